@@ -0,0 +1,674 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"coach-calendar-app/handlers"
+
+	"github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// backupFormat identifies coachctl backup files. backupFormatVersion changes with the
+// layout of the file, not with the database schema, which the manifest records separately.
+const (
+	backupFormat        = "coach-calendar-backup"
+	backupFormatVersion = 1
+)
+
+// backupTable is a table included in backups
+type backupTable struct {
+	name       string
+	orderBy    string // export order, which is also the restore order of its rows
+	appendOnly bool   // rows cannot be updated, so overwrite leaves existing ones alone
+}
+
+// backupTables lists every table in restore order: tables come after those they reference.
+// A new table must be added here or to backupExcluded, or export fails.
+var backupTables = []backupTable{
+	{name: "session_types", orderBy: "key"},
+	{name: "clients", orderBy: "merged_into_id IS NOT NULL, id"}, // merge targets first
+	{name: "packages", orderBy: "id"},
+	{name: "discount_codes", orderBy: "id"},
+	{name: "bookings", orderBy: "id"},
+	{name: "booking_reschedules", orderBy: "id"},
+	{name: "session_notes", orderBy: "id"},
+	{name: "payments", orderBy: "id"},
+	{name: "waitlist_entries", orderBy: "id"},
+	{name: "blocked_slots", orderBy: "id"},
+	{name: "bulk_operations", orderBy: "created_at, id"},
+	{name: "feature_flags", orderBy: "key"},
+	{name: "audit_events", orderBy: "id", appendOnly: true},
+}
+
+// backupExcluded are tables left out of backups: short-lived rate limit counters, and the
+// schema versions initDB records in each database itself
+var backupExcluded = map[string]bool{
+	"rate_limit_buckets": true,
+	"schema_migrations":  true,
+}
+
+// backupFile is the file written by "coachctl export" and read by "coachctl import".
+// Rows are JSON objects keyed by column name.
+type backupFile struct {
+	Manifest backupManifest               `json:"manifest"`
+	Tables   map[string][]json.RawMessage `json:"tables"`
+}
+
+type backupManifest struct {
+	Format        string            `json:"format"`
+	FormatVersion int               `json:"format_version"`
+	SchemaVersion string            `json:"schema_version,omitempty"` // empty for SQLite exports
+	CreatedAt     time.Time         `json:"created_at"`
+	Source        string            `json:"source"` // postgres or sqlite
+	Tables        []backupTableInfo `json:"tables"`
+}
+
+type backupTableInfo struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	PrimaryKey []string `json:"primary_key"`
+	Rows       int      `json:"rows"`
+}
+
+// Conflict strategies for backup rows whose primary key exists with different values
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictFail      = "fail"
+)
+
+// sqlQueryer is satisfied by *sql.DB and *sql.Tx
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// runExport writes every table of DATABASE_URL, or the bookings and blocked slots of a
+// legacy SQLite database given with -sqlite, to a backup file
+func runExport(args []string) error {
+	fs := newFlagSet("export", "[-sqlite FILE] [-o FILE]")
+	sqlitePath := fs.String("sqlite", "", "read the legacy SQLite database FILE instead of DATABASE_URL")
+	output := fs.String("o", "-", "output file; - writes to stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	var backup *backupFile
+	if *sqlitePath != "" {
+		if _, err := os.Stat(*sqlitePath); err != nil {
+			return err
+		}
+		source, err := sql.Open("sqlite", *sqlitePath)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+
+		if backup, err = exportSQLite(ctx, source); err != nil {
+			return err
+		}
+	} else {
+		cfg, err := LoadConfig()
+		if err := requireValidConfig(err); err != nil {
+			return err
+		}
+		if err := initDB(ctx, cfg.DatabaseURL); err != nil {
+			return err
+		}
+		defer db.Close()
+
+		if backup, err = exportPostgres(ctx); err != nil {
+			return err
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(backup); err != nil {
+		return err
+	}
+
+	for _, info := range backup.Manifest.Tables {
+		fmt.Fprintf(os.Stderr, "Exported %d rows from %s\n", info.Rows, info.Name)
+	}
+	return nil
+}
+
+// exportPostgres reads every table from one consistent snapshot
+func exportPostgres(ctx context.Context) (*backupFile, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	existing, err := queryStrings(ctx, tx, `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'`)
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	covered := make(map[string]bool, len(backupTables))
+	for _, t := range backupTables {
+		covered[t.name] = true
+	}
+	for _, name := range existing {
+		if !covered[name] && !backupExcluded[name] {
+			return nil, fmt.Errorf("table %s is not covered by backups; add it to backupTables or backupExcluded", name)
+		}
+	}
+
+	backup := &backupFile{
+		Manifest: backupManifest{
+			Format:        backupFormat,
+			FormatVersion: backupFormatVersion,
+			SchemaVersion: schemaVersion,
+			CreatedAt:     time.Now().UTC(),
+			Source:        "postgres",
+		},
+		Tables: make(map[string][]json.RawMessage),
+	}
+	for _, t := range backupTables {
+		info := backupTableInfo{Name: t.name}
+		if info.Columns, err = tableColumns(ctx, tx, t.name); err != nil {
+			return nil, fmt.Errorf("reading columns of %s: %w", t.name, err)
+		}
+		if info.PrimaryKey, err = primaryKey(ctx, tx, t.name); err != nil {
+			return nil, fmt.Errorf("reading primary key of %s: %w", t.name, err)
+		}
+
+		rows, err := queryStrings(ctx, tx, fmt.Sprintf("SELECT to_jsonb(t)::text FROM %s t ORDER BY %s", pq.QuoteIdentifier(t.name), t.orderBy))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", t.name, err)
+		}
+		data := make([]json.RawMessage, len(rows))
+		for i, row := range rows {
+			data[i] = json.RawMessage(row)
+		}
+
+		info.Rows = len(data)
+		backup.Manifest.Tables = append(backup.Manifest.Tables, info)
+		backup.Tables[t.name] = data
+	}
+	return backup, nil
+}
+
+// exportSQLite reads the bookings and blocked slots of the SQLite database used before the
+// move to Postgres. Its schema predates session types and booking statuses, so restored
+// bookings get the column defaults.
+func exportSQLite(ctx context.Context, source *sql.DB) (*backupFile, error) {
+	backup := &backupFile{
+		Manifest: backupManifest{
+			Format:        backupFormat,
+			FormatVersion: backupFormatVersion,
+			CreatedAt:     time.Now().UTC(),
+			Source:        "sqlite",
+			Tables: []backupTableInfo{
+				{Name: "bookings", Columns: []string{"id", "slot_time", "name", "email", "created_at", "duration", "zoom_link"}, PrimaryKey: []string{"id"}},
+				{Name: "blocked_slots", Columns: []string{"id", "slot_time", "created_at"}, PrimaryKey: []string{"id"}},
+			},
+		},
+		Tables: map[string][]json.RawMessage{"bookings": {}, "blocked_slots": {}},
+	}
+
+	add := func(name string, row map[string]any) error {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		backup.Tables[name] = append(backup.Tables[name], data)
+		return nil
+	}
+
+	rows, err := source.QueryContext(ctx, "SELECT id, slot_time, name, email, created_at, duration, zoom_link FROM bookings ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("querying bookings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, duration int
+		var slotTime time.Time
+		var name, email string
+		var createdAt sql.NullTime
+		var zoomLink sql.NullString
+		if err := rows.Scan(&id, &slotTime, &name, &email, &createdAt, &duration, &zoomLink); err != nil {
+			return nil, fmt.Errorf("reading booking: %w", err)
+		}
+		row := map[string]any{"id": id, "slot_time": slotTime, "name": name, "email": email, "duration": duration}
+		if createdAt.Valid {
+			row["created_at"] = createdAt.Time
+		}
+		if zoomLink.Valid {
+			row["zoom_link"] = zoomLink.String
+		}
+		if err := add("bookings", row); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying bookings: %w", err)
+	}
+
+	blockedRows, err := source.QueryContext(ctx, "SELECT id, slot_time, created_at FROM blocked_slots ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("querying blocked slots: %w", err)
+	}
+	defer blockedRows.Close()
+	for blockedRows.Next() {
+		var id int
+		var slotTime time.Time
+		var createdAt sql.NullTime
+		if err := blockedRows.Scan(&id, &slotTime, &createdAt); err != nil {
+			return nil, fmt.Errorf("reading blocked slot: %w", err)
+		}
+		row := map[string]any{"id": id, "slot_time": slotTime}
+		if createdAt.Valid {
+			row["created_at"] = createdAt.Time
+		}
+		if err := add("blocked_slots", row); err != nil {
+			return nil, err
+		}
+	}
+	if err := blockedRows.Err(); err != nil {
+		return nil, fmt.Errorf("querying blocked slots: %w", err)
+	}
+
+	for i := range backup.Manifest.Tables {
+		info := &backup.Manifest.Tables[i]
+		info.Rows = len(backup.Tables[info.Name])
+	}
+	return backup, nil
+}
+
+// restoreResult counts what happened to the rows of one table
+type restoreResult struct {
+	table     string
+	rows      int // in the backup
+	inserted  int
+	updated   int
+	skipped   int // already present: identical, or left as is by skip
+	conflicts int // clashed with a different row on another unique key; skip only
+	present   int // backup rows found by primary key after the restore
+}
+
+// runImport restores a backup into DATABASE_URL in one transaction. Rows are matched by
+// primary key and rows identical to the backup's are left alone, so restoring the same
+// backup again changes nothing. Before committing, the backup's rows are counted in every
+// table; if any is missing the restore is rolled back.
+func runImport(args []string) error {
+	fs := newFlagSet("import", "[-on-conflict skip|overwrite|fail] [-dry-run] FILE")
+	onConflict := fs.String("on-conflict", conflictSkip, "rows that exist with different values: skip keeps them, overwrite replaces them, fail aborts the restore")
+	dryRun := fs.Bool("dry-run", false, "restore and verify, then roll back")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errReported
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errReported
+	}
+	switch *onConflict {
+	case conflictSkip, conflictOverwrite, conflictFail:
+	default:
+		return fmt.Errorf("-on-conflict must be skip, overwrite or fail")
+	}
+
+	backup, err := readBackup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig()
+	if err := requireValidConfig(err); err != nil {
+		return err
+	}
+	ctx, stop := commandContext()
+	defer stop()
+	if err := initDB(ctx, cfg.DatabaseURL); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := backup.Manifest
+	fmt.Printf("Restoring %s backup from %s", m.Source, m.CreatedAt.Format(time.RFC3339))
+	if m.SchemaVersion != "" {
+		fmt.Printf(" (schema %s)", m.SchemaVersion)
+	}
+	fmt.Println()
+	if m.SchemaVersion != "" && m.SchemaVersion != schemaVersion {
+		fmt.Fprintf(os.Stderr, "Warning: the backup was made with schema %s and this database has %s; columns are matched by name\n", m.SchemaVersion, schemaVersion)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Backups made before client notes were encrypted have a plaintext clients.notes column.
+	// It is restored into a temporary column and moved into encrypted notes, as on upgrade.
+	legacyNotes := false
+	if info, ok := backup.table("clients"); ok && slices.Contains(info.Columns, "notes") {
+		columns, err := tableColumns(ctx, tx, "clients")
+		if err != nil {
+			return fmt.Errorf("reading columns of clients: %w", err)
+		}
+		if !slices.Contains(columns, "notes") {
+			if _, err := tx.ExecContext(ctx, "ALTER TABLE clients ADD COLUMN notes TEXT"); err != nil {
+				return err
+			}
+			legacyNotes = true
+		}
+	}
+
+	var results []restoreResult
+	for _, t := range backupTables {
+		info, ok := backup.table(t.name)
+		if !ok {
+			continue
+		}
+		result, err := restoreTable(ctx, tx, t, info, backup.Tables[t.name], *onConflict)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+	if legacyNotes {
+		moved, err := handlers.MoveClientNotes(ctx, tx, loadNoteCipher(cfg))
+		if err != nil {
+			return fmt.Errorf("restoring client notes: %w", err)
+		}
+		fmt.Printf("Moved %d client notes from the backup into encrypted notes\n", moved)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tBACKUP\tINSERTED\tUPDATED\tSKIPPED\tCONFLICTS\tVERIFIED")
+	var missing []string
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", r.table, r.rows, r.inserted, r.updated, r.skipped, r.conflicts, r.present)
+		if r.present != r.rows-r.conflicts {
+			missing = append(missing, fmt.Sprintf("%s: %d of %d rows", r.table, r.present, r.rows-r.conflicts))
+		}
+	}
+	tw.Flush()
+
+	if len(missing) > 0 {
+		return fmt.Errorf("verification failed, nothing was restored: %s", strings.Join(missing, "; "))
+	}
+	for _, r := range results {
+		if r.conflicts > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d %s rows were not restored: they clash with different rows on a unique column such as email or code\n", r.conflicts, r.table)
+		}
+	}
+	if *dryRun {
+		fmt.Println("\nDry run: verified and rolled back")
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Println("\nRestore complete and verified")
+	return nil
+}
+
+// readBackup reads and checks a backup file
+func readBackup(path string) (*backupFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var backup backupFile
+	if err := json.Unmarshal(raw, &backup); err != nil {
+		return nil, fmt.Errorf("%s is not a coachctl backup: %w", path, err)
+	}
+
+	m := backup.Manifest
+	if m.Format != backupFormat {
+		return nil, fmt.Errorf("%s is not a coachctl backup", path)
+	}
+	if m.FormatVersion < 1 || m.FormatVersion > backupFormatVersion {
+		return nil, fmt.Errorf("%s has backup format version %d; this coachctl reads up to %d", path, m.FormatVersion, backupFormatVersion)
+	}
+
+	known := make(map[string]bool, len(backupTables))
+	for _, t := range backupTables {
+		known[t.name] = true
+	}
+	for _, info := range m.Tables {
+		if !known[info.Name] {
+			return nil, fmt.Errorf("%s has table %s, which this coachctl does not restore", path, info.Name)
+		}
+		if n := len(backup.Tables[info.Name]); n != info.Rows {
+			return nil, fmt.Errorf("%s is incomplete: the manifest lists %d %s rows but the file has %d", path, info.Rows, info.Name, n)
+		}
+	}
+	return &backup, nil
+}
+
+// table returns the manifest entry of name
+func (b *backupFile) table(name string) (backupTableInfo, bool) {
+	for _, info := range b.Manifest.Tables {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return backupTableInfo{}, false
+}
+
+// restoreTable restores the rows of one table and counts them back by primary key
+func restoreTable(ctx context.Context, tx *sql.Tx, t backupTable, info backupTableInfo, rows []json.RawMessage, onConflict string) (restoreResult, error) {
+	result := restoreResult{table: t.name, rows: len(rows)}
+
+	targetColumns, err := tableColumns(ctx, tx, t.name)
+	if err != nil {
+		return result, fmt.Errorf("reading columns of %s: %w", t.name, err)
+	}
+	if len(targetColumns) == 0 {
+		return result, fmt.Errorf("table %s does not exist in this database", t.name)
+	}
+	pk, err := primaryKey(ctx, tx, t.name)
+	if err != nil {
+		return result, fmt.Errorf("reading primary key of %s: %w", t.name, err)
+	}
+
+	// Every backed-up column must exist here, and the primary key must be in the backup
+	exists := make(map[string]bool, len(targetColumns))
+	for _, c := range targetColumns {
+		exists[c] = true
+	}
+	backedUp := make(map[string]bool, len(info.Columns))
+	for _, c := range info.Columns {
+		if !exists[c] {
+			return result, fmt.Errorf("%s.%s is in the backup but not in this database; upgrade before restoring", t.name, c)
+		}
+		backedUp[c] = true
+	}
+	for _, c := range pk {
+		if !backedUp[c] {
+			return result, fmt.Errorf("the backup of %s lacks its primary key column %s", t.name, c)
+		}
+	}
+
+	table := pq.QuoteIdentifier(t.name)
+	columns := quoteIdentifiers(info.Columns)
+	keys := quoteIdentifiers(pk)
+	record := fmt.Sprintf("jsonb_populate_record(NULL::%s, $1::jsonb)", table)
+
+	// The existing row with the same primary key: missing, the same as the backup's in every
+	// backed-up column, or different
+	var joinOn, existing, restored []string
+	for _, k := range keys {
+		joinOn = append(joinOn, fmt.Sprintf("t.%s = r.%s", k, k))
+	}
+	for _, c := range columns {
+		existing = append(existing, "t."+c)
+		restored = append(restored, "r."+c)
+	}
+	compareSQL := fmt.Sprintf(`
+		SELECT CASE WHEN t.%s IS NULL THEN 'missing'
+			WHEN ROW(%s) IS NOT DISTINCT FROM ROW(%s) THEN 'same'
+			ELSE 'different' END
+		FROM %s r LEFT JOIN %s t ON %s`,
+		keys[0], strings.Join(existing, ", "), strings.Join(restored, ", "), record, table, strings.Join(joinOn, " AND "))
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, strings.Join(columns, ", "), strings.Join(columns, ", "), record)
+
+	var updates []string
+	isKey := make(map[string]bool, len(pk))
+	for _, c := range pk {
+		isKey[c] = true
+	}
+	for _, c := range info.Columns {
+		if !isKey[c] {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", pq.QuoteIdentifier(c), pq.QuoteIdentifier(c)))
+		}
+	}
+	overwriteSQL := insertSQL + fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(keys, ", "))
+	if len(updates) > 0 {
+		overwriteSQL = insertSQL + fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(updates, ", "))
+	}
+
+	for i, row := range rows {
+		var state string
+		if err := tx.QueryRowContext(ctx, compareSQL, string(row)).Scan(&state); err != nil {
+			return result, fmt.Errorf("restoring %s row %d: %w", t.name, i+1, err)
+		}
+
+		switch {
+		case state == "same":
+			result.skipped++
+		case state == "different" && onConflict == conflictFail:
+			return result, fmt.Errorf("restoring %s %s: a different row with this key exists (-on-conflict fail)", t.name, rowKey(row, pk))
+		case state == "different" && (onConflict == conflictSkip || t.appendOnly):
+			result.skipped++
+		case state == "different":
+			if _, err := tx.ExecContext(ctx, overwriteSQL, string(row)); err != nil {
+				return result, fmt.Errorf("restoring %s %s: %w", t.name, rowKey(row, pk), err)
+			}
+			result.updated++
+		case onConflict == conflictSkip:
+			res, err := tx.ExecContext(ctx, insertSQL+" ON CONFLICT DO NOTHING", string(row))
+			if err != nil {
+				return result, fmt.Errorf("restoring %s %s: %w", t.name, rowKey(row, pk), err)
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				result.conflicts++
+			} else {
+				result.inserted++
+			}
+		default:
+			if _, err := tx.ExecContext(ctx, insertSQL, string(row)); err != nil {
+				return result, fmt.Errorf("restoring %s %s: %w", t.name, rowKey(row, pk), err)
+			}
+			result.inserted++
+		}
+	}
+
+	// Serial keys continue after the restored rows
+	if len(pk) == 1 {
+		var sequence sql.NullString
+		if err := tx.QueryRowContext(ctx, "SELECT pg_get_serial_sequence($1, $2)", t.name, pk[0]).Scan(&sequence); err != nil {
+			return result, fmt.Errorf("reading the sequence of %s: %w", t.name, err)
+		}
+		if sequence.Valid {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("SELECT setval($1::regclass, COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)", keys[0], table), sequence.String)
+			if err != nil {
+				return result, fmt.Errorf("resetting the sequence of %s: %w", t.name, err)
+			}
+		}
+	}
+
+	// Verify: count the backup's rows in the table by primary key
+	all, err := json.Marshal(rows)
+	if err != nil {
+		return result, err
+	}
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s JOIN jsonb_populate_recordset(NULL::%s, $1::jsonb) r USING (%s)", table, table, strings.Join(keys, ", "))
+	if err := tx.QueryRowContext(ctx, countSQL, string(all)).Scan(&result.present); err != nil {
+		return result, fmt.Errorf("verifying %s: %w", t.name, err)
+	}
+	return result, nil
+}
+
+// rowKey describes the primary key of a backup row for error messages, e.g. "id=42"
+func rowKey(row json.RawMessage, pk []string) string {
+	var values map[string]any
+	json.Unmarshal(row, &values)
+	parts := make([]string, len(pk))
+	for i, c := range pk {
+		parts[i] = fmt.Sprintf("%s=%v", c, values[c])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tableColumns returns the columns of table in the current schema, in table order
+func tableColumns(ctx context.Context, q sqlQueryer, table string) ([]string, error) {
+	return queryStrings(ctx, q, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, table)
+}
+
+// primaryKey returns the primary key columns of table
+func primaryKey(ctx context.Context, q sqlQueryer, table string) ([]string, error) {
+	columns, err := queryStrings(ctx, q, `
+		SELECT a.attname FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary
+		ORDER BY array_position(i.indkey::int2[], a.attnum)`, pq.QuoteIdentifier(table))
+	if err == nil && len(columns) == 0 {
+		err = fmt.Errorf("table has no primary key")
+	}
+	return columns, err
+}
+
+// queryStrings returns the first column of every row of query
+func queryStrings(ctx context.Context, q sqlQueryer, query string, args ...any) ([]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func quoteIdentifiers(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pq.QuoteIdentifier(name)
+	}
+	return quoted
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadBackup(t *testing.T) {
+	row := json.RawMessage(`{"id": 1, "slot_time": "2026-03-02T10:00:00Z"}`)
+	valid := func() backupFile {
+		return backupFile{
+			Manifest: backupManifest{
+				Format:        backupFormat,
+				FormatVersion: backupFormatVersion,
+				CreatedAt:     time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+				Source:        "postgres",
+				Tables: []backupTableInfo{
+					{Name: "blocked_slots", Columns: []string{"id", "slot_time"}, PrimaryKey: []string{"id"}, Rows: 1},
+					{Name: "feature_flags", Columns: []string{"key", "enabled"}, PrimaryKey: []string{"key"}, Rows: 0},
+				},
+			},
+			Tables: map[string][]json.RawMessage{"blocked_slots": {row}, "feature_flags": {}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(b *backupFile)
+		raw     string // written instead of the backup when set
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "not JSON", raw: "bookings.csv", wantErr: "is not a coachctl backup"},
+		{name: "other JSON", raw: `{"bookings": []}`, wantErr: "is not a coachctl backup"},
+		{name: "wrong format", modify: func(b *backupFile) { b.Manifest.Format = "pg_dump" }, wantErr: "is not a coachctl backup"},
+		{name: "future format version", modify: func(b *backupFile) { b.Manifest.FormatVersion = backupFormatVersion + 1 }, wantErr: "has backup format version 2"},
+		{name: "missing format version", modify: func(b *backupFile) { b.Manifest.FormatVersion = 0 }, wantErr: "has backup format version 0"},
+		{
+			name: "unknown table",
+			modify: func(b *backupFile) {
+				b.Manifest.Tables = append(b.Manifest.Tables, backupTableInfo{Name: "rate_limit_buckets", Rows: 0})
+			},
+			wantErr: "has table rate_limit_buckets, which this coachctl does not restore",
+		},
+		{
+			name:    "fewer rows than the manifest",
+			modify:  func(b *backupFile) { b.Tables["blocked_slots"] = nil },
+			wantErr: "the manifest lists 1 blocked_slots rows but the file has 0",
+		},
+		{
+			name:    "more rows than the manifest",
+			modify:  func(b *backupFile) { b.Tables["feature_flags"] = []json.RawMessage{row} },
+			wantErr: "the manifest lists 0 feature_flags rows but the file has 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.raw)
+			if tt.raw == "" {
+				b := valid()
+				if tt.modify != nil {
+					tt.modify(&b)
+				}
+				var err error
+				if content, err = json.Marshal(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := filepath.Join(t.TempDir(), "backup.json")
+			if err := os.WriteFile(path, content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			backup, err := readBackup(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readBackup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readBackup() error = %v", err)
+			}
+			if info, ok := backup.table("blocked_slots"); !ok || info.Rows != 1 {
+				t.Errorf("table(blocked_slots) = %+v, %v", info, ok)
+			}
+			if _, ok := backup.table("bookings"); ok {
+				t.Error("table(bookings) found in a backup without it")
+			}
+		})
+	}
+}
+
+func TestReadBackupMissingFile(t *testing.T) {
+	if _, err := readBackup(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("readBackup() error = %v, want not exist", err)
+	}
+}
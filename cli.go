@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"coach-calendar-app/handlers"
+)
+
+// command is a coachctl subcommand. name may have several words, e.g. "bookings list".
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "run the web server (the default when no command is given)", serve},
+	{"migrate", "apply the database schema and print its version", runMigrate},
+	{"config check", "print the effective configuration and any problems", runConfigCheck},
+	{"block", "block slots by date range, weekday and time of day", runBlock(true)},
+	{"unblock", "unblock slots by date range, weekday and time of day", runBlock(false)},
+	{"bookings list", "list bookings", runBookingsList},
+	{"bookings cancel", "cancel a booking on the coach's behalf", runBookingsCancel},
+	{"email test", "send a test confirmation email", runEmailTest},
+	{"export", "back up all scheduling data to a versioned JSON file", runExport},
+	{"import", "restore a backup made by export, then verify its row counts", runImport},
+}
+
+// errReported is returned by commands that have already printed why they failed
+var errReported = errors.New("failed")
+
+// runCLI runs the coachctl command named by args and returns the process exit code
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "coachctl: unknown command %q\n\n", strings.Join(args, " "))
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	// Commands other than serve only log warnings, to stderr, so their output stays readable
+	if cmd.name != "serve" {
+		slog.SetDefault(handlers.NewLogger(os.Stderr, slog.LevelWarn, handlers.LogFormatText))
+	}
+
+	err := cmd.run(rest)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errReported):
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "coachctl %s: %v\n", cmd.name, err)
+	return 1
+}
+
+// findCommand returns the command named by the leading words of args and the remaining arguments
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: coachctl <command> [options]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun \"coachctl <command> -h\" for the options of a command.")
+	fmt.Fprintln(w, "Configuration is read like the server's: environment, .env, then CONFIG_FILE.")
+}
+
+// newFlagSet returns the flag set of a command; parse errors are reported by the flag package
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: coachctl %s %s\n\nOptions:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, rejecting positional arguments the command does not take
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errReported
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// commandContext is cancelled on Ctrl+C or SIGTERM
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+}
+
+// requireValidConfig returns the configuration problems found by LoadConfig as one error
+func requireValidConfig(err error) error {
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		return err
+	}
+	for _, problem := range configErr.Problems {
+		fmt.Fprintln(os.Stderr, "coachctl: invalid configuration: "+problem)
+	}
+	fmt.Fprintln(os.Stderr, "coachctl: run \"coachctl config check\" to inspect the configuration")
+	return errReported
+}
+
+func runConfigCheck(args []string) error {
+	fs := newFlagSet("config check", "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if configCheck(os.Stdout, os.Stderr) != 0 {
+		return errReported
+	}
+	return nil
+}
+
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate", "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig()
+	if err := requireValidConfig(err); err != nil {
+		return err
+	}
+	ctx, stop := commandContext()
+	defer stop()
+
+	if err := initDB(ctx, cfg.DatabaseURL); err != nil {
+		return err
+	}
+	defer db.Close()
+
+	moved, err := moveClientNotes(ctx, loadNoteCipher(cfg))
+	if err != nil {
+		return err
+	}
+	if moved > 0 {
+		fmt.Printf("Moved %d client notes into encrypted notes\n", moved)
+	}
+
+	fmt.Printf("Database schema is up to date (version %s)\n", schemaVersion)
+	return nil
+}
+
+// adminFlags selects how a command reaches the admin API: over HTTP when -api is set,
+// otherwise through the database directly
+type adminFlags struct {
+	api   string
+	token string
+}
+
+func addAdminFlags(fs *flag.FlagSet, cfg *Config) *adminFlags {
+	f := &adminFlags{}
+	fs.StringVar(&f.api, "api", cfg.CLIAPIURL, "base URL of a running instance; empty uses the database directly (default $COACHCTL_API_URL)")
+	fs.StringVar(&f.token, "token", cfg.CLIAPIToken, "admin API token for -api (default $COACHCTL_API_TOKEN)")
+	return f
+}
+
+// adminClient calls the admin API of a running instance, or of handlers served in process
+type adminClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	close   func()
+}
+
+// connect returns a client for the instance at -api. Without -api the admin routes are
+// served in process against DATABASE_URL, authenticated as the script "coachctl" so the
+// audit log shows where changes came from; the configuration must then be valid.
+func (f *adminFlags) connect(ctx context.Context, cfg *Config, cfgErr error) (*adminClient, error) {
+	if f.api != "" {
+		u, err := url.Parse(f.api)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("-api %q is not an absolute http(s) URL", f.api)
+		}
+		return &adminClient{
+			baseURL: strings.TrimRight(f.api, "/"),
+			token:   f.token,
+			http:    &http.Client{Timeout: cfg.HTTPWriteTimeout},
+			close:   func() {},
+		}, nil
+	}
+
+	if err := requireValidConfig(cfgErr); err != nil {
+		return nil, err
+	}
+	if err := initDB(ctx, cfg.DatabaseURL); err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	registerRoutes(mux, newAPIHandlers(cfg), &handlers.AdminAuth{
+		APITokens: map[string]string{"coachctl": token},
+	})
+
+	return &adminClient{
+		baseURL: "http://coachctl.internal",
+		token:   token,
+		http:    &http.Client{Transport: handlerTransport{handlers.WithRequestID(mux)}},
+		close:   func() { db.Close() },
+	}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handlerTransport answers requests with an in-process handler instead of the network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	r.RemoteAddr = "127.0.0.1:0"
+	r.RequestURI = r.URL.RequestURI()
+
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, r)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// do sends body as JSON to path and decodes the JSON response into out, if not nil.
+// Responses other than 2xx are returned as errors carrying the response text.
+func (c *adminClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(text)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// blockPresets are named schedules for block and unblock. "standard" is the coach's usual
+// week: lunch breaks Monday to Thursday and all of Sunday.
+var blockPresets = map[string][]handlers.SlotFilter{
+	"standard": {
+		{Weekdays: []int{1, 2, 3, 4}, StartTime: "11:30", EndTime: "15:30"},
+		{Weekdays: []int{0}, StartTime: "09:00", EndTime: "20:30"},
+	},
+}
+
+// runBlock returns the block (or unblock) command. Slots are selected like the bulk
+// actions in /admin, or one at a time with -slot.
+func runBlock(block bool) func(args []string) error {
+	name, verb := "block", "blocked"
+	if !block {
+		name, verb = "unblock", "unblocked"
+	}
+
+	return func(args []string) error {
+		cfg, cfgErr := LoadConfig()
+
+		fs := newFlagSet(name, "[options]")
+		admin := addAdminFlags(fs, cfg)
+		from := fs.String("from", "", "first date, YYYY-MM-DD (default today)")
+		to := fs.String("to", "", "last date, YYYY-MM-DD (default -from plus -days)")
+		days := fs.Int("days", 30, "number of days from -from when -to is not set")
+		weekdays := fs.String("weekdays", "", "comma-separated weekdays, 0 = Sunday … 6 = Saturday (default every day)")
+		start := fs.String("start", "", "first slot time of day, HH:MM")
+		end := fs.String("end", "", "end time of day, HH:MM, exclusive")
+		preset := fs.String("preset", "", "named schedule instead of -weekdays/-start/-end: standard (Mon-Thu 11:30-15:00, Sun 9:00-20:00)")
+		slot := fs.String("slot", "", "a single slot, RFC3339, instead of a range")
+		reason := fs.String("reason", "", "reason recorded with the operation")
+		dryRun := fs.Bool("dry-run", false, "show the slots that would change without changing them")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+
+		ctx, stop := commandContext()
+		defer stop()
+		client, err := admin.connect(ctx, cfg, cfgErr)
+		if err != nil {
+			return err
+		}
+		defer client.close()
+
+		if *slot != "" {
+			if _, err := time.Parse(time.RFC3339, *slot); err != nil {
+				return fmt.Errorf("-slot must be RFC3339, e.g. 2026-01-05T11:30:00+01:00")
+			}
+			if *dryRun {
+				fmt.Printf("Would be %s: %s\n", verb, *slot)
+				return nil
+			}
+			if err := client.do(ctx, http.MethodPost, "/api/admin/"+name, map[string]string{"slot_time": *slot}, nil); err != nil {
+				return err
+			}
+			fmt.Printf("Slot %s: %s\n", verb, *slot)
+			return nil
+		}
+
+		location, err := time.LoadLocation("Europe/Amsterdam")
+		if err != nil {
+			location = time.UTC
+		}
+		if *from == "" {
+			*from = time.Now().In(location).Format("2006-01-02")
+		}
+		if *to == "" {
+			first, err := time.ParseInLocation("2006-01-02", *from, location)
+			if err != nil {
+				return fmt.Errorf("-from must be YYYY-MM-DD")
+			}
+			if *days < 1 {
+				return fmt.Errorf("-days must be at least 1")
+			}
+			*to = first.AddDate(0, 0, *days-1).Format("2006-01-02")
+		}
+
+		filters := []handlers.SlotFilter{{StartTime: *start, EndTime: *end}}
+		if *weekdays != "" {
+			for _, field := range strings.Split(*weekdays, ",") {
+				day, err := strconv.Atoi(strings.TrimSpace(field))
+				if err != nil || day < 0 || day > 6 {
+					return fmt.Errorf("-weekdays must be numbers from 0 (Sunday) to 6 (Saturday)")
+				}
+				filters[0].Weekdays = append(filters[0].Weekdays, day)
+			}
+		}
+		if *preset != "" {
+			if *weekdays != "" || *start != "" || *end != "" {
+				return fmt.Errorf("-preset cannot be combined with -weekdays, -start or -end")
+			}
+			var ok bool
+			if filters, ok = blockPresets[*preset]; !ok {
+				return fmt.Errorf("unknown preset %q", *preset)
+			}
+		}
+
+		for _, filter := range filters {
+			filter.From, filter.To = *from, *to
+			req := handlers.BulkRequest{SlotFilter: filter, Reason: *reason, DryRun: *dryRun}
+
+			var result handlers.BulkResult
+			if err := client.do(ctx, http.MethodPost, "/api/admin/bulk/"+name, req, &result); err != nil {
+				return err
+			}
+			printBulkResult(os.Stdout, verb, result, location)
+		}
+		return nil
+	}
+}
+
+func printBulkResult(w io.Writer, verb string, result handlers.BulkResult, location *time.Location) {
+	c := result.Criteria
+	fmt.Fprintf(w, "%s to %s", c.From, c.To)
+	if len(c.Weekdays) > 0 {
+		fmt.Fprintf(w, ", weekdays %v", c.Weekdays)
+	}
+	if c.StartTime != "" || c.EndTime != "" {
+		fmt.Fprintf(w, ", %s-%s", c.StartTime, c.EndTime)
+	}
+	fmt.Fprintln(w)
+
+	for _, item := range result.Affected {
+		fmt.Fprintf(w, "  %s  %s\n", verb, item.SlotTime.In(location).Format("Mon 2006-01-02 15:04"))
+	}
+	for _, item := range result.Skipped {
+		fmt.Fprintf(w, "  skipped  %s  %s\n", item.SlotTime.In(location).Format("Mon 2006-01-02 15:04"), item.Reason)
+	}
+
+	if result.DryRun {
+		fmt.Fprintf(w, "Dry run: %d slot(s) would be %s, %d skipped\n\n", result.Count, verb, len(result.Skipped))
+		return
+	}
+	fmt.Fprintf(w, "%d slot(s) %s, %d skipped", result.Count, verb, len(result.Skipped))
+	if result.OperationID != "" && result.UndoExpiresAt != nil {
+		fmt.Fprintf(w, " (operation %s, undo from /admin until %s)", result.OperationID, result.UndoExpiresAt.In(location).Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(w, "\n\n")
+}
+
+func runBookingsList(args []string) error {
+	cfg, cfgErr := LoadConfig()
+
+	fs := newFlagSet("bookings list", "[options]")
+	admin := addAdminFlags(fs, cfg)
+	from := fs.String("from", "", "first slot date, YYYY-MM-DD or RFC3339")
+	to := fs.String("to", "", "last slot date, YYYY-MM-DD or RFC3339")
+	status := fs.String("status", "", "comma-separated statuses, e.g. pending,confirmed")
+	query := fs.String("q", "", "name or email contains")
+	email := fs.String("email", "", "client email")
+	limit := fs.Int("limit", 50, "bookings per page, at most 200")
+	cursor := fs.String("cursor", "", "next page cursor printed by the previous call")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	client, err := admin.connect(ctx, cfg, cfgErr)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	params := url.Values{"limit": {strconv.Itoa(*limit)}, "sort": {"slot_time"}, "order": {"asc"}}
+	for key, value := range map[string]string{"from": *from, "to": *to, "status": *status, "q": *query, "email": *email, "cursor": *cursor} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	var page struct {
+		Bookings   []handlers.BookingDetails `json:"bookings"`
+		NextCursor string                    `json:"next_cursor"`
+	}
+	if err := client.do(ctx, http.MethodGet, "/api/admin/bookings?"+params.Encode(), nil, &page); err != nil {
+		return err
+	}
+
+	location, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		location = time.UTC
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSLOT\tSTATUS\tTYPE\tNAME\tEMAIL")
+	for _, b := range page.Bookings {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", b.ID, b.SlotTime.In(location).Format("Mon 2006-01-02 15:04"), b.Status, b.SessionType, b.Name, b.Email)
+	}
+	tw.Flush()
+
+	if page.NextCursor != "" {
+		fmt.Printf("\nMore bookings: add -cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
+
+func runBookingsCancel(args []string) error {
+	cfg, cfgErr := LoadConfig()
+
+	fs := newFlagSet("bookings cancel", "-id ID | -slot TIME [options]")
+	admin := addAdminFlags(fs, cfg)
+	id := fs.Int("id", 0, "booking ID")
+	slot := fs.String("slot", "", "slot time of the booking, RFC3339, instead of -id")
+	reason := fs.String("reason", "", "reason given to the client")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if (*id == 0) == (*slot == "") {
+		return fmt.Errorf("set either -id or -slot")
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+	client, err := admin.connect(ctx, cfg, cfgErr)
+	if err != nil {
+		return err
+	}
+	defer client.close()
+
+	if *slot != "" {
+		req := map[string]string{"slot_time": *slot, "reason": *reason}
+		if err := client.do(ctx, http.MethodPost, "/api/admin/cancel", req, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Booking at %s cancelled\n", *slot)
+		return nil
+	}
+
+	req := map[string]any{"id": *id, "status": handlers.StatusCancelledByCoach, "reason": *reason}
+	if err := client.do(ctx, http.MethodPost, "/api/admin/bookings/status", req, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Booking %d cancelled\n", *id)
+	return nil
+}
+
+func runEmailTest(args []string) error {
+	cfg, _ := LoadConfig()
+
+	fs := newFlagSet("email test", "-to ADDRESS [options]")
+	to := fs.String("to", "", "recipient email address (required)")
+	name := fs.String("name", "Test User", "recipient name")
+	zoomLink := fs.String("zoom", "", "Zoom meeting link to include")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return fmt.Errorf("-to is required")
+	}
+
+	emailService := NewEmailService(cfg.SMTP)
+	if !emailService.Enabled {
+		return fmt.Errorf("email is not configured: set SMTP_HOST, SMTP_PORT, SMTP_FROM and SMTP_PASSWORD")
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	// A booking tomorrow at 14:00
+	now := time.Now()
+	slotTime := time.Date(now.Year(), now.Month(), now.Day()+1, 14, 0, 0, 0, now.Location())
+
+	fmt.Printf("Sending a test confirmation email from %s <%s> to %s\n", cfg.SMTP.FromName, cfg.SMTP.From, *to)
+	if err := emailService.SendBookingConfirmation(ctx, *name, *to, slotTime, defaultSessionDuration, false, *zoomLink, ""); err != nil {
+		return err
+	}
+	fmt.Println("Sent. It may take a few moments to arrive and can end up in the spam folder.")
+	return nil
+}
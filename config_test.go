@@ -0,0 +1,227 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"coach-calendar-app/handlers"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "flat settings",
+			content: "---\n# Database\nDATABASE_URL: postgres://localhost/bookings\n\nport: 9090\n",
+			want:    map[string]string{"DATABASE_URL": "postgres://localhost/bookings", "PORT": "9090"},
+		},
+		{
+			name:    "quoted values",
+			content: "SMTP_FROM: \"Coach <coach@example.com>\"\nSMTP_PASSWORD: 'it''s # secret'\n",
+			want:    map[string]string{"SMTP_FROM": "Coach <coach@example.com>", "SMTP_PASSWORD": "it's # secret"},
+		},
+		{
+			name:    "trailing comment",
+			content: "PAYMENT_HOLD: 15m # while the client pays\nZOOM_URL: https://zoom.us/j/1#x\n",
+			want:    map[string]string{"PAYMENT_HOLD": "15m", "ZOOM_URL": "https://zoom.us/j/1#x"},
+		},
+		{
+			name:    "empty value",
+			content: "PUBLIC_BASE_URL:\n",
+			want:    map[string]string{"PUBLIC_BASE_URL": ""},
+		},
+		{
+			name:    "nested mapping",
+			content: "smtp:\n  host: smtp.example.com\n",
+			wantErr: ":2: nested values are not supported",
+		},
+		{
+			name:    "list",
+			content: "ADMIN_API_TOKENS:\n- a\n",
+			wantErr: ":2: nested values are not supported",
+		},
+		{
+			name:    "missing colon",
+			content: "PORT 8080\n",
+			wantErr: ":1: expected KEY: value",
+		},
+		{
+			name:    "missing key",
+			content: ": 8080\n",
+			wantErr: ":1: expected KEY: value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readConfigFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readConfigFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfigFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readConfigFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileMissing(t *testing.T) {
+	if _, err := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("readConfigFile() error = %v, want not exist", err)
+	}
+}
+
+// newTestConfigLoader returns a loader reading key from a config file only, with the
+// environment variable cleared
+func newTestConfigLoader(t *testing.T, key, value string) *configLoader {
+	t.Setenv(key, "")
+	return &configLoader{file: map[string]string{key: value}, filePath: "config.yaml"}
+}
+
+func TestConfigLoaderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		load    func(l *configLoader, key string) any
+		want    any
+		wantErr string
+	}{
+		{"port", "PORT", "8081", func(l *configLoader, k string) any { return l.port(k, "8080") }, "8081", ""},
+		{"port default", "PORT", "", func(l *configLoader, k string) any { return l.port(k, "8080") }, "8080", ""},
+		{"port out of range", "PORT", "70000", func(l *configLoader, k string) any { return l.port(k, "8080") }, "70000", `PORT (from config.yaml): "70000" is not a port number`},
+		{"duration", "PAYMENT_HOLD", "15m", func(l *configLoader, k string) any { return l.duration(k, time.Hour) }, 15 * time.Minute, ""},
+		{"negative duration", "PAYMENT_HOLD", "-1m", func(l *configLoader, k string) any { return l.duration(k, time.Hour) }, time.Hour, "is not a duration"},
+		{"integer", "BOOKING_MAX_PER_DAY", "3", func(l *configLoader, k string) any { return l.integer(k, 1) }, 3, ""},
+		{"negative integer", "BOOKING_MAX_PER_DAY", "-3", func(l *configLoader, k string) any { return l.integer(k, 1) }, 1, "is not a non-negative whole number"},
+		{"boolean", "BOOKING_OPEN", "Yes", func(l *configLoader, k string) any { return l.boolean(k, false) }, true, ""},
+		{"not a boolean", "BOOKING_OPEN", "maybe", func(l *configLoader, k string) any { return l.boolean(k, false) }, false, "is not a boolean"},
+		{"choice lower-cased", "LOG_FORMAT", "TEXT", func(l *configLoader, k string) any { return l.choice(k, "json", "json", "text") }, "text", ""},
+		{"unknown choice", "PAYMENT_PROVIDER", "fake", func(l *configLoader, k string) any { return l.choice(k, "", "", "stripe") }, "", `"fake" is not one of stripe`},
+		{"base URL", "PUBLIC_BASE_URL", "https://coach.example.com", func(l *configLoader, k string) any { return l.baseURL(k) }, "https://coach.example.com", ""},
+		{"relative base URL", "PUBLIC_BASE_URL", "coach.example.com", func(l *configLoader, k string) any { return l.baseURL(k) }, "coach.example.com", "is not an absolute http(s) URL"},
+		{"missing database URL", "DATABASE_URL", "", func(l *configLoader, k string) any { return l.databaseURL(k) }, "", "DATABASE_URL: required"},
+		{"rate limit", "RATE_LIMIT_IP", "5/1h", func(l *configLoader, k string) any { return l.rateLimit(k, "10/1h") }, handlers.RateLimit{Burst: 5, Per: time.Hour}, ""},
+		{"invalid rate limit", "RATE_LIMIT_IP", "5", func(l *configLoader, k string) any { return l.rateLimit(k, "10/1h") }, handlers.RateLimit{Burst: 10, Per: time.Hour}, "expected <burst>/<duration>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestConfigLoader(t, tt.key, tt.value)
+			got := tt.load(l, tt.key)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+			problems := strings.Join(l.problems, "\n")
+			if tt.wantErr == "" && problems != "" {
+				t.Errorf("unexpected problems: %s", problems)
+			}
+			if tt.wantErr != "" && !strings.Contains(problems, tt.wantErr) {
+				t.Errorf("problems = %q, want one containing %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigLoaderPrecedence(t *testing.T) {
+	l := &configLoader{
+		dotenv:   map[string]string{"PORT": "8082", "SMTP_HOST": "dotenv.example.com"},
+		file:     map[string]string{"PORT": "8083", "SMTP_HOST": "file.example.com", "SMTP_FROM": "coach@example.com"},
+		filePath: "config.yaml",
+	}
+	t.Setenv("PORT", "8081")
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_FROM", "")
+
+	for key, want := range map[string][2]string{
+		"PORT":      {"8081", "env"},
+		"SMTP_HOST": {"dotenv.example.com", ".env"},
+		"SMTP_FROM": {"coach@example.com", "config.yaml"},
+	} {
+		if value, source := l.lookup(key); value != want[0] || source != want[1] {
+			t.Errorf("lookup(%q) = %q from %q, want %q from %q", key, value, source, want[0], want[1])
+		}
+	}
+}
+
+func TestConfigLoaderSecretsMasked(t *testing.T) {
+	l := newTestConfigLoader(t, "STRIPE_SECRET_KEY", "sk_live_abcdef")
+	if got := l.secret("STRIPE_SECRET_KEY"); got != "sk_live_abcdef" {
+		t.Errorf("secret() = %q", got)
+	}
+	if len(l.settings) != 1 || strings.Contains(l.settings[0].Value, "abcdef") {
+		t.Errorf("recorded setting leaks the secret: %+v", l.settings)
+	}
+}
+
+func TestConfigLoaderTogether(t *testing.T) {
+	keys := []string{"SMTP_HOST", "SMTP_PORT", "SMTP_FROM"}
+	tests := []struct {
+		name    string
+		set     map[string]string
+		wantErr string
+	}{
+		{"none set", nil, ""},
+		{"all set", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "587", "SMTP_FROM": "coach@example.com"}, ""},
+		{"some set", map[string]string{"SMTP_HOST": "smtp.example.com"}, "must be set together (missing SMTP_PORT, SMTP_FROM)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range keys {
+				t.Setenv(key, "")
+			}
+			l := &configLoader{file: tt.set, filePath: "config.yaml"}
+			l.together(keys...)
+			problems := strings.Join(l.problems, "\n")
+			if tt.wantErr == "" && problems != "" {
+				t.Errorf("unexpected problems: %s", problems)
+			}
+			if tt.wantErr != "" && !strings.Contains(problems, tt.wantErr) {
+				t.Errorf("problems = %q, want one containing %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigLoaderRequireBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"nothing needs it", Config{}, ""},
+		{"set", Config{PublicBaseURL: "https://coach.example.com", EmailVerification: true, PaymentProvider: "stripe"}, ""},
+		{"email verification", Config{EmailVerification: true}, "PUBLIC_BASE_URL: required by BOOKING_EMAIL_VERIFICATION"},
+		{"waitlist emails", Config{SMTP: SMTPConfig{Host: "smtp.example.com"}}, "PUBLIC_BASE_URL: required by SMTP_HOST (waitlist claim links)"},
+		{"payments", Config{PaymentProvider: "stripe"}, "PUBLIC_BASE_URL: required by PAYMENT_PROVIDER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &configLoader{}
+			l.requireBaseURL(&tt.cfg)
+			problems := strings.Join(l.problems, "\n")
+			if tt.wantErr == "" && problems != "" {
+				t.Errorf("unexpected problems: %s", problems)
+			}
+			if tt.wantErr != "" && !strings.Contains(problems, tt.wantErr) {
+				t.Errorf("problems = %q, want one containing %q", problems, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/smtp"
-	"os"
 	"strings"
 	"time"
+
+	"coach-calendar-app/handlers"
 )
 
 type EmailService struct {
@@ -18,38 +22,53 @@ type EmailService struct {
 	FromName string
 	Password string
 	Enabled  bool
+	Timeout  time.Duration // bounds each send, from dialing the server to QUIT
 }
 
-func NewEmailService() *EmailService {
-	// SMTP Configuration
-	host := os.Getenv("SMTP_HOST")
-	port := os.Getenv("SMTP_PORT")
-	from := os.Getenv("SMTP_FROM")
-	password := os.Getenv("SMTP_PASSWORD")
-
-	// User-friendly "From" name with default
-	fromName := os.Getenv("SMTP_USER_FRIENDLY_FROM")
-	if fromName == "" {
-		fromName = "Христина Івасюк"
-	}
+// defaultSMTPTimeout bounds an SMTP send when SMTP_TIMEOUT is unset
+const defaultSMTPTimeout = 15 * time.Second
+
+// defaultFromName is the sender name shown when SMTP_USER_FRIENDLY_FROM is unset
+const defaultFromName = "Христина Івасюк"
+
+// SMTPConfig holds the SMTP_* settings
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	From     string
+	FromName string // user-friendly "From" name
+	Password string
+	Timeout  time.Duration
+}
 
-	enabled := host != "" && port != "" && from != "" && password != ""
+func NewEmailService(cfg SMTPConfig) *EmailService {
+	enabled := cfg.Host != "" && cfg.Port != "" && cfg.From != "" && cfg.Password != ""
 
 	if !enabled {
-		log.Println("Email service disabled - SMTP configuration not found")
-		log.Println("To enable SMTP email confirmations, set: SMTP_HOST, SMTP_PORT, SMTP_FROM, SMTP_PASSWORD")
+		slog.Info("Email service disabled - SMTP configuration not found")
+		slog.Info("To enable SMTP email confirmations, set: SMTP_HOST, SMTP_PORT, SMTP_FROM, SMTP_PASSWORD")
 	} else {
-		log.Printf("Email service enabled using SMTP (from: %s <%s>)", fromName, from)
+		slog.Info("Email service enabled using SMTP", "from_name", cfg.FromName, "from", cfg.From)
 	}
 
 	return &EmailService{
-		SMTPHost: host,
-		SMTPPort: port,
-		From:     from,
-		FromName: fromName,
-		Password: password,
+		SMTPHost: cfg.Host,
+		SMTPPort: cfg.Port,
+		From:     cfg.From,
+		FromName: cfg.FromName,
+		Password: cfg.Password,
 		Enabled:  enabled,
+		Timeout:  cfg.Timeout,
+	}
+}
+
+// ConfigStatus reports whether SMTP is configured, for the readiness probe. /readyz is
+// public, so the detail leaves out the SMTP host.
+func (e *EmailService) ConfigStatus() handlers.ComponentStatus {
+	if !e.Enabled {
+		return handlers.ComponentStatus{Status: handlers.ComponentDisabled, Detail: "SMTP configuration not found"}
 	}
+	return handlers.ComponentStatus{Status: handlers.ComponentOK, Detail: "SMTP configured"}
 }
 
 // getZoomSection returns the HTML for the Zoom meeting section, or empty string if no link
@@ -70,9 +89,20 @@ func getZoomSection(zoomLink string) string {
             </div>`, zoomLink)
 }
 
+// defaultSessionDuration is the length assumed for invites when no duration is known
+const defaultSessionDuration = 30 * time.Minute
+
+// sessionEnd returns when a session starting at slotTime ends
+func sessionEnd(slotTime time.Time, duration time.Duration) time.Time {
+	if duration <= 0 {
+		duration = defaultSessionDuration
+	}
+	return slotTime.Add(duration)
+}
+
 // generateGoogleCalendarURL creates a Google Calendar event URL
-func generateGoogleCalendarURL(slotTime time.Time) string {
-	endTime := slotTime.Add(30 * time.Minute)
+func generateGoogleCalendarURL(slotTime time.Time, duration time.Duration) string {
+	endTime := sessionEnd(slotTime, duration)
 	startUTC := slotTime.UTC().Format("20060102T150405Z")
 	endUTC := endTime.UTC().Format("20060102T150405Z")
 	url := fmt.Sprintf("https://calendar.google.com/calendar/render?action=TEMPLATE&text=%s&dates=%s/%s",
@@ -83,18 +113,21 @@ func generateGoogleCalendarURL(slotTime time.Time) string {
 	return url
 }
 
-// generateICalendar creates an iCalendar (ICS) format string for the appointment
-func generateICalendar(name, email string, slotTime time.Time) string {
-	// Calculate end time (30 minutes after start)
-	endTime := slotTime.Add(30 * time.Minute)
+// generateICalendar creates an iCalendar (ICS) format string for the appointment. Invites for
+// the same booking share uid; sequence increases each time the event changes so calendar
+// apps replace the earlier version. An empty uid generates a one-off ID.
+func generateICalendar(name, email string, slotTime time.Time, duration time.Duration, uid string, sequence int) string {
+	endTime := sessionEnd(slotTime, duration)
 
 	// Format times in iCalendar format (YYYYMMDDTHHMMSSZ in UTC)
 	startUTC := slotTime.UTC().Format("20060102T150405Z")
 	endUTC := endTime.UTC().Format("20060102T150405Z")
 	now := time.Now().UTC().Format("20060102T150405Z")
 
-	// Generate a unique ID for the event
-	eventID := fmt.Sprintf("%d@coach-calendar.com", time.Now().UnixNano())
+	eventID := uid
+	if eventID == "" {
+		eventID = fmt.Sprintf("%d@coach-calendar.com", time.Now().UnixNano())
+	}
 
 	// Create iCalendar content
 	ical := fmt.Sprintf(`BEGIN:VCALENDAR
@@ -111,32 +144,75 @@ SUMMARY:Онлайн консультація з %s
 DESCRIPTION:Your coaching appointment has been confirmed.\n\nClient: %s\nEmail: %s
 LOCATION:Online/TBD
 STATUS:CONFIRMED
-SEQUENCE:0
+SEQUENCE:%d
 BEGIN:VALARM
 TRIGGER:-PT15M
 ACTION:DISPLAY
 DESCRIPTION:Reminder: Онлайн консультація з %s починається через 15 хвилин
 END:VALARM
 END:VEVENT
-END:VCALENDAR`, eventID, now, startUTC, endUTC, name, name, email)
+END:VCALENDAR`, eventID, now, startUTC, endUTC, name, name, email, sequence, name)
 
 	return ical
 }
 
-func (e *EmailService) SendBookingConfirmation(name, email string, slotTime time.Time, zoomLink string) error {
+// confirmationWording is the part of the confirmation email that depends on who made the booking
+// and whether the session is paid
+type confirmationWording struct {
+	subject   string
+	session   string
+	htmlIntro string
+	textIntro string
+}
+
+// sessionTitle names the session in confirmation emails
+func sessionTitle(paid bool) string {
+	if paid {
+		return "Консультація з Христиною Івасюк"
+	}
+	return "Безкоштовна консультація з Христиною Івасюк"
+}
+
+// SendBookingConfirmation confirms a booking the client made themselves
+func (e *EmailService) SendBookingConfirmation(ctx context.Context, name, email string, slotTime time.Time, duration time.Duration, paid bool, zoomLink, calendarUID string) error {
+	subject := "Підтвердження онлайн-запису - безкоштовна консультація з Христиною Івасюк"
+	if paid {
+		subject = "Підтвердження онлайн-запису - консультація з Христиною Івасюк"
+	}
+	return e.sendConfirmation(ctx, name, email, slotTime, duration, zoomLink, calendarUID, confirmationWording{
+		subject:   subject,
+		session:   sessionTitle(paid),
+		htmlIntro: "Дякуємо за бронювання зустрічі!",
+		textIntro: "Дякую за бронювання зустрічі!",
+	})
+}
+
+// SendAdminBookingConfirmation confirms a booking the coach made on the client's behalf,
+// e.g. after a phone call
+func (e *EmailService) SendAdminBookingConfirmation(ctx context.Context, name, email string, slotTime time.Time, duration time.Duration, paid bool, zoomLink, calendarUID string) error {
+	return e.sendConfirmation(ctx, name, email, slotTime, duration, zoomLink, calendarUID, confirmationWording{
+		subject:   "Вас записано на зустріч з Христиною Івасюк",
+		session:   sessionTitle(paid),
+		htmlIntro: "Як ми домовилися, я записала вас на зустріч.",
+		textIntro: "Як ми домовилися, я записала вас на зустріч.",
+	})
+}
+
+func (e *EmailService) sendConfirmation(ctx context.Context, name, email string, slotTime time.Time, duration time.Duration, zoomLink, calendarUID string, wording confirmationWording) error {
 	if !e.Enabled {
-		log.Printf("Email service disabled - skipping confirmation email to %s", email)
+		slog.DebugContext(ctx, "Email service disabled - skipping confirmation email", "to", email)
 		return nil
 	}
 
 	// Format the booking time
 	formattedTime := slotTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
+	minutes := int(sessionEnd(slotTime, duration).Sub(slotTime).Minutes())
 
 	// Generate Google Calendar URL
-	googleCalURL := generateGoogleCalendarURL(slotTime)
+	googleCalURL := generateGoogleCalendarURL(slotTime, duration)
 
 	// Create email subject and body
-	subject := "Підтвердження онлайн-запису - безкоштовна консультація з Христиною Івасюк"
+	subject := wording.subject
 
 	// HTML body
 	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
@@ -160,16 +236,16 @@ func (e *EmailService) SendBookingConfirmation(name, email string, slotTime time
     <div class="container">
         <div class="header">
             <h1>Підтвердження онлайн-запису</h1>
-            <p>Безкоштовна консультація з Христиною Івасюк</p>
+            <p>%s</p>
         </div>
         <div class="content">
             <p>Вітаємо, <strong>%s</strong>!</p>
-            <p>Дякуємо за бронювання зустрічі!</p>
+            <p>%s</p>
 
             <div class="details">
                 <h3>Деталі зустрічі:</h3>
                 <div class="detail-row">📅 <strong>Дата і час:</strong> %s</div>
-                <div class="detail-row">⏱️ <strong>Тривалість:</strong> 30 хвилин</div>
+                <div class="detail-row">⏱️ <strong>Тривалість:</strong> %d хвилин</div>
                 <div class="detail-row">👤 <strong>Ім'я:</strong> %s</div>
                 <div class="detail-row">📧 <strong>Email:</strong> %s</div>
             </div>
@@ -200,7 +276,7 @@ func (e *EmailService) SendBookingConfirmation(name, email string, slotTime time
         </div>
     </div>
 </body>
-</html>`, name, formattedTime, name, email, getZoomSection(zoomLink), googleCalURL)
+</html>`, wording.session, name, wording.htmlIntro, formattedTime, minutes, name, email, getZoomSection(zoomLink), googleCalURL)
 
 	// Plain text fallback
 	zoomText := ""
@@ -214,12 +290,12 @@ func (e *EmailService) SendBookingConfirmation(name, email string, slotTime time
 
 	textBody := fmt.Sprintf(`Вітаємо, %s!
 
-Дякую за бронювання зустрічі!
+%s
 
 Деталі зустрічі:
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 📅 Дата і час: %s
-⏱️ Тривалість: 30 хвилин
+⏱️ Тривалість: %d хвилин
 👤 Ім'я: %s
 📧 Email: %s
 %s
@@ -237,16 +313,277 @@ func (e *EmailService) SendBookingConfirmation(name, email string, slotTime time
 
 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
-`, name, formattedTime, name, email, zoomText, googleCalURL)
+`, name, wording.textIntro, formattedTime, minutes, name, email, zoomText, googleCalURL)
 
 	// Generate iCalendar attachment
-	icalContent := generateICalendar(name, email, slotTime)
+	icalContent := generateICalendar(name, email, slotTime, duration, calendarUID, 0)
 
 	// Send via SMTP
-	return e.sendViaSMTP(email, subject, htmlBody, textBody, icalContent)
+	return e.sendViaSMTP(ctx, email, subject, htmlBody, textBody, icalContent)
+}
+
+// SendBookingVerification emails the link a client must click to confirm a pending booking
+func (e *EmailService) SendBookingVerification(ctx context.Context, name, email string, slotTime time.Time, confirmURL string, expiresAt time.Time) error {
+	if !e.Enabled {
+		slog.DebugContext(ctx, "Email service disabled - skipping verification email", "to", email)
+		return nil
+	}
+
+	formattedTime := slotTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
+	formattedExpiry := expiresAt.In(slotTime.Location()).Format("15:04 MST")
+
+	subject := "Підтвердіть ваш запис на консультацію з Христиною Івасюк"
+
+	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #800020 0%%, #5c0011 100%%); color: white; padding: 30px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 8px 8px; }
+        .details { background: white; padding: 20px; border-left: 4px solid #800020; margin: 20px 0; }
+        .calendar-section { background: white; padding: 20px; margin: 20px 0; text-align: center; border-radius: 8px; }
+        .footer { text-align: center; color: #666; font-size: 12px; margin-top: 20px; padding-top: 20px; border-top: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Підтвердіть запис</h1>
+            <p>Безкоштовна консультація з Христиною Івасюк</p>
+        </div>
+        <div class="content">
+            <p>Вітаємо, <strong>%s</strong>!</p>
+            <p>Ми отримали запит на бронювання зустрічі на цю email адресу.</p>
+
+            <div class="details">
+                <div>📅 <strong>Дата і час:</strong> %s</div>
+            </div>
+
+            <div class="calendar-section">
+                <p>
+                    <a href="%s" target="_blank" style="display: inline-block; padding: 12px 24px; background: #800020; color: #ffffff !important; text-decoration: none; border-radius: 6px; margin: 10px;">Підтвердити бронювання</a>
+                </p>
+                <p style="font-size: 14px; color: #666;">
+                    Слот зарезервовано до %s. Якщо ви не підтвердите запис до цього часу, бронювання буде скасовано.
+                </p>
+            </div>
+
+            <p>Якщо ви не робили цього запиту, просто проігноруйте цей лист.</p>
+        </div>
+        <div class="footer">
+            Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+        </div>
+    </div>
+</body>
+</html>`, name, formattedTime, confirmURL, formattedExpiry)
+
+	textBody := fmt.Sprintf(`Вітаємо, %s!
+
+Ми отримали запит на бронювання зустрічі на цю email адресу.
+
+📅 Дата і час: %s
+
+Щоб підтвердити бронювання, перейдіть за посиланням:
+%s
+
+Слот зарезервовано до %s. Якщо ви не підтвердите запис до цього часу, бронювання буде скасовано.
+
+Якщо ви не робили цього запиту, просто проігноруйте цей лист.
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+`, name, formattedTime, confirmURL, formattedExpiry)
+
+	return e.sendViaSMTP(ctx, email, subject, htmlBody, textBody, "")
+}
+
+// SendWaitlistOffer tells a waitlisted client a slot in one of their windows has freed up.
+// The slot is held for them until expiresAt; the claim link confirms the booking.
+func (e *EmailService) SendWaitlistOffer(ctx context.Context, name, email string, slotTime time.Time, claimURL string, expiresAt time.Time) error {
+	if !e.Enabled {
+		slog.DebugContext(ctx, "Email service disabled - skipping waitlist offer email", "to", email)
+		return nil
+	}
+
+	formattedTime := slotTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
+	formattedExpiry := expiresAt.In(slotTime.Location()).Format("January 2, 15:04 MST")
+
+	subject := "Звільнився час для консультації з Христиною Івасюк"
+
+	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #800020 0%%, #5c0011 100%%); color: white; padding: 30px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 8px 8px; }
+        .details { background: white; padding: 20px; border-left: 4px solid #800020; margin: 20px 0; }
+        .calendar-section { background: white; padding: 20px; margin: 20px 0; text-align: center; border-radius: 8px; }
+        .footer { text-align: center; color: #666; font-size: 12px; margin-top: 20px; padding-top: 20px; border-top: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Звільнився час</h1>
+            <p>Безкоштовна консультація з Христиною Івасюк</p>
+        </div>
+        <div class="content">
+            <p>Вітаємо, <strong>%s</strong>!</p>
+            <p>Ви у списку очікування, і звільнився час, який вам підходить.</p>
+
+            <div class="details">
+                <div>📅 <strong>Дата і час:</strong> %s</div>
+            </div>
+
+            <div class="calendar-section">
+                <p>
+                    <a href="%s" target="_blank" style="display: inline-block; padding: 12px 24px; background: #800020; color: #ffffff !important; text-decoration: none; border-radius: 6px; margin: 10px;">Забронювати цей час</a>
+                </p>
+                <p style="font-size: 14px; color: #666;">
+                    Ми тримаємо цей час для вас до %s. Після цього його буде запропоновано наступному у списку очікування.
+                </p>
+            </div>
+
+            <p>Якщо цей час вам не підходить, просто проігноруйте цей лист.</p>
+        </div>
+        <div class="footer">
+            Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+        </div>
+    </div>
+</body>
+</html>`, name, formattedTime, claimURL, formattedExpiry)
+
+	textBody := fmt.Sprintf(`Вітаємо, %s!
+
+Ви у списку очікування, і звільнився час, який вам підходить.
+
+📅 Дата і час: %s
+
+Щоб забронювати цей час, перейдіть за посиланням:
+%s
+
+Ми тримаємо цей час для вас до %s. Після цього його буде запропоновано наступному у списку очікування.
+
+Якщо цей час вам не підходить, просто проігноруйте цей лист.
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+`, name, formattedTime, claimURL, formattedExpiry)
+
+	return e.sendViaSMTP(ctx, email, subject, htmlBody, textBody, "")
 }
 
-func (e *EmailService) sendViaSMTP(toEmail, subject, htmlBody, textBody, icalContent string) error {
+// SendBookingRescheduled tells the client their session has moved. The attached invite
+// reuses the booking's calendar UID with a higher sequence so it updates the existing event.
+func (e *EmailService) SendBookingRescheduled(ctx context.Context, name, email string, oldTime, newTime time.Time, duration time.Duration, zoomLink, calendarUID string, sequence int) error {
+	if !e.Enabled {
+		slog.DebugContext(ctx, "Email service disabled - skipping reschedule email", "to", email)
+		return nil
+	}
+
+	formattedOld := oldTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
+	formattedNew := newTime.Format("Monday, January 2, 2006 at 3:04 PM MST")
+	googleCalURL := generateGoogleCalendarURL(newTime, duration)
+
+	subject := "Зміна часу зустрічі з Христиною Івасюк"
+
+	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: linear-gradient(135deg, #800020 0%%, #5c0011 100%%); color: white; padding: 30px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 8px 8px; }
+        .details { background: white; padding: 20px; border-left: 4px solid #800020; margin: 20px 0; }
+        .detail-row { margin: 10px 0; }
+        .calendar-section { background: white; padding: 20px; margin: 20px 0; text-align: center; border-radius: 8px; }
+        .footer { text-align: center; color: #666; font-size: 12px; margin-top: 20px; padding-top: 20px; border-top: 1px solid #ddd; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Час зустрічі змінено</h1>
+            <p>Консультація з Христиною Івасюк</p>
+        </div>
+        <div class="content">
+            <p>Вітаємо, <strong>%s</strong>!</p>
+            <p>Час вашої зустрічі змінено.</p>
+
+            <div class="details">
+                <div class="detail-row">❌ <strong>Попередній час:</strong> <s>%s</s></div>
+                <div class="detail-row">📅 <strong>Новий час:</strong> %s</div>
+            </div>
+
+            %s
+
+            <div class="calendar-section">
+                <p>
+                    <a href="%s" target="_blank" style="display: inline-block; padding: 12px 24px; background: #800020; color: #ffffff !important; text-decoration: none; border-radius: 6px; margin: 10px;">Додати в Google Calendar</a>
+                </p>
+                <p style="font-size: 14px; color: #666;">
+                    Прикріплений файл invite.ics оновить подію у вашому календарі
+                    <br>(Outlook, Apple Calendar тощо)
+                </p>
+            </div>
+
+            <p>Якщо новий час вам не підходить, будь ласка, зв'яжіться зі мною якнайшвидше.</p>
+
+            <p style="margin-top: 30px;">
+                З повагою,<br>
+                <strong>Христина Івасюк</strong>
+            </p>
+        </div>
+        <div class="footer">
+            Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+        </div>
+    </div>
+</body>
+</html>`, name, formattedOld, formattedNew, getZoomSection(zoomLink), googleCalURL)
+
+	zoomText := ""
+	if zoomLink != "" {
+		zoomText = fmt.Sprintf(`
+🎥 Онлайн зустріч Zoom:
+%s
+`, zoomLink)
+	}
+
+	textBody := fmt.Sprintf(`Вітаємо, %s!
+
+Час вашої зустрічі змінено.
+
+❌ Попередній час: %s
+📅 Новий час: %s
+%s
+📅 Додати до календаря:
+%s
+
+Прикріплений файл invite.ics оновить подію у вашому календарі.
+
+Якщо новий час вам не підходить, будь ласка, зв'яжіться зі мною якнайшвидше.
+
+З повагою,
+Христина Івасюк
+
+━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+Це автоматичне повідомлення. Будь ласка, не відповідайте на цей email.
+`, name, formattedOld, formattedNew, zoomText, googleCalURL)
+
+	icalContent := generateICalendar(name, email, newTime, duration, calendarUID, sequence)
+
+	return e.sendViaSMTP(ctx, email, subject, htmlBody, textBody, icalContent)
+}
+
+func (e *EmailService) sendViaSMTP(ctx context.Context, toEmail, subject, htmlBody, textBody, icalContent string) error {
 	// Create boundaries for multipart message
 	mixedBoundary := fmt.Sprintf("mixed_boundary_%d", rand.Int63())
 	altBoundary := fmt.Sprintf("alt_boundary_%d", rand.Int63())
@@ -287,14 +624,16 @@ func (e *EmailService) sendViaSMTP(toEmail, subject, htmlBody, textBody, icalCon
 	message.WriteString(fmt.Sprintf("--%s--\r\n", altBoundary))
 	message.WriteString("\r\n")
 
-	// Calendar attachment part
-	message.WriteString(fmt.Sprintf("--%s\r\n", mixedBoundary))
-	message.WriteString("Content-Type: text/calendar; charset=UTF-8; method=REQUEST; name=\"invite.ics\"\r\n")
-	message.WriteString("Content-Transfer-Encoding: base64\r\n")
-	message.WriteString("Content-Disposition: attachment; filename=\"invite.ics\"\r\n")
-	message.WriteString("\r\n")
-	message.WriteString(base64.StdEncoding.EncodeToString([]byte(icalContent)))
-	message.WriteString("\r\n")
+	// Calendar attachment part (omitted for emails without an invite)
+	if icalContent != "" {
+		message.WriteString(fmt.Sprintf("--%s\r\n", mixedBoundary))
+		message.WriteString("Content-Type: text/calendar; charset=UTF-8; method=REQUEST; name=\"invite.ics\"\r\n")
+		message.WriteString("Content-Transfer-Encoding: base64\r\n")
+		message.WriteString("Content-Disposition: attachment; filename=\"invite.ics\"\r\n")
+		message.WriteString("\r\n")
+		message.WriteString(base64.StdEncoding.EncodeToString([]byte(icalContent)))
+		message.WriteString("\r\n")
+	}
 	message.WriteString(fmt.Sprintf("--%s--\r\n", mixedBoundary))
 
 	// Set up authentication
@@ -302,12 +641,78 @@ func (e *EmailService) sendViaSMTP(toEmail, subject, htmlBody, textBody, icalCon
 
 	// Send the email
 	addr := fmt.Sprintf("%s:%s", e.SMTPHost, e.SMTPPort)
-	err := smtp.SendMail(addr, auth, e.From, []string{toEmail}, []byte(message.String()))
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultSMTPTimeout
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := sendMail(sendCtx, addr, e.SMTPHost, auth, e.From, toEmail, []byte(message.String()))
+	handlers.ObserveEmailSend(err, time.Since(start))
 	if err != nil {
-		log.Printf("Failed to send confirmation email via SMTP to %s: %v", toEmail, err)
-		return fmt.Errorf("failed to send confirmation email via SMTP: %w", err)
+		slog.ErrorContext(ctx, "Failed to send email via SMTP", "to", toEmail, "error", err)
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
 	}
 
-	log.Printf("Confirmation email sent successfully via SMTP to %s", toEmail)
+	slog.InfoContext(ctx, "Email sent via SMTP", "to", toEmail, "subject", subject,
+		"duration_ms", time.Since(start).Milliseconds())
 	return nil
 }
+
+// sendMail is smtp.SendMail with cancellation: the connection is closed when ctx is done,
+// so a server that stops responding cannot hold the request past its deadline
+func sendMail(ctx context.Context, addr, host string, auth smtp.Auth, from, to string, msg []byte) (err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		// Report the cancellation rather than the I/O error it caused
+		if !stop() && err != nil {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
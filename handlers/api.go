@@ -1,41 +1,78 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// EmailSender interface for sending emails
+// EmailSender interface for sending emails. The context carries the request ID into the
+// service's logs.
 type EmailSender interface {
-	SendBookingConfirmation(name, email string, slotTime time.Time, zoomLink string) error
+	SendBookingConfirmation(ctx context.Context, name, email string, slotTime time.Time, duration time.Duration, paid bool, zoomLink, calendarUID string) error
+	SendBookingVerification(ctx context.Context, name, email string, slotTime time.Time, confirmURL string, expiresAt time.Time) error
+	SendAdminBookingConfirmation(ctx context.Context, name, email string, slotTime time.Time, duration time.Duration, paid bool, zoomLink, calendarUID string) error
+	SendBookingRescheduled(ctx context.Context, name, email string, oldTime, newTime time.Time, duration time.Duration, zoomLink, calendarUID string, sequence int) error
+	SendWaitlistOffer(ctx context.Context, name, email string, slotTime time.Time, claimURL string, expiresAt time.Time) error
 }
 
-// ZoomMeetingCreator interface for creating and deleting Zoom meetings
+// ZoomMeetingCreator interface for creating, moving and deleting Zoom meetings
 type ZoomMeetingCreator interface {
-	CreateMeeting(name, email string, slotTime time.Time) (string, error)
-	DeleteMeeting(joinURL string) error
+	CreateMeeting(ctx context.Context, name, email string, slotTime time.Time) (string, error)
+	UpdateMeeting(ctx context.Context, joinURL string, slotTime time.Time) error
+	DeleteMeeting(ctx context.Context, joinURL string) error
+	// ScrubMeeting removes the client's name and email from a meeting's topic and agenda
+	ScrubMeeting(ctx context.Context, joinURL string) error
+}
+
+// CalendarUID is the iCalendar UID of a booking's event. It stays the same for the life of
+// the booking so calendar apps update the event instead of adding a new one.
+func CalendarUID(bookingID int) string {
+	return fmt.Sprintf("booking-%d@coach-calendar.com", bookingID)
 }
 
 // Re-export types from main package
 type Booking struct {
-	ID        int       `json:"id"`
-	SlotTime  time.Time `json:"slot_time"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	ZoomLink  string    `json:"zoom_link,omitempty"`
+	ID              int       `json:"id"`
+	SlotTime        time.Time `json:"slot_time"`
+	Name            string    `json:"name"`
+	Email           string    `json:"email"`
+	CreatedAt       time.Time `json:"created_at"`
+	DurationMinutes int       `json:"duration_minutes,omitempty"`
+	ZoomLink        string    `json:"zoom_link,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	SessionType     string    `json:"session_type,omitempty"`
+	ClientID        int       `json:"client_id,omitempty"`
+	Source          string    `json:"source,omitempty"`
+
+	// Set when the booking was paid with a package credit or discounted
+	PackageID      int `json:"package_id,omitempty"`
+	DiscountCodeID int `json:"discount_code_id,omitempty"`
+}
+
+// Duration is the length of the session, fixed from its session type when it was booked
+func (b *Booking) Duration() time.Duration {
+	return time.Duration(b.DurationMinutes) * time.Minute
 }
 
 type BookingRequest struct {
-	SlotTime string `json:"slot_time"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
+	SlotTime    string `json:"slot_time"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	SessionType string `json:"session_type,omitempty"`
+	Code        string `json:"code,omitempty"` // package redemption code or discount code
+
+	// Bot protection: Website is a honeypot hidden from humans, CaptchaToken comes from the CAPTCHA widget
+	Website      string `json:"website,omitempty"`
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type AvailableSlot struct {
@@ -44,10 +81,13 @@ type AvailableSlot struct {
 }
 
 type AdminSlot struct {
-	SlotTime string `json:"slot_time"`
-	Status   string `json:"status"` // "available", "booked", "blocked"
-	Name     string `json:"name,omitempty"`
-	Email    string `json:"email,omitempty"`
+	SlotTime      string `json:"slot_time"`
+	Status        string `json:"status"` // "available", "booked", "pending", "blocked", "buffer"
+	BookingID     int    `json:"booking_id,omitempty"`
+	BookingStatus string `json:"booking_status,omitempty"`
+	ClientID      int    `json:"client_id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
 }
 
 type GenerateSlotsFn func() []AvailableSlot
@@ -57,15 +97,59 @@ type APIHandlers struct {
 	GenerateAvailableSlots GenerateSlotsFn
 	EmailService           EmailSender
 	ZoomService            ZoomMeetingCreator
+	Policy                 *BookingPolicy
+	RateLimiter            *RateLimiter
+	Captcha                CaptchaVerifier
+	Verification           *EmailVerification
+	BulkUndoWindow         time.Duration
+	Notes                  *NoteCipher // nil disables session notes
+	PublicBaseURL          string      // public URL used in emailed links and payment redirects
+	WaitlistClaimWindow    time.Duration
+	Payments               PaymentProvider // nil disables paid session types
+	PaymentHold            time.Duration
+	RetentionMonths        int             // past bookings older than this are anonymized; 0 keeps them
+	SchemaVersion          string          // version of the schema applied at startup, checked by Readiness
+	ReadinessTimeout       time.Duration   // bounds the database checks of Readiness
+	DBTimeout              time.Duration   // bounds each unit of database work; 0 uses DefaultDBTimeout
+	Flags                  *FeatureFlags   // runtime switches edited from /admin
+	Background             *sync.WaitGroup // joined by work that outlives its request; waited for at shutdown
 }
 
+// DefaultDBTimeout bounds the database work of a request or job step when DBTimeout is unset
+const DefaultDBTimeout = 5 * time.Second
+
 func NewAPIHandlers(db *sql.DB, generateSlotsFn GenerateSlotsFn, emailService EmailSender, zoomService ZoomMeetingCreator) *APIHandlers {
 	return &APIHandlers{
 		DB:                     db,
 		GenerateAvailableSlots: generateSlotsFn,
 		EmailService:           emailService,
 		ZoomService:            zoomService,
+		Flags:                  NewFeatureFlags(db, nil),
+	}
+}
+
+// runInBackground runs fn in its own goroutine, counted in Background when it is set
+func (h *APIHandlers) runInBackground(fn func()) {
+	if h.Background != nil {
+		h.Background.Add(1)
 	}
+	go func() {
+		if h.Background != nil {
+			defer h.Background.Done()
+		}
+		fn()
+	}()
+}
+
+// dbContext derives the context for one unit of database work from ctx: it is cancelled
+// when the client disconnects and expires after DBTimeout. Zoom, SMTP and payment calls
+// take ctx itself, since they have deadlines of their own.
+func (h *APIHandlers) dbContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := h.DBTimeout
+	if timeout <= 0 {
+		timeout = DefaultDBTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 func (h *APIHandlers) GetSlots(w http.ResponseWriter, r *http.Request) {
@@ -74,33 +158,50 @@ func (h *APIHandlers) GetSlots(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only 30-minute slots are available
+	// While bookings are closed clients see no slots
+	if !h.Flags.Enabled(r.Context(), FlagBookingOpen) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]AvailableSlot{})
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	// Session starts lie on the 30-minute grid; the session type decides how long each one runs
 	slots := h.GenerateAvailableSlots()
 
-	// Get booked slots from database
-	rows, err := h.DB.Query("SELECT slot_time FROM bookings")
+	sessionTypes, err := h.loadSessionTypes(ctx)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error querying bookings: %v", err)
+		slog.ErrorContext(r.Context(), "Error querying session types", "error", err)
 		return
 	}
-	defer rows.Close()
 
-	bookedSlots := make(map[int64]bool)
-	for rows.Next() {
-		var slotTime time.Time
-		if err := rows.Scan(&slotTime); err != nil {
-			continue
-		}
-		// Use Unix timestamp for timezone-independent comparison
-		bookedSlots[slotTime.Unix()] = true
+	typeKey := r.URL.Query().Get("session_type")
+	if typeKey == "" {
+		typeKey = DefaultSessionType
+	}
+	slotType, ok := sessionTypes[typeKey]
+	if !ok {
+		http.Error(w, "Unknown session type", http.StatusBadRequest)
+		return
+	}
+
+	// Get booked sessions from database
+	from, to := sessionWindow(slots)
+	sessions, err := h.loadSessions(ctx, sessionTypes, from, to)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+		return
 	}
 
 	// Get blocked slots from database
-	blockedRows, err := h.DB.Query("SELECT slot_time FROM blocked_slots")
+	blockedRows, err := h.DB.QueryContext(ctx, "SELECT slot_time FROM blocked_slots")
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error querying blocked slots: %v", err)
+		slog.ErrorContext(r.Context(), "Error querying blocked slots", "error", err)
 		return
 	}
 	defer blockedRows.Close()
@@ -115,21 +216,34 @@ func (h *APIHandlers) GetSlots(w http.ResponseWriter, r *http.Request) {
 		blockedSlots[slotTime.Unix()] = true
 	}
 
-	// Mark booked and blocked slots as unavailable
-	for i := range slots {
+	// Tally booked sessions per day and week for the booking caps
+	counter := newSlotCounter(h.Policy)
+	for _, session := range sessions {
+		counter.add(session.Start)
+	}
+
+	// Mark booked, buffered and blocked slots as unavailable, hide slots the booking policy rejects
+	now := time.Now()
+	visibleSlots := make([]AvailableSlot, 0, len(slots))
+	for _, slot := range slots {
 		// Parse slot time to compare as Unix timestamp
-		slotTime, err := time.Parse(time.RFC3339, slots[i].SlotTime)
+		slotTime, err := time.Parse(time.RFC3339, slot.SlotTime)
 		if err != nil {
 			continue
 		}
-		unixTime := slotTime.Unix()
-		if bookedSlots[unixTime] || blockedSlots[unixTime] {
-			slots[i].Available = false
+		if h.Policy.CheckWindow(slotTime, now) != nil {
+			continue
+		}
+		if blockedSlots[slotTime.Unix()] || slotOccupancy(slotTime, slotType, sessions) != slotFree {
+			slot.Available = false
+		} else if counter.check(slotTime) != nil {
+			continue
 		}
+		visibleSlots = append(visibleSlots, slot)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(slots)
+	json.NewEncoder(w).Encode(visibleSlots)
 }
 
 func (h *APIHandlers) CreateBooking(w http.ResponseWriter, r *http.Request) {
@@ -138,17 +252,56 @@ func (h *APIHandlers) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Each database step below bounds itself with dbContext; Zoom and SMTP have their own timeouts
+	ctx := r.Context()
+
+	if !h.Flags.Enabled(ctx, FlagBookingOpen) {
+		http.Error(w, "Booking is currently closed", http.StatusForbidden)
+		return
+	}
+
+	ip := clientIP(r)
+	if allowed, retryAfter := h.RateLimiter.AllowIP(ctx, ip); !allowed {
+		slog.WarnContext(r.Context(), "Rate limit exceeded for IP", "ip", ip)
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
 	var req BookingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// Bots fill in every field; pretend success so they don't adapt
+	if req.Website != "" {
+		slog.WarnContext(r.Context(), "Honeypot triggered, ignoring booking request", "ip", ip)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Booking created successfully",
+		})
+		return
+	}
+
 	if req.Name == "" || req.Email == "" || req.SlotTime == "" {
 		http.Error(w, "Name, email, and slot_time are required", http.StatusBadRequest)
 		return
 	}
 
+	if h.Captcha != nil {
+		if err := h.Captcha.Verify(r.Context(), req.CaptchaToken, ip); err != nil {
+			http.Error(w, "CAPTCHA verification failed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if allowed, retryAfter := h.RateLimiter.AllowEmail(ctx, req.Email); !allowed {
+		slog.WarnContext(r.Context(), "Rate limit exceeded for email", "ip", ip)
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
 	slotTime, err := time.Parse(time.RFC3339, req.SlotTime)
 	if err != nil {
 		http.Error(w, "Invalid slot_time format", http.StatusBadRequest)
@@ -161,44 +314,105 @@ func (h *APIHandlers) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apply minimum notice and advance booking window
+	if err := h.Policy.CheckWindow(slotTime, time.Now()); err != nil {
+		http.Error(w, "Slot is not open for booking: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Apply daily and weekly session caps
+	if err := h.checkBookingCaps(ctx, slotTime); err != nil {
+		if errors.Is(err, ErrDailyLimitReached) || errors.Is(err, ErrWeeklyLimitReached) {
+			slotConflict(w, conflictLimit, "Slot is not open for booking: "+err.Error())
+		} else {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error counting bookings", "error", err)
+		}
+		return
+	}
+
+	// Reject slots overlapping another session or its buffer
+	sessionTypes, err := h.loadSessionTypes(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying session types", "error", err)
+		return
+	}
+	if req.SessionType == "" {
+		req.SessionType = DefaultSessionType
+	}
+	slotType, ok := sessionTypes[req.SessionType]
+	if !ok {
+		http.Error(w, "Unknown session type", http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := h.loadSessions(ctx, sessionTypes, slotTime.AddDate(0, 0, -1), slotTime.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+		return
+	}
+	switch slotOccupancy(slotTime, slotType, sessions) {
+	case slotOverlapsSession:
+		slotConflict(w, conflictBooked, "Slot already booked")
+		return
+	case slotInBuffer:
+		slotConflict(w, conflictBuffer, "Slot is too close to another session")
+		return
+	}
+	// Checked again inside the insert transaction in case another booking lands first
+	guard := &slotGuard{slotTime: slotTime, slotType: slotType, types: sessionTypes, caps: true}
+
 	// Convert to UTC for consistent storage
 	slotTimeUTC := slotTime.UTC()
 
-	// Create Zoom meeting first (before database insert) if enabled
-	var zoomLink string
-	createZoomMeeting := strings.ToLower(os.Getenv("CREATE_ZOOM_MEETING"))
-	if (createZoomMeeting == "yes" || createZoomMeeting == "true") && h.ZoomService != nil {
-		zoomLink, err = h.ZoomService.CreateMeeting(req.Name, req.Email, slotTime)
-		if err != nil {
-			// Log the error but don't fail the booking
-			log.Printf("Warning: Failed to create Zoom meeting: %v", err)
+	// Free the slot if it is only held by an expired, unconfirmed booking
+	if _, err := h.ExpirePendingHolds(ctx); err != nil {
+		slog.WarnContext(r.Context(), "Failed to clear expired holds", "error", err)
+	}
+
+	// A package code makes the session free; a discount code lowers its price
+	redemption, err := h.resolveCode(ctx, req.Code, slotType, req.Email)
+	if err != nil {
+		if !codeError(w, err) {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error checking booking code", "error", err)
 		}
+		return
+	}
+
+	// Paid sessions are confirmed by the payment, which also proves the email address
+	if redemption.PriceCents > 0 {
+		h.createPaymentHold(w, r, req, guard, redemption)
+		return
 	}
 
+	if h.Verification != nil {
+		h.createPendingBooking(w, r, req, guard, redemption)
+		return
+	}
+
+	// Create Zoom meeting first (before database insert) if enabled
+	zoomLink := h.createZoomMeeting(r.Context(), req.Name, req.Email, slotTime)
+
 	// Insert booking into database with zoom_link (store in UTC)
-	_, err = h.DB.Exec(
-		"INSERT INTO bookings (slot_time, name, email, zoom_link) VALUES ($1, $2, $3, $4)",
+	id, err := h.insertRedeemedBooking(ctx, auditorFromRequest(r), AuditBookingCreated, guard, redemption,
+		`INSERT INTO bookings (slot_time, name, email, zoom_link, duration, session_type, status, confirmed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'confirmed', CURRENT_TIMESTAMP)`,
 		slotTimeUTC, req.Name, req.Email, sql.NullString{String: zoomLink, Valid: zoomLink != ""},
+		slotType.DurationMinutes, slotType.Key,
 	)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value") || strings.Contains(err.Error(), "unique constraint") {
-			http.Error(w, "Slot already booked", http.StatusConflict)
-		} else {
+		h.deleteZoomMeeting(r.Context(), zoomLink)
+		if !slotTakenError(w, err) && !codeError(w, err) {
 			http.Error(w, "Failed to create booking", http.StatusInternalServerError)
-			log.Printf("Error creating booking: %v", err)
+			slog.ErrorContext(r.Context(), "Error creating booking", "error", err)
 		}
 		return
 	}
 
-	// Send confirmation email if enabled
-	sendConfirmationEmail := strings.ToLower(os.Getenv("SEND_CONFIRMATION_EMAIL"))
-	if (sendConfirmationEmail == "yes" || sendConfirmationEmail == "true") && h.EmailService != nil {
-		err = h.EmailService.SendBookingConfirmation(req.Name, req.Email, slotTime, zoomLink)
-		if err != nil {
-			// Log the error but don't fail the booking
-			log.Printf("Warning: Booking created but failed to send confirmation email: %v", err)
-		}
-	}
+	h.sendConfirmationEmail(r.Context(), id, req.Name, req.Email, slotTime, slotType.Duration(), slotType.Paid(), zoomLink)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -207,45 +421,211 @@ func (h *APIHandlers) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// createPendingBooking holds the slot for the verification window and emails a confirmation link
+func (h *APIHandlers) createPendingBooking(w http.ResponseWriter, r *http.Request, req BookingRequest, guard *slotGuard, redemption *codeRedemption) {
+	slotType, slotTime := guard.slotType, guard.slotTime
+	token, err := newToken()
+	if err != nil {
+		http.Error(w, "Failed to create booking", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error generating confirmation token", "error", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.Verification.Hold)
+	_, err = h.insertRedeemedBooking(r.Context(), auditorFromRequest(r), AuditBookingHeld, guard, redemption,
+		`INSERT INTO bookings (slot_time, name, email, duration, session_type, status, confirmation_token, hold_expires_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $7)`,
+		slotTime.UTC(), req.Name, req.Email, slotType.DurationMinutes, slotType.Key, token, expiresAt.UTC(),
+	)
+	if err != nil {
+		if !slotTakenError(w, err) && !codeError(w, err) {
+			http.Error(w, "Failed to create booking", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating pending booking", "error", err)
+		}
+		return
+	}
+
+	if h.EmailService != nil {
+		confirmURL := h.Verification.confirmationURL(token)
+		// The hold is committed; send the link even if the client has disconnected
+		if err := h.EmailService.SendBookingVerification(context.WithoutCancel(r.Context()), req.Name, req.Email, slotTime, confirmURL, expiresAt); err != nil {
+			slog.WarnContext(r.Context(), "Pending booking created but failed to send verification email", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":         "Booking pending email confirmation",
+		"status":          "pending",
+		"hold_expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// insertBooking runs an INSERT into bookings, links the booking to its client and records
+// the audit event in the same transaction, returning the new booking ID. A non-nil guard
+// re-checks the slot in that transaction first.
+func (h *APIHandlers) insertBooking(ctx context.Context, audit auditor, action string, guard *slotGuard, query string, args ...interface{}) (int, error) {
+	return h.insertRedeemedBooking(ctx, audit, action, guard, nil, query, args...)
+}
+
+// insertRedeemedBooking is insertBooking that also takes one use of a package or discount
+// code in the booking's transaction, so a used-up code leaves no booking behind
+func (h *APIHandlers) insertRedeemedBooking(ctx context.Context, audit auditor, action string, guard *slotGuard, redemption *codeRedemption, query string, args ...interface{}) (int, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := guard.check(ctx, tx, h.Policy); err != nil {
+		return 0, err
+	}
+
+	var id int
+	if err := tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id); err != nil {
+		return 0, err
+	}
+	if _, err := linkBookingClient(ctx, tx, id); err != nil {
+		return 0, err
+	}
+	if err := redemption.redeem(ctx, tx, audit, id); err != nil {
+		return 0, err
+	}
+
+	booking, err := loadBooking(ctx, tx, id, false)
+	if err != nil {
+		return 0, err
+	}
+	if err := audit.record(ctx, tx, action, "booking", strconv.Itoa(id), nil, booking); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	metrics.bookingsCreated.inc(booking.Source, booking.Status)
+	return id, nil
+}
+
+// createZoomMeeting creates a Zoom meeting if enabled, returning its join URL or "" on failure
+func (h *APIHandlers) createZoomMeeting(ctx context.Context, name, email string, slotTime time.Time) string {
+	if !h.Flags.Enabled(ctx, FlagCreateZoomMeeting) || h.ZoomService == nil {
+		return ""
+	}
+
+	zoomLink, err := h.ZoomService.CreateMeeting(ctx, name, email, slotTime)
+	if err != nil {
+		// Log the error but don't fail the booking
+		slog.WarnContext(ctx, "Failed to create Zoom meeting", "error", err)
+		return ""
+	}
+	return zoomLink
+}
+
+// deleteZoomMeeting removes a meeting created for a booking that did not go through. The
+// cleanup runs even if the client has disconnected; the Zoom timeout still bounds it.
+func (h *APIHandlers) deleteZoomMeeting(ctx context.Context, zoomLink string) {
+	if zoomLink == "" || h.ZoomService == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := h.ZoomService.DeleteMeeting(ctx, zoomLink); err != nil {
+		slog.WarnContext(ctx, "Failed to delete Zoom meeting", "error", err)
+	}
+}
+
+// sendConfirmationEmail sends the booking confirmation if enabled, logging failures. The
+// booking is already committed, so the email goes out even if the client has disconnected.
+func (h *APIHandlers) sendConfirmationEmail(ctx context.Context, bookingID int, name, email string, slotTime time.Time, duration time.Duration, paid bool, zoomLink string) {
+	if !h.clientEmailsEnabled(ctx) {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	if err := h.EmailService.SendBookingConfirmation(ctx, name, email, slotTime, duration, paid, zoomLink, CalendarUID(bookingID)); err != nil {
+		// Log the error but don't fail the booking
+		slog.WarnContext(ctx, "Booking created but failed to send confirmation email", "error", err)
+	}
+}
+
+// clientEmailsEnabled reports whether booking emails to clients are switched on (FlagSendConfirmationEmail)
+func (h *APIHandlers) clientEmailsEnabled(ctx context.Context) bool {
+	return h.Flags.Enabled(ctx, FlagSendConfirmationEmail) && h.EmailService != nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "duplicate key value") || strings.Contains(err.Error(), "unique constraint")
+}
+
 func (h *APIHandlers) GetAdminSlots(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
 	slots := h.GenerateAvailableSlots()
 	adminSlots := make([]AdminSlot, 0, len(slots))
 
+	sessionTypes, err := h.loadSessionTypes(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying session types", "error", err)
+		return
+	}
+
+	// Get booked sessions to work out which slots fall into buffers
+	from, to := sessionWindow(slots)
+	sessions, err := h.loadSessions(ctx, sessionTypes, from, to)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+		return
+	}
+	defaultType := sessionTypes[DefaultSessionType]
+
 	// Get booked slots with booking info
 	bookedMap := make(map[int64]Booking)
-	bookingRows, err := h.DB.Query("SELECT slot_time, name, email FROM bookings")
+	bookingRows, err := h.DB.QueryContext(ctx, "SELECT id, slot_time, name, email, status, COALESCE(client_id, 0) FROM bookings WHERE "+occupyingBookingSQL)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error querying bookings: %v", err)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
 		return
 	}
 	defer bookingRows.Close()
 
 	for bookingRows.Next() {
+		var id int
 		var slotTime time.Time
-		var name, email string
-		if err := bookingRows.Scan(&slotTime, &name, &email); err != nil {
+		var name, email, status string
+		var clientID int
+		if err := bookingRows.Scan(&id, &slotTime, &name, &email, &status, &clientID); err != nil {
 			continue
 		}
 		// Use Unix timestamp for timezone-independent comparison
 		bookedMap[slotTime.Unix()] = Booking{
+			ID:       id,
 			SlotTime: slotTime,
 			Name:     name,
 			Email:    email,
+			Status:   status,
+			ClientID: clientID,
 		}
 	}
 
 	// Get blocked slots
 	blockedMap := make(map[int64]bool)
-	blockedRows, err := h.DB.Query("SELECT slot_time FROM blocked_slots")
+	blockedRows, err := h.DB.QueryContext(ctx, "SELECT slot_time FROM blocked_slots")
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error querying blocked slots: %v", err)
+		slog.ErrorContext(r.Context(), "Error querying blocked slots", "error", err)
 		return
 	}
 	defer blockedRows.Close()
@@ -275,10 +655,18 @@ func (h *APIHandlers) GetAdminSlots(w http.ResponseWriter, r *http.Request) {
 		unixTime := slotTime.Unix()
 		if booking, ok := bookedMap[unixTime]; ok {
 			adminSlot.Status = "booked"
+			if booking.Status == StatusPending {
+				adminSlot.Status = "pending"
+			}
+			adminSlot.BookingID = booking.ID
+			adminSlot.BookingStatus = booking.Status
+			adminSlot.ClientID = booking.ClientID
 			adminSlot.Name = booking.Name
 			adminSlot.Email = booking.Email
 		} else if blockedMap[unixTime] {
 			adminSlot.Status = "blocked"
+		} else if slotOccupancy(slotTime, defaultType, sessions) != slotFree {
+			adminSlot.Status = "buffer"
 		}
 
 		adminSlots = append(adminSlots, adminSlot)
@@ -294,6 +682,9 @@ func (h *APIHandlers) BlockSlot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
 	var req struct {
 		SlotTime string `json:"slot_time"`
 	}
@@ -312,12 +703,20 @@ func (h *APIHandlers) BlockSlot(w http.ResponseWriter, r *http.Request) {
 	// Convert to UTC for consistent storage (SQLite driver doesn't handle timezones well)
 	slotTimeUTC := slotTime.UTC()
 
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
 	// Check if slot is already booked
 	var count int
-	err = h.DB.QueryRow("SELECT COUNT(*) FROM bookings WHERE slot_time = $1", slotTimeUTC).Scan(&count)
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookings WHERE slot_time = $1 AND "+occupyingBookingSQL, slotTimeUTC).Scan(&count)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error checking booking: %v", err)
+		slog.ErrorContext(r.Context(), "Error checking booking", "error", err)
 		return
 	}
 
@@ -327,18 +726,31 @@ func (h *APIHandlers) BlockSlot(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Insert blocked slot (store in UTC)
-	_, err = h.DB.Exec("INSERT INTO blocked_slots (slot_time) VALUES ($1)", slotTimeUTC)
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO blocked_slots (slot_time) VALUES ($1) RETURNING id", slotTimeUTC).Scan(&id)
 	if err != nil {
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "duplicate key value") || strings.Contains(errMsg, "unique constraint") {
+		if isUniqueViolation(err) {
 			http.Error(w, "Slot already blocked", http.StatusConflict)
 		} else {
 			http.Error(w, fmt.Sprintf("Failed to block slot: %v", err), http.StatusInternalServerError)
-			log.Printf("Error blocking slot: %v", err)
+			slog.ErrorContext(r.Context(), "Error blocking slot", "error", err)
 		}
 		return
 	}
 
+	after := map[string]interface{}{"id": id, "slot_time": slotTimeUTC}
+	if err := auditorFromRequest(r).record(ctx, tx, AuditSlotBlocked, "blocked_slot", slotTimeUTC.Format(time.RFC3339), nil, after); err != nil {
+		http.Error(w, "Failed to block slot", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error blocking slot", "error", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to block slot", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error committing blocked slot", "error", err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -352,6 +764,9 @@ func (h *APIHandlers) UnblockSlot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
 	var req struct {
 		SlotTime string `json:"slot_time"`
 	}
@@ -370,20 +785,42 @@ func (h *APIHandlers) UnblockSlot(w http.ResponseWriter, r *http.Request) {
 	// Convert to UTC (database stores times in UTC)
 	slotTimeUTC := slotTime.UTC()
 
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
 	// Delete blocked slot
-	result, err := h.DB.Exec("DELETE FROM blocked_slots WHERE slot_time = $1", slotTimeUTC)
+	var id int
+	err = tx.QueryRowContext(ctx, "DELETE FROM blocked_slots WHERE slot_time = $1 RETURNING id", slotTimeUTC).Scan(&id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Slot not found in blocked list", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to unblock slot", http.StatusInternalServerError)
-		log.Printf("Error unblocking slot: %v", err)
+		slog.ErrorContext(r.Context(), "Error unblocking slot", "error", err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Slot not found in blocked list", http.StatusNotFound)
+	before := map[string]interface{}{"id": id, "slot_time": slotTimeUTC}
+	if err := auditorFromRequest(r).record(ctx, tx, AuditSlotUnblocked, "blocked_slot", slotTimeUTC.Format(time.RFC3339), before, nil); err != nil {
+		http.Error(w, "Failed to unblock slot", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error unblocking slot", "error", err)
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to unblock slot", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error committing unblock", "error", err)
+		return
+	}
+
+	h.notifyWaitlist(r, slotTimeUTC)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Slot unblocked successfully",
@@ -396,6 +833,9 @@ func (h *APIHandlers) DebugBlockedSlots(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
 	type DebugInfo struct {
 		SlotTimeRaw     string `json:"slot_time_raw"`
 		SlotTimeUnix    int64  `json:"slot_time_unix"`
@@ -403,7 +843,7 @@ func (h *APIHandlers) DebugBlockedSlots(w http.ResponseWriter, r *http.Request)
 		Location        string `json:"location"`
 	}
 
-	rows, err := h.DB.Query("SELECT slot_time FROM blocked_slots WHERE slot_time LIKE '2026-%' LIMIT 20")
+	rows, err := h.DB.QueryContext(ctx, "SELECT slot_time FROM blocked_slots WHERE slot_time LIKE '2026-%' LIMIT 20")
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
@@ -428,27 +868,19 @@ func (h *APIHandlers) DebugBlockedSlots(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(debugInfo)
 }
 
+// ClearAllBlockedSlots unblocks every blocked slot as a bulk operation, so it can be
+// previewed with ?dry_run=true and undone with the returned operation_id
 func (h *APIHandlers) ClearAllBlockedSlots(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Delete all blocked slots
-	result, err := h.DB.Exec("DELETE FROM blocked_slots")
-	if err != nil {
-		http.Error(w, "Failed to clear blocked slots", http.StatusInternalServerError)
-		log.Printf("Error clearing blocked slots: %v", err)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
+	var req BulkRequest
+	req.DryRun, _ = strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	criteria := &bulkCriteria{loc: h.Policy.location(), startMinutes: 0, endMinutes: 24 * 60}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":       "All blocked slots cleared",
-		"rows_affected": rowsAffected,
-	})
+	h.runBulk(w, r, BulkUnblock, req, criteria)
 }
 
 func (h *APIHandlers) CancelBooking(w http.ResponseWriter, r *http.Request) {
@@ -459,6 +891,7 @@ func (h *APIHandlers) CancelBooking(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		SlotTime string `json:"slot_time"`
+		Reason   string `json:"reason"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -475,13 +908,14 @@ func (h *APIHandlers) CancelBooking(w http.ResponseWriter, r *http.Request) {
 	// Convert to UTC for database query
 	slotTimeUTC := slotTime.UTC()
 
-	// Get the booking details (including zoom_link) before deleting
-	var zoomLink sql.NullString
-	var name, email string
-	err = h.DB.QueryRow(
-		"SELECT name, email, zoom_link FROM bookings WHERE slot_time = $1",
+	// Find the active booking occupying the slot
+	var id int
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT id FROM bookings WHERE slot_time = $1 AND "+occupyingBookingSQL,
 		slotTimeUTC,
-	).Scan(&name, &email, &zoomLink)
+	).Scan(&id)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Booking not found", http.StatusNotFound)
@@ -490,33 +924,28 @@ func (h *APIHandlers) CancelBooking(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
-		log.Printf("Error querying booking: %v", err)
+		slog.ErrorContext(r.Context(), "Error querying booking", "error", err)
 		return
 	}
 
-	// Delete the Zoom meeting if one exists
-	if zoomLink.Valid && zoomLink.String != "" && h.ZoomService != nil {
-		if err := h.ZoomService.DeleteMeeting(zoomLink.String); err != nil {
-			// Log the error but don't fail the booking cancellation
-			log.Printf("Warning: Failed to delete Zoom meeting for booking %s: %v", req.SlotTime, err)
-		}
-	}
-
-	// Delete the booking from database
-	result, err := h.DB.Exec("DELETE FROM bookings WHERE slot_time = $1", slotTimeUTC)
-	if err != nil {
+	// Mark the booking as cancelled by the coach (this also deletes its Zoom meeting)
+	from, err := h.transitionBooking(r.Context(), auditorFromRequest(r), id, StatusCancelledByCoach, req.Reason, false)
+	switch {
+	case errors.Is(err, ErrBookingNotFound):
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrInvalidTransition):
+		http.Error(w, "Cannot cancel a booking that is "+from, http.StatusConflict)
+		return
+	case err != nil:
 		http.Error(w, "Failed to cancel booking", http.StatusInternalServerError)
-		log.Printf("Error deleting booking: %v", err)
+		slog.ErrorContext(r.Context(), "Error cancelling booking", "error", err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Booking not found", http.StatusNotFound)
-		return
-	}
+	slog.InfoContext(r.Context(), "Booking cancelled", "booking_id", id, "slot_time", req.SlotTime)
 
-	log.Printf("Booking cancelled successfully: %s - %s (%s)", req.SlotTime, name, email)
+	h.notifyWaitlist(r, slotTimeUTC)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditBookingCreated       = "booking.created"
+	AuditBookingHeld          = "booking.held"
+	AuditBookingConfirmed     = "booking.confirmed"
+	AuditBookingStatusChanged = "booking.status_changed"
+	AuditBookingHoldExpired   = "booking.hold_expired"
+	AuditSlotBlocked          = "slot.blocked"
+	AuditSlotUnblocked        = "slot.unblocked"
+	AuditSessionTypeSaved     = "session_type.saved"
+)
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// AuditEvent is an entry of the append-only audit log
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+}
+
+// auditor carries who is making a change so the mutation can be recorded alongside it
+type auditor struct {
+	actor     Actor
+	ip        string
+	requestID string
+}
+
+func auditorFromRequest(r *http.Request) auditor {
+	return auditor{
+		actor:     ActorFromContext(r.Context()),
+		ip:        clientIP(r),
+		requestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+// systemAuditor attributes changes made by background jobs
+func systemAuditor(job string) auditor {
+	return auditor{actor: Actor{Type: ActorSystem, ID: job}}
+}
+
+// record appends an audit event using tx so it commits or rolls back with the mutation
+func (a auditor) record(ctx context.Context, tx execer, action, entityType, entityID string, before, after interface{}) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO audit_events (actor_type, actor_id, action, entity_type, entity_id, before_state, after_state, ip, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		a.actor.Type, nullString(a.actor.ID), action, entityType, nullString(entityID),
+		beforeJSON, afterJSON, nullString(a.ip), nullString(a.requestID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+func auditJSON(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	return string(b), nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// loadBooking reads the audited fields of a booking, locking the row when lock is set
+func loadBooking(ctx context.Context, q queryRower, id int, lock bool) (*Booking, error) {
+	query := `SELECT id, slot_time, name, email, created_at, duration, zoom_link, status, session_type, client_id, source,
+		package_id, discount_code_id FROM bookings WHERE id = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var b Booking
+	var zoomLink sql.NullString
+	var createdAt sql.NullTime
+	var clientID, packageID, discountCodeID sql.NullInt64
+	err := q.QueryRowContext(ctx, query, id).Scan(&b.ID, &b.SlotTime, &b.Name, &b.Email, &createdAt, &b.DurationMinutes, &zoomLink, &b.Status, &b.SessionType, &clientID, &b.Source,
+		&packageID, &discountCodeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.ZoomLink = zoomLink.String
+	b.CreatedAt = createdAt.Time
+	b.ClientID = int(clientID.Int64)
+	b.PackageID = int(packageID.Int64)
+	b.DiscountCodeID = int(discountCodeID.Int64)
+	return &b, nil
+}
+
+const auditEventColumns = `id, occurred_at, actor_type, actor_id, action, entity_type, entity_id,
+	before_state, after_state, ip, request_id`
+
+func scanAuditEvent(row rowScanner) (*AuditEvent, error) {
+	var e AuditEvent
+	var actorID, entityID, before, after, ip, requestID sql.NullString
+	if err := row.Scan(&e.ID, &e.OccurredAt, &e.ActorType, &actorID, &e.Action, &e.EntityType, &entityID,
+		&before, &after, &ip, &requestID); err != nil {
+		return nil, err
+	}
+	e.ActorID = actorID.String
+	e.EntityID = entityID.String
+	e.IP = ip.String
+	e.RequestID = requestID.String
+	if before.Valid {
+		e.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		e.After = json.RawMessage(after.String)
+	}
+	return &e, nil
+}
+
+// ListAuditEvents returns audit events newest first. Filters: actor_type, actor_id, action,
+// entity_type, entity_id, from, to (RFC3339). Pagination: limit (default 50, max 200) and
+// cursor, the next_cursor value from the previous page.
+func (h *APIHandlers) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	addCondition := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	for _, field := range []string{"actor_type", "actor_id", "action", "entity_type", "entity_id"} {
+		if value := q.Get(field); value != "" {
+			addCondition(field+" = $%d", value)
+		}
+	}
+	for param, clause := range map[string]string{"from": "occurred_at >= $%d", "to": "occurred_at < $%d"} {
+		if value := q.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid "+param+" format", http.StatusBadRequest)
+				return
+			}
+			addCondition(clause, t.UTC())
+		}
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		addCondition("id < $%d", id)
+	}
+
+	limit := 50
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > 200 {
+			n = 200
+		}
+		limit = n
+	}
+
+	query := "SELECT " + auditEventColumns + " FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT %d", limit)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying audit events", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	events := make([]AuditEvent, 0, limit)
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning audit event", "error", err)
+			return
+		}
+		events = append(events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying audit events", "error", err)
+		return
+	}
+
+	response := map[string]interface{}{"events": events}
+	if len(events) == limit {
+		response["next_cursor"] = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
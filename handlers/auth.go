@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Actor types recorded in the audit log
+const (
+	ActorAdmin  = "admin"
+	ActorScript = "script"
+	ActorClient = "client"
+	ActorSystem = "system"
+)
+
+// Actor identifies who performed a request
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (a Actor) String() string {
+	if a.ID == "" {
+		return a.Type
+	}
+	return a.Type + ":" + a.ID
+}
+
+// ActorFromContext returns the actor stored by AdminAuth, or an anonymous client
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: ActorClient}
+}
+
+// withActor stores the actor in the request context
+func withActor(r *http.Request, actor Actor) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorKey, actor))
+}
+
+// AdminAuth protects the admin page and API. Browsers authenticate with HTTP Basic auth
+// (ADMIN_USERNAME / ADMIN_PASSWORD); scripts send "Authorization: Bearer <token>" with one
+// of the named API tokens. When no credentials are configured the admin area stays open,
+// as it was before authentication existed, and requests are attributed to an anonymous admin.
+type AdminAuth struct {
+	Username  string
+	Password  string
+	APITokens map[string]string // token name -> token
+}
+
+// ParseAPITokens parses "name:token,name2:token2" into a map of token name to token
+func ParseAPITokens(value string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("expected name:token, got %q", entry)
+		}
+		tokens[name] = token
+	}
+	return tokens, nil
+}
+
+// Enabled reports whether any admin credentials are configured
+func (a *AdminAuth) Enabled() bool {
+	return a != nil && ((a.Username != "" && a.Password != "") || len(a.APITokens) > 0)
+}
+
+// authenticate returns the actor for the request credentials
+func (a *AdminAuth) authenticate(r *http.Request) (Actor, bool) {
+	if !a.Enabled() {
+		return Actor{Type: ActorAdmin, ID: "anonymous"}, true
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		presented := strings.TrimPrefix(auth, "Bearer ")
+		for name, token := range a.APITokens {
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+				return Actor{Type: ActorScript, ID: name}, true
+			}
+		}
+		return Actor{}, false
+	}
+
+	if a.Username != "" && a.Password != "" {
+		user, pass, ok := r.BasicAuth()
+		if ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(a.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(a.Password)) == 1 {
+			return Actor{Type: ActorAdmin, ID: user}, true
+		}
+	}
+
+	return Actor{}, false
+}
+
+// Require wraps an admin handler, rejecting unauthenticated requests and recording the actor
+func (a *AdminAuth) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Coach Calendar Admin", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, withActor(r, actor))
+	}
+}
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BookingDetails is the full admin view of a booking
+type BookingDetails struct {
+	ID              int        `json:"id"`
+	SlotTime        time.Time  `json:"slot_time"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	DurationMinutes int        `json:"duration_minutes"`
+	ZoomLink        string     `json:"zoom_link,omitempty"`
+	SessionType     string     `json:"session_type"`
+	ClientID        int        `json:"client_id,omitempty"`
+	Source          string     `json:"source"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusUpdatedAt *time.Time `json:"status_updated_at,omitempty"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
+	HoldExpiresAt   *time.Time `json:"hold_expires_at,omitempty"`
+	PackageID       int        `json:"package_id,omitempty"`
+	DiscountCodeID  int        `json:"discount_code_id,omitempty"`
+}
+
+// bookingDetailsColumns is the column list scanned by scanBookingDetails
+const bookingDetailsColumns = `id, slot_time, name, email, created_at, duration, zoom_link, session_type, client_id,
+	source, created_by, status, status_reason, status_updated_at, confirmed_at, cancelled_at, hold_expires_at,
+	package_id, discount_code_id`
+
+// bookingSortColumns maps the sort parameter to a non-null SQL expression. Bookings imported
+// without created_at sort by their slot time.
+var bookingSortColumns = map[string]string{
+	"slot_time":  "slot_time",
+	"created_at": "COALESCE(created_at, slot_time)",
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBookingDetails(row rowScanner) (*BookingDetails, error) {
+	var b BookingDetails
+	var zoomLink, statusReason, createdBy sql.NullString
+	var createdAt, statusUpdatedAt, confirmedAt, cancelledAt, holdExpiresAt sql.NullTime
+	var clientID, packageID, discountCodeID sql.NullInt64
+	err := row.Scan(&b.ID, &b.SlotTime, &b.Name, &b.Email, &createdAt, &b.DurationMinutes, &zoomLink, &b.SessionType, &clientID,
+		&b.Source, &createdBy, &b.Status, &statusReason, &statusUpdatedAt, &confirmedAt, &cancelledAt, &holdExpiresAt,
+		&packageID, &discountCodeID)
+	if err != nil {
+		return nil, err
+	}
+	b.ZoomLink = zoomLink.String
+	b.ClientID = int(clientID.Int64)
+	b.PackageID = int(packageID.Int64)
+	b.DiscountCodeID = int(discountCodeID.Int64)
+	b.CreatedBy = createdBy.String
+	b.StatusReason = statusReason.String
+	b.CreatedAt = nullTimePtr(createdAt)
+	b.StatusUpdatedAt = nullTimePtr(statusUpdatedAt)
+	b.ConfirmedAt = nullTimePtr(confirmedAt)
+	b.CancelledAt = nullTimePtr(cancelledAt)
+	b.HoldExpiresAt = nullTimePtr(holdExpiresAt)
+	return &b, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// parseTimeParam accepts RFC3339 timestamps or YYYY-MM-DD dates in loc
+func parseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// parseRangeParam parses the from or to bound of a slot time filter. A bare date as to
+// includes the whole day, so its bound is the following local midnight.
+func parseRangeParam(param, value string, loc *time.Location) (time.Time, error) {
+	t, err := parseTimeParam(value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if param == "to" && !strings.Contains(value, "T") {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.UTC(), nil
+}
+
+// encodeBookingCursor and decodeBookingCursor wrap the sort key of the last row of a page
+func encodeBookingCursor(sortValue time.Time, id int) string {
+	raw := sortValue.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBookingCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	value, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, id, nil
+}
+
+// Booking sources
+const (
+	BookingSourcePublic   = "public"
+	BookingSourceAdmin    = "admin"
+	BookingSourceWaitlist = "waitlist"
+)
+
+// Bookings serves /api/admin/bookings
+func (h *APIHandlers) Bookings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listBookings(w, r)
+	case http.MethodPost:
+		h.createAdminBooking(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listBookings returns bookings straight from the database, independent of the slot generator.
+// Filters: from, to (RFC3339 or YYYY-MM-DD, on slot_time), status (comma-separated), q (name
+// or email substring), email (exact, case-insensitive), session_type, source, client_id. Sorting: sort
+// (slot_time or created_at) and order (asc or desc, default desc). Pagination: limit
+// (default 50, max 200) and cursor, the next_cursor value from the previous page.
+func (h *APIHandlers) listBookings(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+	loc := h.Policy.location()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(clause string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(clause, placeholders...))
+	}
+
+	for param, clause := range map[string]string{"from": "slot_time >= $%d", "to": "slot_time < $%d"} {
+		if value := q.Get(param); value != "" {
+			t, err := parseRangeParam(param, value, loc)
+			if err != nil {
+				http.Error(w, "Invalid "+param+" format", http.StatusBadRequest)
+				return
+			}
+			addCondition(clause, t)
+		}
+	}
+
+	if value := q.Get("status"); value != "" {
+		var placeholders []string
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if _, known := statusTransitions[status]; !known && !isCancelledStatus(status) && status != StatusExpired {
+				http.Error(w, "Unknown status: "+status, http.StatusBadRequest)
+				return
+			}
+			args = append(args, status)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if value := q.Get("q"); value != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value) + "%"
+		addCondition("(name ILIKE $%d OR email ILIKE $%d)", pattern, pattern)
+	}
+	if value := q.Get("email"); value != "" {
+		addCondition("LOWER(email) = LOWER($%d)", value)
+	}
+	if value := q.Get("session_type"); value != "" {
+		addCondition("session_type = $%d", value)
+	}
+	if value := q.Get("source"); value != "" {
+		addCondition("source = $%d", value)
+	}
+	if value := q.Get("client_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid client_id", http.StatusBadRequest)
+			return
+		}
+		addCondition("client_id = $%d", id)
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = "slot_time"
+	}
+	sortExpr, ok := bookingSortColumns[sort]
+	if !ok {
+		http.Error(w, "Invalid sort, expected slot_time or created_at", http.StatusBadRequest)
+		return
+	}
+	order := strings.ToLower(q.Get("order"))
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		http.Error(w, "Invalid order, expected asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	if cursor := q.Get("cursor"); cursor != "" {
+		sortValue, id, err := decodeBookingCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		comparison := "<"
+		if order == "asc" {
+			comparison = ">"
+		}
+		addCondition("("+sortExpr+", id) "+comparison+" ($%d, $%d)", sortValue, id)
+	}
+
+	limit := 50
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > 200 {
+			n = 200
+		}
+		limit = n
+	}
+
+	query := "SELECT " + bookingDetailsColumns + " FROM bookings"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", sortExpr, order, order, limit)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	bookings := make([]BookingDetails, 0, limit)
+	for rows.Next() {
+		b, err := scanBookingDetails(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning booking", "error", err)
+			return
+		}
+		bookings = append(bookings, *b)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+		return
+	}
+
+	response := map[string]interface{}{"bookings": bookings}
+	if len(bookings) == limit {
+		last := bookings[len(bookings)-1]
+		sortValue := last.SlotTime
+		if sort == "created_at" && last.CreatedAt != nil {
+			sortValue = *last.CreatedAt
+		}
+		response["next_cursor"] = encodeBookingCursor(sortValue, last.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AdminBookingRequest is a booking the coach creates for a client, e.g. after a phone call
+type AdminBookingRequest struct {
+	SlotTime    string `json:"slot_time"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	SessionType string `json:"session_type,omitempty"`
+
+	// Override books the slot even if it is blocked or overlaps another session's buffer.
+	// A slot already taken by an active booking can never be double-booked.
+	Override         bool `json:"override"`
+	CreateZoom       bool `json:"create_zoom"`
+	SendConfirmation bool `json:"send_confirmation"`
+}
+
+// createAdminBooking books a slot on a client's behalf. The public booking rules (notice,
+// advance window, caps, rate limits, verification) do not apply, and the Zoom meeting and
+// confirmation email are explicit choices rather than environment defaults.
+func (h *APIHandlers) createAdminBooking(w http.ResponseWriter, r *http.Request) {
+	var req AdminBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Email) == "" || req.SlotTime == "" {
+		http.Error(w, "Name, email, and slot_time are required", http.StatusBadRequest)
+		return
+	}
+
+	slotTime, err := time.Parse(time.RFC3339, req.SlotTime)
+	if err != nil {
+		http.Error(w, "Invalid slot_time format", http.StatusBadRequest)
+		return
+	}
+	slotTimeUTC := slotTime.UTC()
+
+	ctx := r.Context()
+	sessionTypes, err := h.loadSessionTypes(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying session types", "error", err)
+		return
+	}
+	if req.SessionType == "" {
+		req.SessionType = DefaultSessionType
+	}
+	slotType, ok := sessionTypes[req.SessionType]
+	if !ok {
+		http.Error(w, "Unknown session type", http.StatusBadRequest)
+		return
+	}
+
+	var guard *slotGuard
+	if !req.Override {
+		var blocked int
+		dbCtx, cancel := h.dbContext(ctx)
+		defer cancel()
+		if err := h.DB.QueryRowContext(dbCtx, "SELECT COUNT(*) FROM blocked_slots WHERE slot_time = $1", slotTimeUTC).Scan(&blocked); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error checking blocked slot", "error", err)
+			return
+		}
+		if blocked > 0 {
+			http.Error(w, "Slot is blocked; set override to book it anyway", http.StatusConflict)
+			return
+		}
+
+		sessions, err := h.loadSessions(ctx, sessionTypes, slotTime.AddDate(0, 0, -1), slotTime.AddDate(0, 0, 1))
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error querying bookings", "error", err)
+			return
+		}
+		switch slotOccupancy(slotTime, slotType, sessions) {
+		case slotOverlapsSession:
+			http.Error(w, "Slot overlaps another session; set override to book it anyway", http.StatusConflict)
+			return
+		case slotInBuffer:
+			http.Error(w, "Slot is too close to another session; set override to book it anyway", http.StatusConflict)
+			return
+		}
+		guard = &slotGuard{slotTime: slotTime, slotType: slotType, types: sessionTypes}
+	}
+
+	// Free the slot if it is only held by an expired, unconfirmed booking
+	if _, err := h.ExpirePendingHolds(ctx); err != nil {
+		slog.WarnContext(r.Context(), "Failed to clear expired holds", "error", err)
+	}
+
+	zoomLink := ""
+	if req.CreateZoom {
+		if h.ZoomService == nil {
+			slog.WarnContext(r.Context(), "Zoom meeting requested for admin booking but Zoom is not configured")
+		} else if zoomLink, err = h.ZoomService.CreateMeeting(ctx, req.Name, req.Email, slotTime); err != nil {
+			slog.WarnContext(r.Context(), "Failed to create Zoom meeting", "error", err)
+			zoomLink = ""
+		}
+	}
+
+	audit := auditorFromRequest(r)
+	id, err := h.insertBooking(ctx, audit, AuditBookingCreated, guard,
+		`INSERT INTO bookings (slot_time, name, email, zoom_link, duration, session_type, status, confirmed_at, source, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, 'confirmed', CURRENT_TIMESTAMP, $7, $8)`,
+		slotTimeUTC, strings.TrimSpace(req.Name), strings.TrimSpace(req.Email), nullString(zoomLink),
+		slotType.DurationMinutes, slotType.Key, BookingSourceAdmin, audit.actor.String(),
+	)
+	if err != nil {
+		h.deleteZoomMeeting(r.Context(), zoomLink)
+		switch {
+		case isUniqueViolation(err):
+			http.Error(w, "Slot already booked", http.StatusConflict)
+		case errors.Is(err, ErrSlotBooked):
+			http.Error(w, "Slot overlaps another session; set override to book it anyway", http.StatusConflict)
+		case errors.Is(err, ErrSlotInBuffer):
+			http.Error(w, "Slot is too close to another session; set override to book it anyway", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to create booking", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating admin booking", "error", err)
+		}
+		return
+	}
+
+	// The booking is committed; send the email even if the admin has disconnected
+	ctx = context.WithoutCancel(ctx)
+	emailSent := false
+	if req.SendConfirmation && h.EmailService != nil {
+		if err := h.EmailService.SendAdminBookingConfirmation(ctx, req.Name, req.Email, slotTime, slotType.Duration(), slotType.Paid(), zoomLink, CalendarUID(id)); err != nil {
+			slog.WarnContext(r.Context(), "Booking created but failed to send confirmation email", "booking_id", id, "error", err)
+		} else {
+			emailSent = true
+		}
+	}
+
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+	booking, err := scanBookingDetails(h.DB.QueryRowContext(dbCtx, "SELECT "+bookingDetailsColumns+" FROM bookings WHERE id = $1", id))
+	if err != nil {
+		http.Error(w, "Booking created but could not be loaded", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error loading booking", "booking_id", id, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Booking created by admin", "booking_id", id, "actor", audit.actor, "override", req.Override)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"booking":    booking,
+		"email_sent": emailSent,
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestBookingCursorRoundTrip(t *testing.T) {
+	loc := testLocation(t)
+
+	tests := []struct {
+		name      string
+		sortValue time.Time
+		id        int
+	}{
+		{"UTC", time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), 42},
+		{"local time", time.Date(2026, 3, 29, 10, 30, 0, 0, loc), 7},
+		{"sub-second created_at", time.Date(2026, 3, 2, 10, 0, 0, 123456789, time.UTC), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortValue, id, err := decodeBookingCursor(encodeBookingCursor(tt.sortValue, tt.id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sortValue.Equal(tt.sortValue) || id != tt.id {
+				t.Errorf("decoded %s, %d, want %s, %d", sortValue, id, tt.sortValue, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeBookingCursorInvalid(t *testing.T) {
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"no separator", encode("2026-03-02T10:00:00Z")},
+		{"bad time", encode("yesterday|42")},
+		{"bad id", encode("2026-03-02T10:00:00Z|x")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeBookingCursor(tt.cursor); err == nil {
+				t.Errorf("decodeBookingCursor(%q) succeeded", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestParseRangeParam(t *testing.T) {
+	loc := testLocation(t)
+
+	tests := []struct {
+		name    string
+		param   string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"from date", "from", "2026-03-02", time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC), false},
+		{"to date includes the day", "to", "2026-03-02", time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC), false},
+		{"to date on spring forward", "to", "2026-03-29", time.Date(2026, 3, 29, 22, 0, 0, 0, time.UTC), false},
+		{"to timestamp is exact", "to", "2026-03-02T10:00:00+01:00", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), false},
+		{"from timestamp", "from", "2026-03-02T10:00:00Z", time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), false},
+		{"invalid", "from", "02.03.2026", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRangeParam(tt.param, tt.value, loc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRangeParam(%q, %q) error = %v, wantErr %v", tt.param, tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseRangeParam(%q, %q) = %s, want %s", tt.param, tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,693 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bulk operation kinds
+const (
+	BulkBlock   = "block"
+	BulkUnblock = "unblock"
+	BulkCancel  = "cancel"
+)
+
+// Audit actions for bulk operations
+const (
+	AuditBulkApplied = "bulk.applied"
+	AuditBulkUndone  = "bulk.undone"
+)
+
+// DefaultBulkUndoWindow is how long a bulk operation can be undone when no window is configured
+const DefaultBulkUndoWindow = 15 * time.Minute
+
+// maxBulkBlockDays bounds the date range a bulk block or cancel may cover
+const maxBulkBlockDays = 366
+
+// Default time window for bulk operations, matching the public slot grid (last slot at 20:00)
+const (
+	defaultBulkStartTime = "09:00"
+	defaultBulkEndTime   = "20:30"
+)
+
+var (
+	ErrBulkOperationNotFound = errors.New("bulk operation not found")
+	ErrBulkUndoExpired       = errors.New("bulk operation can no longer be undone")
+	ErrBulkUndoConflict      = errors.New("slot was taken after the bulk operation")
+)
+
+// SlotFilter selects slots by date range, weekday and time of day, all in the policy
+// location. From and To are inclusive dates (YYYY-MM-DD); Weekdays uses 0 = Sunday … 6 = Saturday
+// and matches every day when empty; StartTime (inclusive) and EndTime (exclusive) are HH:MM.
+type SlotFilter struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Weekdays  []int  `json:"weekdays,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+}
+
+// BulkRequest is the body of the bulk endpoints
+type BulkRequest struct {
+	SlotFilter
+	Reason string `json:"reason,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// BulkItem is one slot affected (or skipped) by a bulk operation
+type BulkItem struct {
+	SlotTime       time.Time  `json:"slot_time"`
+	BlockedSlotID  int        `json:"blocked_slot_id,omitempty"`
+	BookingID      int        `json:"booking_id,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	PreviousStatus string     `json:"previous_status,omitempty"`
+	HoldExpiresAt  *time.Time `json:"hold_expires_at,omitempty"`
+	ZoomLink       string     `json:"zoom_link,omitempty"`
+	Reason         string     `json:"reason,omitempty"` // why the slot was skipped
+}
+
+// BulkResult is returned by the bulk endpoints; OperationID is empty for dry runs
+type BulkResult struct {
+	OperationID   string      `json:"operation_id,omitempty"`
+	Kind          string      `json:"kind"`
+	DryRun        bool        `json:"dry_run"`
+	Count         int         `json:"count"`
+	Affected      []BulkItem  `json:"affected"`
+	Skipped       []BulkItem  `json:"skipped"`
+	UndoExpiresAt *time.Time  `json:"undo_expires_at,omitempty"`
+	Criteria      BulkRequest `json:"criteria"`
+}
+
+// bulkCriteria is a parsed SlotFilter
+type bulkCriteria struct {
+	loc          *time.Location
+	from, to     time.Time // to is exclusive; zero means unbounded
+	weekdays     map[time.Weekday]bool
+	startMinutes int
+	endMinutes   int
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		if value == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (req SlotFilter) parse(loc *time.Location, requireRange bool) (*bulkCriteria, error) {
+	c := &bulkCriteria{loc: loc, weekdays: make(map[time.Weekday]bool)}
+
+	if req.From != "" {
+		from, err := time.ParseInLocation("2006-01-02", req.From, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date, expected YYYY-MM-DD")
+		}
+		c.from = from
+	}
+	if req.To != "" {
+		to, err := time.ParseInLocation("2006-01-02", req.To, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date, expected YYYY-MM-DD")
+		}
+		c.to = to.AddDate(0, 0, 1)
+	}
+	if requireRange {
+		if c.from.IsZero() || c.to.IsZero() {
+			return nil, fmt.Errorf("from and to are required")
+		}
+		if c.to.Sub(c.from) > maxBulkBlockDays*24*time.Hour {
+			return nil, fmt.Errorf("date range may not exceed %d days", maxBulkBlockDays)
+		}
+	}
+	if !c.from.IsZero() && !c.to.IsZero() && !c.from.Before(c.to) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+
+	for _, d := range req.Weekdays {
+		if d < 0 || d > 6 {
+			return nil, fmt.Errorf("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		c.weekdays[time.Weekday(d)] = true
+	}
+
+	startTime, endTime := req.StartTime, req.EndTime
+	if startTime == "" {
+		startTime = defaultBulkStartTime
+	}
+	if endTime == "" {
+		endTime = defaultBulkEndTime
+	}
+	var err error
+	if c.startMinutes, err = parseClock(startTime); err != nil {
+		return nil, fmt.Errorf("invalid start_time, expected HH:MM")
+	}
+	if c.endMinutes, err = parseClock(endTime); err != nil {
+		return nil, fmt.Errorf("invalid end_time, expected HH:MM")
+	}
+	if c.startMinutes >= c.endMinutes {
+		return nil, fmt.Errorf("start_time must be before end_time")
+	}
+
+	return c, nil
+}
+
+// matches reports whether the slot falls inside the criteria
+func (c *bulkCriteria) matches(t time.Time) bool {
+	if !c.from.IsZero() && t.Before(c.from) {
+		return false
+	}
+	if !c.to.IsZero() && !t.Before(c.to) {
+		return false
+	}
+	local := t.In(c.loc)
+	if len(c.weekdays) > 0 && !c.weekdays[local.Weekday()] {
+		return false
+	}
+	minutes := local.Hour()*60 + local.Minute()
+	return minutes >= c.startMinutes && minutes < c.endMinutes
+}
+
+// rangeSQL restricts slot_time to the date range, appending the bounds to args
+func (c *bulkCriteria) rangeSQL(args []interface{}) (string, []interface{}) {
+	var conditions []string
+	if !c.from.IsZero() {
+		args = append(args, c.from.UTC())
+		conditions = append(conditions, fmt.Sprintf("slot_time >= $%d", len(args)))
+	}
+	if !c.to.IsZero() {
+		args = append(args, c.to.UTC())
+		conditions = append(conditions, fmt.Sprintf("slot_time < $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "TRUE", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// slots lists the 30-minute grid slots matching the criteria
+func (c *bulkCriteria) slots() []time.Time {
+	var slots []time.Time
+	for day := c.from; day.Before(c.to); day = day.AddDate(0, 0, 1) {
+		for minutes := c.startMinutes; minutes < c.endMinutes; minutes += 30 {
+			t := time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, c.loc)
+			if c.matches(t) {
+				slots = append(slots, t.UTC())
+			}
+		}
+	}
+	return slots
+}
+
+func (h *APIHandlers) bulkUndoWindow() time.Duration {
+	if h.BulkUndoWindow > 0 {
+		return h.BulkUndoWindow
+	}
+	return DefaultBulkUndoWindow
+}
+
+// BulkBlock blocks every free slot matching the criteria
+func (h *APIHandlers) BulkBlock(w http.ResponseWriter, r *http.Request) {
+	h.handleBulk(w, r, BulkBlock)
+}
+
+// BulkUnblock unblocks every blocked slot matching the criteria
+func (h *APIHandlers) BulkUnblock(w http.ResponseWriter, r *http.Request) {
+	h.handleBulk(w, r, BulkUnblock)
+}
+
+// BulkCancel cancels, on the coach's side, every upcoming active booking matching the
+// criteria, which must include a date range. Zoom meetings are only deleted once the undo
+// window has passed.
+func (h *APIHandlers) BulkCancel(w http.ResponseWriter, r *http.Request) {
+	h.handleBulk(w, r, BulkCancel)
+}
+
+func (h *APIHandlers) handleBulk(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if dryRun := r.URL.Query().Get("dry_run"); dryRun != "" {
+		req.DryRun, _ = strconv.ParseBool(dryRun)
+	}
+
+	criteria, err := req.parse(h.Policy.location(), kind == BulkBlock || kind == BulkCancel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.runBulk(w, r, kind, req, criteria)
+}
+
+// runBulk applies (or previews) a bulk operation in a single transaction and writes the result
+func (h *APIHandlers) runBulk(w http.ResponseWriter, r *http.Request, kind string, req BulkRequest, criteria *bulkCriteria) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	result := &BulkResult{Kind: kind, DryRun: req.DryRun, Criteria: req, Affected: []BulkItem{}, Skipped: []BulkItem{}}
+	switch kind {
+	case BulkBlock:
+		err = bulkBlock(ctx, tx, criteria, result)
+	case BulkUnblock:
+		err = bulkUnblock(ctx, tx, criteria, result)
+	case BulkCancel:
+		err = bulkCancel(ctx, tx, criteria, req.Reason, result)
+	}
+	if err != nil {
+		http.Error(w, "Failed to apply bulk operation", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error applying bulk operation", "kind", kind, "error", err)
+		return
+	}
+	result.Count = len(result.Affected)
+
+	if !req.DryRun {
+		if err := h.saveBulkOperation(ctx, tx, auditorFromRequest(r), result); err != nil {
+			http.Error(w, "Failed to apply bulk operation", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error saving bulk operation", "kind", kind, "error", err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to apply bulk operation", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error committing bulk operation", "kind", kind, "error", err)
+			return
+		}
+		if kind == BulkCancel {
+			metrics.bookingsCancelled.add(float64(result.Count), StatusCancelledByCoach)
+		}
+		slog.InfoContext(r.Context(), "Bulk operation applied", "kind", kind, "operation_id", result.OperationID, "count", result.Count)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func bulkBlock(ctx context.Context, tx *sql.Tx, criteria *bulkCriteria, result *BulkResult) error {
+	where, args := criteria.rangeSQL(nil)
+
+	blocked := make(map[int64]bool)
+	rows, err := tx.QueryContext(ctx, "SELECT slot_time FROM blocked_slots WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var slotTime time.Time
+		if err := rows.Scan(&slotTime); err != nil {
+			rows.Close()
+			return err
+		}
+		blocked[slotTime.Unix()] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	booked := make(map[int64]bool)
+	rows, err = tx.QueryContext(ctx, "SELECT slot_time FROM bookings WHERE "+where+" AND "+occupyingBookingSQL, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var slotTime time.Time
+		if err := rows.Scan(&slotTime); err != nil {
+			rows.Close()
+			return err
+		}
+		booked[slotTime.Unix()] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, slotTime := range criteria.slots() {
+		switch {
+		case blocked[slotTime.Unix()]:
+			result.Skipped = append(result.Skipped, BulkItem{SlotTime: slotTime, Reason: "already blocked"})
+			continue
+		case booked[slotTime.Unix()]:
+			result.Skipped = append(result.Skipped, BulkItem{SlotTime: slotTime, Reason: "booked"})
+			continue
+		}
+
+		item := BulkItem{SlotTime: slotTime}
+		if !result.DryRun {
+			if err := tx.QueryRowContext(ctx, "INSERT INTO blocked_slots (slot_time) VALUES ($1) RETURNING id", slotTime).Scan(&item.BlockedSlotID); err != nil {
+				return err
+			}
+		}
+		result.Affected = append(result.Affected, item)
+	}
+	return nil
+}
+
+func bulkUnblock(ctx context.Context, tx *sql.Tx, criteria *bulkCriteria, result *BulkResult) error {
+	where, args := criteria.rangeSQL(nil)
+	rows, err := tx.QueryContext(ctx, "SELECT id, slot_time FROM blocked_slots WHERE "+where+" ORDER BY slot_time FOR UPDATE", args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item BulkItem
+		if err := rows.Scan(&item.BlockedSlotID, &item.SlotTime); err != nil {
+			rows.Close()
+			return err
+		}
+		item.SlotTime = item.SlotTime.UTC()
+		if criteria.matches(item.SlotTime) {
+			result.Affected = append(result.Affected, item)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		return nil
+	}
+	for _, item := range result.Affected {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM blocked_slots WHERE id = $1", item.BlockedSlotID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bulkCancel(ctx context.Context, tx *sql.Tx, criteria *bulkCriteria, reason string, result *BulkResult) error {
+	where, args := criteria.rangeSQL(nil)
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, slot_time, name, status, hold_expires_at, zoom_link FROM bookings WHERE "+where+
+			" AND slot_time > CURRENT_TIMESTAMP AND "+occupyingBookingSQL+" ORDER BY slot_time FOR UPDATE",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item BulkItem
+		var holdExpiresAt sql.NullTime
+		var zoomLink sql.NullString
+		if err := rows.Scan(&item.BookingID, &item.SlotTime, &item.Name, &item.PreviousStatus, &holdExpiresAt, &zoomLink); err != nil {
+			rows.Close()
+			return err
+		}
+		item.SlotTime = item.SlotTime.UTC()
+		item.ZoomLink = zoomLink.String
+		if holdExpiresAt.Valid {
+			item.HoldExpiresAt = &holdExpiresAt.Time
+		}
+		if !criteria.matches(item.SlotTime) {
+			continue
+		}
+		if !canTransition(item.PreviousStatus, StatusCancelledByCoach) {
+			item.Reason = "status " + item.PreviousStatus
+			result.Skipped = append(result.Skipped, item)
+			continue
+		}
+		result.Affected = append(result.Affected, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if result.DryRun {
+		return nil
+	}
+	for _, item := range result.Affected {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE bookings SET
+				status = 'cancelled_by_coach',
+				status_reason = $1,
+				status_updated_at = CURRENT_TIMESTAMP,
+				cancelled_at = CURRENT_TIMESTAMP,
+				hold_expires_at = NULL
+			WHERE id = $2`,
+			nullString(reason), item.BookingID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveBulkOperation stores the operation so it can be undone and records it in the audit log
+func (h *APIHandlers) saveBulkOperation(ctx context.Context, tx *sql.Tx, audit auditor, result *BulkResult) error {
+	id, err := newToken()
+	if err != nil {
+		return err
+	}
+	result.OperationID = id[:16]
+	undoExpiresAt := time.Now().Add(h.bulkUndoWindow()).UTC()
+	result.UndoExpiresAt = &undoExpiresAt
+
+	criteria, err := json.Marshal(result.Criteria)
+	if err != nil {
+		return err
+	}
+	affected, err := json.Marshal(result.Affected)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO bulk_operations (id, kind, criteria, affected, created_by, undo_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		result.OperationID, result.Kind, string(criteria), string(affected), audit.actor.String(), undoExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return audit.record(ctx, tx, AuditBulkApplied, "bulk_operation", result.OperationID, nil, result)
+}
+
+// bulkOperation is a stored bulk operation
+type bulkOperation struct {
+	ID            string
+	Kind          string
+	Affected      []BulkItem
+	UndoExpiresAt time.Time
+	UndoneAt      sql.NullTime
+	FinalizedAt   sql.NullTime
+}
+
+func loadBulkOperation(ctx context.Context, q queryRower, id string, lock bool) (*bulkOperation, error) {
+	query := "SELECT id, kind, affected, undo_expires_at, undone_at, finalized_at FROM bulk_operations WHERE id = $1"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var op bulkOperation
+	var affected string
+	err := q.QueryRowContext(ctx, query, id).Scan(&op.ID, &op.Kind, &affected, &op.UndoExpiresAt, &op.UndoneAt, &op.FinalizedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrBulkOperationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(affected), &op.Affected); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// undoBulkOperation reverts a bulk operation inside its undo window
+func (h *APIHandlers) undoBulkOperation(ctx context.Context, audit auditor, id string) (*bulkOperation, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	op, err := loadBulkOperation(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if op.UndoneAt.Valid || op.FinalizedAt.Valid || time.Now().After(op.UndoExpiresAt) {
+		return op, ErrBulkUndoExpired
+	}
+
+	for _, item := range op.Affected {
+		switch op.Kind {
+		case BulkBlock:
+			_, err = tx.ExecContext(ctx, "DELETE FROM blocked_slots WHERE id = $1", item.BlockedSlotID)
+		case BulkUnblock:
+			_, err = tx.ExecContext(ctx, "INSERT INTO blocked_slots (slot_time) VALUES ($1) ON CONFLICT (slot_time) DO NOTHING", item.SlotTime)
+		case BulkCancel:
+			_, err = tx.ExecContext(ctx, `
+				UPDATE bookings SET
+					status = $1,
+					status_reason = NULL,
+					status_updated_at = CURRENT_TIMESTAMP,
+					cancelled_at = NULL,
+					hold_expires_at = $2
+				WHERE id = $3 AND status = 'cancelled_by_coach'`,
+				item.PreviousStatus, item.HoldExpiresAt, item.BookingID,
+			)
+			if isUniqueViolation(err) {
+				return op, ErrBulkUndoConflict
+			}
+		}
+		if err != nil {
+			return op, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE bulk_operations SET undone_at = CURRENT_TIMESTAMP WHERE id = $1", id); err != nil {
+		return op, err
+	}
+	after := map[string]interface{}{"kind": op.Kind, "count": len(op.Affected)}
+	if err := audit.record(ctx, tx, AuditBulkUndone, "bulk_operation", id, nil, after); err != nil {
+		return op, err
+	}
+
+	return op, tx.Commit()
+}
+
+// UndoBulkOperation reverts a bulk operation by its operation_id
+func (h *APIHandlers) UndoBulkOperation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OperationID string `json:"operation_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OperationID == "" {
+		http.Error(w, "operation_id is required", http.StatusBadRequest)
+		return
+	}
+
+	op, err := h.undoBulkOperation(r.Context(), auditorFromRequest(r), req.OperationID)
+	switch {
+	case errors.Is(err, ErrBulkOperationNotFound):
+		http.Error(w, "Bulk operation not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrBulkUndoExpired):
+		http.Error(w, "Bulk operation was already undone or its undo window has passed", http.StatusConflict)
+		return
+	case errors.Is(err, ErrBulkUndoConflict):
+		http.Error(w, "Cannot undo: a cancelled slot has been booked again", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to undo bulk operation", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error undoing bulk operation", "operation_id", req.OperationID, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Bulk operation undone", "kind", op.Kind, "operation_id", op.ID, "count", len(op.Affected))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Bulk operation undone",
+		"operation_id": op.ID,
+		"kind":         op.Kind,
+		"count":        len(op.Affected),
+	})
+}
+
+// FinalizeBulkOperations deletes the Zoom meetings of bookings cancelled in bulk and refunds
+// their payments once the undo window has passed. It returns the number of operations finalized.
+func (h *APIHandlers) FinalizeBulkOperations(ctx context.Context) (int, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	rows, err := h.DB.QueryContext(dbCtx, `SELECT id FROM bulk_operations
+		WHERE kind = 'cancel' AND undone_at IS NULL AND finalized_at IS NULL AND undo_expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		// Claim the operation first so an undo cannot race with the Zoom deletions
+		op, err := h.claimBulkOperation(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if op == nil {
+			continue
+		}
+		for _, item := range op.Affected {
+			h.deleteZoomMeeting(ctx, item.ZoomLink)
+			h.settleCoachCancellation(ctx, systemAuditor("bulk"), item.BookingID)
+		}
+		// The cancellations are final now, so their slots can go to the waitlist
+		for _, item := range op.Affected {
+			if err := h.offerSlotToWaitlist(ctx, item.SlotTime); err != nil {
+				slog.WarnContext(ctx, "Failed to offer freed slot to the waitlist", "error", err)
+			}
+		}
+	}
+	return len(ids), nil
+}
+
+// claimBulkOperation marks a cancel operation as finalized and loads it. It returns nil if
+// the operation was undone or claimed by another instance in the meantime.
+func (h *APIHandlers) claimBulkOperation(ctx context.Context, id string) (*bulkOperation, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	result, err := h.DB.ExecContext(ctx,
+		"UPDATE bulk_operations SET finalized_at = CURRENT_TIMESTAMP WHERE id = $1 AND undone_at IS NULL AND finalized_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return loadBulkOperation(ctx, h.DB, id, false)
+}
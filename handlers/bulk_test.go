@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLocation returns the coach's time zone, in which the DST tests are written
+func testLocation(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return loc
+}
+
+func TestSlotFilterParse(t *testing.T) {
+	loc := testLocation(t)
+
+	tests := []struct {
+		name         string
+		filter       SlotFilter
+		requireRange bool
+		wantErr      string
+	}{
+		{name: "range", filter: SlotFilter{From: "2026-03-02", To: "2026-03-08"}, requireRange: true},
+		{name: "single day", filter: SlotFilter{From: "2026-03-02", To: "2026-03-02"}, requireRange: true},
+		{name: "open range allowed", filter: SlotFilter{}},
+		{name: "open range required", filter: SlotFilter{}, requireRange: true, wantErr: "from and to are required"},
+		{name: "missing to", filter: SlotFilter{From: "2026-03-02"}, requireRange: true, wantErr: "from and to are required"},
+		{name: "range too long", filter: SlotFilter{From: "2026-01-01", To: "2027-01-02"}, requireRange: true, wantErr: "may not exceed 366 days"},
+		{name: "from after to", filter: SlotFilter{From: "2026-03-08", To: "2026-03-02"}, wantErr: "from must not be after to"},
+		{name: "bad from", filter: SlotFilter{From: "02.03.2026"}, wantErr: "invalid from date"},
+		{name: "bad to", filter: SlotFilter{To: "2026-3-8"}, wantErr: "invalid to date"},
+		{name: "weekdays", filter: SlotFilter{Weekdays: []int{0, 6}}},
+		{name: "weekday out of range", filter: SlotFilter{Weekdays: []int{7}}, wantErr: "weekdays must be between"},
+		{name: "time window", filter: SlotFilter{StartTime: "12:00", EndTime: "24:00"}},
+		{name: "bad start", filter: SlotFilter{StartTime: "9am"}, wantErr: "invalid start_time"},
+		{name: "bad end", filter: SlotFilter{EndTime: "25:00"}, wantErr: "invalid end_time"},
+		{name: "empty window", filter: SlotFilter{StartTime: "12:00", EndTime: "12:00"}, wantErr: "start_time must be before end_time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.filter.parse(loc, tt.requireRange)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBulkCriteriaMatches(t *testing.T) {
+	loc := testLocation(t)
+	filter := SlotFilter{From: "2026-03-02", To: "2026-03-08", Weekdays: []int{1, 3}, StartTime: "10:00", EndTime: "12:00"}
+	criteria, err := filter.parse(loc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		slot time.Time
+		want bool
+	}{
+		{"monday in window", time.Date(2026, 3, 2, 10, 0, 0, 0, loc), true},
+		{"last slot of window", time.Date(2026, 3, 4, 11, 30, 0, 0, loc), true},
+		{"end is exclusive", time.Date(2026, 3, 2, 12, 0, 0, 0, loc), false},
+		{"before window", time.Date(2026, 3, 2, 9, 30, 0, 0, loc), false},
+		{"other weekday", time.Date(2026, 3, 3, 10, 0, 0, 0, loc), false},
+		{"before from", time.Date(2026, 2, 23, 10, 0, 0, 0, loc), false},
+		{"after to", time.Date(2026, 3, 9, 10, 0, 0, 0, loc), false},
+		// 09:00 UTC is 10:00 in Amsterdam in winter: the window is in the coach's time zone
+		{"UTC input", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := criteria.matches(tt.slot); got != tt.want {
+				t.Errorf("matches(%s) = %v, want %v", tt.slot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBulkCriteriaSlots(t *testing.T) {
+	loc := testLocation(t)
+
+	tests := []struct {
+		name      string
+		filter    SlotFilter
+		wantCount int
+		wantFirst time.Time
+		wantLast  time.Time
+	}{
+		{
+			name:      "one day, default window",
+			filter:    SlotFilter{From: "2026-03-02", To: "2026-03-02"},
+			wantCount: 23, // 09:00 to 20:00 every 30 minutes
+			wantFirst: time.Date(2026, 3, 2, 9, 0, 0, 0, loc),
+			wantLast:  time.Date(2026, 3, 2, 20, 0, 0, 0, loc),
+		},
+		{
+			name:      "weekdays only",
+			filter:    SlotFilter{From: "2026-03-02", To: "2026-03-08", Weekdays: []int{6}, StartTime: "10:00", EndTime: "11:00"},
+			wantCount: 2,
+			wantFirst: time.Date(2026, 3, 7, 10, 0, 0, 0, loc),
+			wantLast:  time.Date(2026, 3, 7, 10, 30, 0, 0, loc),
+		},
+		{
+			// Clocks go forward on 29 March: local times stay on the grid, UTC shifts by an hour
+			name:      "across the DST change",
+			filter:    SlotFilter{From: "2026-03-28", To: "2026-03-30", StartTime: "09:00", EndTime: "09:30"},
+			wantCount: 3,
+			wantFirst: time.Date(2026, 3, 28, 8, 0, 0, 0, time.UTC),
+			wantLast:  time.Date(2026, 3, 30, 7, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			criteria, err := tt.filter.parse(loc, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slots := criteria.slots()
+			if len(slots) != tt.wantCount {
+				t.Fatalf("got %d slots, want %d: %v", len(slots), tt.wantCount, slots)
+			}
+			if !slots[0].Equal(tt.wantFirst) || !slots[len(slots)-1].Equal(tt.wantLast) {
+				t.Errorf("slots run %s to %s, want %s to %s", slots[0], slots[len(slots)-1], tt.wantFirst, tt.wantLast)
+			}
+			for _, s := range slots {
+				if s.Location() != time.UTC {
+					t.Errorf("slot %s is not in UTC", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+// CaptchaVerifier checks the CAPTCHA token submitted with a public booking
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// NoopCaptchaVerifier accepts every request; used when no CAPTCHA provider is configured
+type NoopCaptchaVerifier struct{}
+
+func (NoopCaptchaVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	return nil
+}
+
+// StaticCaptchaVerifier accepts only a fixed token; useful for tests and local development
+type StaticCaptchaVerifier struct {
+	Token string
+}
+
+func (v StaticCaptchaVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" || token != v.Token {
+		return ErrCaptchaFailed
+	}
+	return nil
+}
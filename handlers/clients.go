@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audit actions for the client directory
+const (
+	AuditClientUpdated = "client.updated"
+	AuditClientMerged  = "client.merged"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientMerged   = errors.New("client has been merged into another client")
+)
+
+// Client is an entry of the client directory. Bookings are linked to clients by email.
+type Client struct {
+	ID           int       `json:"id"`
+	Email        string    `json:"email"`
+	Name         string    `json:"name"`
+	Phone        string    `json:"phone,omitempty"`
+	Tags         []string  `json:"tags"`
+	FirstSeenAt  time.Time `json:"first_seen_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	MergedIntoID int       `json:"merged_into_id,omitempty"`
+	BookingCount int       `json:"booking_count"`
+}
+
+// clientColumns is the column list scanned by scanClient
+const clientColumns = `c.id, c.email, c.name, c.phone, c.tags, c.first_seen_at, c.last_seen_at, c.merged_into_id,
+	(SELECT COUNT(*) FROM bookings WHERE client_id = c.id)`
+
+func scanClient(row rowScanner) (*Client, error) {
+	var c Client
+	var phone sql.NullString
+	var mergedInto sql.NullInt64
+	err := row.Scan(&c.ID, &c.Email, &c.Name, &phone, pq.Array(&c.Tags),
+		&c.FirstSeenAt, &c.LastSeenAt, &mergedInto, &c.BookingCount)
+	if err != nil {
+		return nil, err
+	}
+	c.Phone = phone.String
+	c.MergedIntoID = int(mergedInto.Int64)
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	return &c, nil
+}
+
+func loadClient(ctx context.Context, q queryRower, id int, lock bool) (*Client, error) {
+	query := "SELECT " + clientColumns + " FROM clients c WHERE c.id = $1"
+	if lock {
+		query += " FOR UPDATE OF c"
+	}
+	c, err := scanClient(q.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrClientNotFound
+	}
+	return c, err
+}
+
+// linkBookingClient attaches a booking to the client with its email, creating the client on
+// first contact and following merges. It returns the client ID.
+func linkBookingClient(ctx context.Context, tx *sql.Tx, bookingID int) (int, error) {
+	var clientID int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO clients (email, name)
+		SELECT LOWER(TRIM(email)), name FROM bookings WHERE id = $1
+		ON CONFLICT (email) DO UPDATE SET last_seen_at = CURRENT_TIMESTAMP
+		RETURNING COALESCE(merged_into_id, id)`,
+		bookingID,
+	).Scan(&clientID)
+	if err != nil {
+		return 0, err
+	}
+
+	// When the email belongs to a merged client, touch the client it was merged into
+	if _, err := tx.ExecContext(ctx, "UPDATE clients SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1", clientID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE bookings SET client_id = $1 WHERE id = $2", clientID, bookingID)
+	return clientID, err
+}
+
+// Clients serves /api/admin/clients. GET lists clients (q searches name, email and phone;
+// tag filters by tag; paginate with limit and cursor). POST updates a client's name, phone
+// and tags; private notes are kept encrypted through /api/admin/notes.
+func (h *APIHandlers) Clients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listClients(w, r)
+	case http.MethodPost:
+		h.updateClient(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIHandlers) listClients(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+	conditions := []string{"c.merged_into_id IS NULL"}
+	var args []interface{}
+
+	if value := q.Get("q"); value != "" {
+		args = append(args, "%"+strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)+"%")
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf("(c.name ILIKE $%d OR c.email ILIKE $%d OR c.phone ILIKE $%d)", n, n, n))
+	}
+	if value := q.Get("tag"); value != "" {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(c.tags)", len(args)))
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		id, err := strconv.Atoi(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf("c.id < $%d", len(args)))
+	}
+
+	limit := 50
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > 200 {
+			n = 200
+		}
+		limit = n
+	}
+
+	query := "SELECT " + clientColumns + " FROM clients c WHERE " + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY c.id DESC LIMIT %d", limit)
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying clients", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	clients := make([]Client, 0, limit)
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning client", "error", err)
+			return
+		}
+		clients = append(clients, *c)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying clients", "error", err)
+		return
+	}
+
+	response := map[string]interface{}{"clients": clients}
+	if len(clients) == limit {
+		response["next_cursor"] = strconv.Itoa(clients[len(clients)-1].ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *APIHandlers) updateClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	var req struct {
+		ID    int      `json:"id"`
+		Name  string   `json:"name"`
+		Phone string   `json:"phone"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ID == 0 || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "id and name are required", http.StatusBadRequest)
+		return
+	}
+	tags := normalizeTags(req.Tags)
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := loadClient(ctx, tx, req.ID, true)
+	if err == ErrClientNotFound {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error loading client", "client_id", req.ID, "error", err)
+		return
+	}
+	if before.MergedIntoID != 0 {
+		http.Error(w, fmt.Sprintf("Client was merged into client %d", before.MergedIntoID), http.StatusConflict)
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE clients SET name = $1, phone = $2, tags = $3 WHERE id = $4",
+		strings.TrimSpace(req.Name), nullString(strings.TrimSpace(req.Phone)), pq.Array(tags), req.ID)
+	if err != nil {
+		http.Error(w, "Failed to update client", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error updating client", "client_id", req.ID, "error", err)
+		return
+	}
+
+	after, err := loadClient(ctx, tx, req.ID, false)
+	if err == nil {
+		err = auditorFromRequest(r).record(ctx, tx, AuditClientUpdated, "client", strconv.Itoa(req.ID), before, after)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to update client", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error updating client", "client_id", req.ID, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
+
+// normalizeTags trims, deduplicates and sorts tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ClientHistory returns a client (?id=) with every booking linked to it, newest first,
+// and the number of bookings in each status
+func (h *APIHandlers) ClientHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	client, err := loadClient(ctx, h.DB, id, false)
+	if err == ErrClientNotFound {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error loading client", "client_id", id, "error", err)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx, "SELECT "+bookingDetailsColumns+" FROM bookings WHERE client_id = $1 ORDER BY slot_time DESC, id DESC", id)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings for client", "client_id", id, "error", err)
+		return
+	}
+	defer rows.Close()
+
+	bookings := []BookingDetails{}
+	statusCounts := make(map[string]int)
+	for rows.Next() {
+		b, err := scanBookingDetails(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning booking", "error", err)
+			return
+		}
+		bookings = append(bookings, *b)
+		statusCounts[b.Status]++
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying bookings for client", "client_id", id, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"client":        client,
+		"bookings":      bookings,
+		"status_counts": statusCounts,
+	})
+}
+
+// mergeClients moves everything from source into target: bookings and session notes are
+// relinked, tags combined and the first/last seen range widened. The source row is kept,
+// pointing at the target, so its email keeps resolving to the merged client.
+func (h *APIHandlers) mergeClients(ctx context.Context, audit auditor, sourceID, targetID int) (*Client, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock in ID order so concurrent merges cannot deadlock
+	first, second := sourceID, targetID
+	if first > second {
+		first, second = second, first
+	}
+	if _, err := loadClient(ctx, tx, first, true); err != nil {
+		return nil, err
+	}
+	if _, err := loadClient(ctx, tx, second, true); err != nil {
+		return nil, err
+	}
+
+	source, err := loadClient(ctx, tx, sourceID, false)
+	if err != nil {
+		return nil, err
+	}
+	target, err := loadClient(ctx, tx, targetID, false)
+	if err != nil {
+		return nil, err
+	}
+	if source.MergedIntoID != 0 || target.MergedIntoID != 0 {
+		return nil, ErrClientMerged
+	}
+
+	tags := normalizeTags(append(append([]string{}, target.Tags...), source.Tags...))
+	phone := target.Phone
+	if phone == "" {
+		phone = source.Phone
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE bookings SET client_id = $1 WHERE client_id = $2", []interface{}{targetID, sourceID}},
+		{"UPDATE session_notes SET client_id = $1 WHERE client_id = $2", []interface{}{targetID, sourceID}},
+		{`UPDATE clients SET tags = $1, phone = $2,
+			first_seen_at = LEAST(first_seen_at, $3), last_seen_at = GREATEST(last_seen_at, $4)
+			WHERE id = $5`,
+			[]interface{}{pq.Array(tags), nullString(phone), source.FirstSeenAt, source.LastSeenAt, targetID}},
+		{"UPDATE clients SET merged_into_id = $1 WHERE id = $2 OR merged_into_id = $2", []interface{}{targetID, sourceID}},
+	}
+	for _, s := range statements {
+		if _, err := tx.ExecContext(ctx, s.query, s.args...); err != nil {
+			return nil, err
+		}
+	}
+
+	merged, err := loadClient(ctx, tx, targetID, false)
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]interface{}{"source": source, "target": target}
+	if err := audit.record(ctx, tx, AuditClientMerged, "client", strconv.Itoa(targetID), before, merged); err != nil {
+		return nil, err
+	}
+
+	return merged, tx.Commit()
+}
+
+// MergeClients merges a duplicate client (source_id) into another (target_id)
+func (h *APIHandlers) MergeClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SourceID int `json:"source_id"`
+		TargetID int `json:"target_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SourceID == 0 || req.TargetID == 0 || req.SourceID == req.TargetID {
+		http.Error(w, "source_id and target_id are required and must differ", http.StatusBadRequest)
+		return
+	}
+
+	merged, err := h.mergeClients(r.Context(), auditorFromRequest(r), req.SourceID, req.TargetID)
+	switch {
+	case errors.Is(err, ErrClientNotFound):
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrClientMerged):
+		http.Error(w, "Client has already been merged", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to merge clients", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error merging clients", "source_id", req.SourceID, "target_id", req.TargetID, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Clients merged", "source_id", req.SourceID, "target_id", req.TargetID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
+}
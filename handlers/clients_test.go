@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"nil", nil, []string{}},
+		{"sorted", []string{"vip", "corporate"}, []string{"corporate", "vip"}},
+		{"trimmed", []string{"  vip ", "\tcorporate"}, []string{"corporate", "vip"}},
+		{"blank dropped", []string{"", "  ", "vip"}, []string{"vip"}},
+		{"duplicates dropped", []string{"vip", " vip", "vip "}, []string{"vip"}},
+		{"case kept", []string{"VIP", "vip"}, []string{"VIP", "vip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTags(tt.tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
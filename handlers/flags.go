@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Feature flags the coach can switch from /admin without a redeploy
+const (
+	FlagBookingOpen           = "booking_open"            // clients can book sessions
+	FlagMaintenanceMode       = "maintenance_mode"        // public pages and API answer 503
+	FlagCreateZoomMeeting     = "create_zoom_meeting"     // create a Zoom meeting for each booking
+	FlagSendConfirmationEmail = "send_confirmation_email" // send booking emails to clients
+)
+
+// featureFlagDefaults lists every flag with its default when the configuration sets none
+var featureFlagDefaults = []struct {
+	key     string
+	enabled bool
+}{
+	{FlagBookingOpen, true},
+	{FlagMaintenanceMode, false},
+	{FlagCreateZoomMeeting, false},
+	{FlagSendConfirmationEmail, false},
+}
+
+// AuditFeatureFlagChanged records a flag switched or reset from /admin
+const AuditFeatureFlagChanged = "feature_flag.changed"
+
+// DefaultFeatureFlagTTL is how long flag values are cached when FeatureFlags.TTL is unset
+const DefaultFeatureFlagTTL = 15 * time.Second
+
+// FeatureFlag is the effective state of a flag as shown in /admin
+type FeatureFlag struct {
+	Key        string     `json:"key"`
+	Enabled    bool       `json:"enabled"`
+	Default    bool       `json:"default"`    // value from the configuration
+	Overridden bool       `json:"overridden"` // set from /admin rather than the default
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy  string     `json:"updated_by,omitempty"`
+}
+
+// FeatureFlags holds the runtime switches. Values set from /admin are stored in
+// feature_flags and shared by all instances; flags without a row use Defaults. Reads are
+// cached for TTL, so a change reaches every instance within TTL.
+type FeatureFlags struct {
+	DB       *sql.DB
+	Defaults map[string]bool
+	TTL      time.Duration // 0 uses DefaultFeatureFlagTTL
+	Timeout  time.Duration // bounds the refresh query; 0 uses DefaultDBTimeout
+
+	mu       sync.Mutex
+	stored   map[string]FeatureFlag
+	loadedAt time.Time
+}
+
+// NewFeatureFlags returns flags stored in db. defaults override the built-in defaults:
+// bookings open, everything else off.
+func NewFeatureFlags(db *sql.DB, defaults map[string]bool) *FeatureFlags {
+	merged := make(map[string]bool, len(featureFlagDefaults))
+	for _, d := range featureFlagDefaults {
+		merged[d.key] = d.enabled
+	}
+	for key, enabled := range defaults {
+		merged[key] = enabled
+	}
+	return &FeatureFlags{DB: db, Defaults: merged}
+}
+
+// Enabled reports whether the flag is on. If the flags cannot be read from the database
+// the last known values are used, or the defaults.
+func (f *FeatureFlags) Enabled(ctx context.Context, key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ttl := f.TTL
+	if ttl <= 0 {
+		ttl = DefaultFeatureFlagTTL
+	}
+	if time.Since(f.loadedAt) >= ttl {
+		if err := f.refresh(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to refresh feature flags, using cached values", "error", err)
+		}
+	}
+
+	if flag, ok := f.stored[key]; ok {
+		return flag.Enabled
+	}
+	return f.Defaults[key]
+}
+
+// List returns every flag, read fresh from the database
+func (f *FeatureFlags) List(ctx context.Context) ([]FeatureFlag, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	flags := make([]FeatureFlag, 0, len(featureFlagDefaults))
+	for _, d := range featureFlagDefaults {
+		flags = append(flags, f.effective(d.key))
+	}
+	return flags, nil
+}
+
+// effective returns the state of key from the cache. The caller holds f.mu.
+func (f *FeatureFlags) effective(key string) FeatureFlag {
+	if flag, ok := f.stored[key]; ok {
+		flag.Default = f.Defaults[key]
+		return flag
+	}
+	return FeatureFlag{Key: key, Enabled: f.Defaults[key], Default: f.Defaults[key]}
+}
+
+// refresh reloads the stored flags. The cache is shared by all requests, so the query is
+// not cancelled with the client that triggered it; on failure it is retried after TTL.
+// The caller holds f.mu.
+func (f *FeatureFlags) refresh(ctx context.Context) error {
+	f.loadedAt = time.Now()
+
+	timeout := f.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDBTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	rows, err := f.DB.QueryContext(ctx, "SELECT key, enabled, updated_at, updated_by FROM feature_flags")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]FeatureFlag)
+	for rows.Next() {
+		var flag FeatureFlag
+		var updatedAt time.Time
+		if err := rows.Scan(&flag.Key, &flag.Enabled, &updatedAt, &flag.UpdatedBy); err != nil {
+			return err
+		}
+		flag.Overridden = true
+		flag.UpdatedAt = &updatedAt
+		stored[flag.Key] = flag
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	f.stored = stored
+	return nil
+}
+
+// invalidate makes the next read go to the database, so this instance sees its own change at once
+func (f *FeatureFlags) invalidate() {
+	f.mu.Lock()
+	f.loadedAt = time.Time{}
+	f.mu.Unlock()
+}
+
+// known reports whether key names a flag
+func (f *FeatureFlags) known(key string) bool {
+	_, ok := f.Defaults[key]
+	return ok
+}
+
+// FeatureFlagRequest switches a flag on or off, or with Reset returns it to its default
+type FeatureFlagRequest struct {
+	Key     string `json:"key"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	Reset   bool   `json:"reset,omitempty"`
+}
+
+// FeatureFlags lists the flags (GET) or switches one (POST)
+func (h *APIHandlers) FeatureFlags(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		flags, err := h.Flags.List(r.Context())
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error querying feature flags", "error", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(flags)
+
+	case http.MethodPost:
+		var req FeatureFlagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !h.Flags.known(req.Key) {
+			http.Error(w, "Unknown feature flag", http.StatusBadRequest)
+			return
+		}
+		if (req.Enabled == nil) == !req.Reset {
+			http.Error(w, "Set either enabled or reset", http.StatusBadRequest)
+			return
+		}
+
+		flag, err := h.saveFeatureFlag(r.Context(), auditorFromRequest(r), req)
+		if err != nil {
+			http.Error(w, "Failed to save feature flag", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error saving feature flag", "flag", req.Key, "error", err)
+			return
+		}
+		slog.InfoContext(r.Context(), "Feature flag changed", "flag", flag.Key, "enabled", flag.Enabled, "overridden", flag.Overridden)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(flag)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveFeatureFlag stores or resets a flag, records the change in the audit log and returns
+// the new effective state
+func (h *APIHandlers) saveFeatureFlag(ctx context.Context, audit auditor, req FeatureFlagRequest) (FeatureFlag, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+	defer tx.Rollback()
+
+	before := FeatureFlag{Key: req.Key, Enabled: h.Flags.Defaults[req.Key], Default: h.Flags.Defaults[req.Key]}
+	var updatedAt time.Time
+	err = tx.QueryRowContext(ctx,
+		"SELECT enabled, updated_at, updated_by FROM feature_flags WHERE key = $1 FOR UPDATE", req.Key,
+	).Scan(&before.Enabled, &updatedAt, &before.UpdatedBy)
+	if err == nil {
+		before.Overridden = true
+		before.UpdatedAt = &updatedAt
+	} else if err != sql.ErrNoRows {
+		return FeatureFlag{}, err
+	}
+
+	after := FeatureFlag{Key: req.Key, Enabled: h.Flags.Defaults[req.Key], Default: h.Flags.Defaults[req.Key]}
+	if req.Reset {
+		_, err = tx.ExecContext(ctx, "DELETE FROM feature_flags WHERE key = $1", req.Key)
+	} else {
+		after.Enabled = *req.Enabled
+		after.Overridden = true
+		after.UpdatedBy = audit.actor.String()
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO feature_flags (key, enabled, updated_by) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET
+				enabled = EXCLUDED.enabled,
+				updated_at = CURRENT_TIMESTAMP,
+				updated_by = EXCLUDED.updated_by
+			RETURNING updated_at`,
+			req.Key, after.Enabled, after.UpdatedBy,
+		).Scan(&updatedAt)
+		after.UpdatedAt = &updatedAt
+	}
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+
+	if err := audit.record(ctx, tx, AuditFeatureFlagChanged, "feature_flag", req.Key, before, after); err != nil {
+		return FeatureFlag{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return FeatureFlag{}, err
+	}
+
+	h.Flags.invalidate()
+	return after, nil
+}
+
+// UnlessMaintenance serves next unless maintenance mode is on, when clients get 503 with a
+// Retry-After. Only public routes are wrapped: the admin area stays usable to switch it
+// off, and health checks and payment webhooks keep working.
+func (h *APIHandlers) UnlessMaintenance(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.Flags.Enabled(r.Context(), FlagMaintenanceMode) {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "300")
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			http.Error(w, "Service is under maintenance, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, maintenancePage)
+	}
+}
+
+const maintenancePage = `<!DOCTYPE html>
+<html lang="uk">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Технічні роботи - Календар тренера</title>
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; text-align: center; padding: 80px 20px; color: #333;">
+    <h1>Проводяться технічні роботи</h1>
+    <p>Бронювання тимчасово недоступне. Будь ласка, спробуйте трохи пізніше.</p>
+</body>
+</html>
+`
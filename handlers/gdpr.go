@@ -0,0 +1,863 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audit actions for data protection requests and the retention job
+const (
+	AuditClientErased      = "client.erased"
+	AuditBookingAnonymized = "booking.anonymized"
+)
+
+// Placeholders written over erased personal data
+const (
+	ErasedName  = "Видалений клієнт"
+	ErasedEmail = "erased@erased.invalid"
+	erasedValue = "[erased]"
+)
+
+// retentionBatch bounds how many bookings and clients one retention run anonymizes
+const retentionBatch = 100
+
+// maxZoomScrubAttempts is how many retention runs try to scrub a booking's Zoom meeting
+// before leaving the booking for the coach to handle by hand
+const maxZoomScrubAttempts = 5
+
+var (
+	ErrNoPersonalData   = errors.New("no data stored for this email")
+	ErrUpcomingBookings = errors.New("client has upcoming bookings")
+	ErrZoomScrubFailed  = errors.New("failed to scrub Zoom meeting")
+)
+
+// personalKeys are the fields of audit states that hold personal data
+var personalKeys = map[string]bool{
+	"name":          true,
+	"email":         true,
+	"client_email":  true,
+	"phone":         true,
+	"notes":         true,
+	"tags":          true,
+	"zoom_link":     true,
+	"status_reason": true,
+	"reason":        true,
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// eachRow runs query and calls scan for every row
+func eachRow(ctx context.Context, q querier, query string, args []interface{}, scan func(row rowScanner) error) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func queryIDs(ctx context.Context, q querier, query string, args ...interface{}) ([]int64, error) {
+	ids := []int64{}
+	err := eachRow(ctx, q, query, args, func(row rowScanner) error {
+		var id int64
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+func idStrings(ids []int64) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = strconv.FormatInt(id, 10)
+	}
+	return result
+}
+
+// dataSubject is everything stored about one person: their client and any clients merged
+// with it, and every record kept under one of those clients' emails
+type dataSubject struct {
+	Emails      []string
+	ClientIDs   []int64
+	BookingIDs  []int64
+	WaitlistIDs []int64
+	PaymentIDs  []int64
+	PackageIDs  []int64
+}
+
+func (s *dataSubject) empty() bool {
+	return len(s.ClientIDs) == 0 && len(s.BookingIDs) == 0 && len(s.WaitlistIDs) == 0 &&
+		len(s.PaymentIDs) == 0 && len(s.PackageIDs) == 0
+}
+
+// auditEntities maps the audit entity types that carry the subject's data to their IDs
+func (s *dataSubject) auditEntities() map[string][]string {
+	return map[string][]string{
+		"client":         idStrings(s.ClientIDs),
+		"booking":        idStrings(s.BookingIDs),
+		"waitlist_entry": idStrings(s.WaitlistIDs),
+		"payment":        idStrings(s.PaymentIDs),
+		"package":        idStrings(s.PackageIDs),
+	}
+}
+
+func findDataSubject(ctx context.Context, q querier, email string) (*dataSubject, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	s := &dataSubject{Emails: []string{email}}
+	if email == ErasedEmail {
+		// The placeholder of anonymized records belongs to no one
+		return s, nil
+	}
+
+	var err error
+	s.ClientIDs, err = queryIDs(ctx, q,
+		`SELECT id FROM clients WHERE COALESCE(merged_into_id, id) IN
+			(SELECT COALESCE(merged_into_id, id) FROM clients WHERE email = $1)`,
+		email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(ctx, q, "SELECT email FROM clients WHERE id = ANY($1) AND email <> $2", []interface{}{pq.Array(s.ClientIDs), email},
+		func(row rowScanner) error {
+			var other string
+			if err := row.Scan(&other); err != nil {
+				return err
+			}
+			s.Emails = append(s.Emails, other)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	emails := pq.Array(s.Emails)
+	if s.BookingIDs, err = queryIDs(ctx, q,
+		"SELECT id FROM bookings WHERE client_id = ANY($1) OR LOWER(TRIM(email)) = ANY($2)",
+		pq.Array(s.ClientIDs), emails,
+	); err != nil {
+		return nil, err
+	}
+	if s.WaitlistIDs, err = queryIDs(ctx, q, "SELECT id FROM waitlist_entries WHERE LOWER(TRIM(email)) = ANY($1)", emails); err != nil {
+		return nil, err
+	}
+	if s.PaymentIDs, err = queryIDs(ctx, q,
+		"SELECT id FROM payments WHERE booking_id = ANY($1) OR LOWER(TRIM(email)) = ANY($2)",
+		pq.Array(s.BookingIDs), emails,
+	); err != nil {
+		return nil, err
+	}
+	if s.PackageIDs, err = queryIDs(ctx, q, "SELECT id FROM packages WHERE LOWER(client_email) = ANY($1)", emails); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// subjectAuditQuery selects the audit events about the subject's records or mentioning one
+// of their emails
+func subjectAuditQuery(entities map[string][]string, emails []string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for entityType, ids := range entities {
+		if len(ids) == 0 {
+			continue
+		}
+		args = append(args, entityType, pq.Array(ids))
+		conditions = append(conditions, fmt.Sprintf("(entity_type = $%d AND entity_id = ANY($%d))", len(args)-1, len(args)))
+	}
+	if len(emails) > 0 {
+		escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+		patterns := make([]string, len(emails))
+		for i, email := range emails {
+			patterns[i] = "%" + escape.Replace(email) + "%"
+		}
+		args = append(args, pq.Array(patterns))
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf("before_state::text ILIKE ANY($%d) OR after_state::text ILIKE ANY($%d)", n, n))
+	}
+	if len(conditions) == 0 {
+		conditions = append(conditions, "FALSE")
+	}
+	return "SELECT " + auditEventColumns + " FROM audit_events WHERE " + strings.Join(conditions, " OR ") + " ORDER BY id", args
+}
+
+// redactAuditEvents blanks the subject's personal data in the audit log. The events stay,
+// so the log still shows what happened and who did it, but no longer to whom.
+func redactAuditEvents(ctx context.Context, tx *sql.Tx, entities map[string][]string, emails []string) (int64, error) {
+	query, args := subjectAuditQuery(entities, emails)
+	var events []*AuditEvent
+	err := eachRow(ctx, tx, query, args, func(row rowScanner) error {
+		e, err := scanAuditEvent(row)
+		if err == nil {
+			events = append(events, e)
+		}
+		return err
+	})
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	// audit_events is append-only; this setting lets the trigger accept redactions in this transaction
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.audit_redaction', 'on', true)"); err != nil {
+		return 0, err
+	}
+
+	var redacted int64
+	for _, e := range events {
+		own := false
+		for _, id := range entities[e.EntityType] {
+			if id == e.EntityID {
+				own = true
+				break
+			}
+		}
+		before, err := redactAuditState(e.Before, emails, own)
+		if err != nil {
+			return 0, err
+		}
+		after, err := redactAuditState(e.After, emails, own)
+		if err != nil {
+			return 0, err
+		}
+		ip := e.IP
+		if e.ActorType == ActorClient {
+			ip = ""
+		}
+		if string(before) == string(e.Before) && string(after) == string(e.After) && ip == e.IP {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE audit_events SET before_state = $1, after_state = $2, ip = $3 WHERE id = $4",
+			nullString(string(before)), nullString(string(after)), nullString(ip), e.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to redact audit event %d: %w", e.ID, err)
+		}
+		redacted++
+	}
+	return redacted, nil
+}
+
+func redactAuditState(state json.RawMessage, emails []string, own bool) (json.RawMessage, error) {
+	if len(state) == 0 {
+		return state, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(state, &v); err != nil {
+		return nil, err
+	}
+	redacted, err := json.Marshal(redactValue(v, emails, own))
+	if err != nil {
+		return nil, err
+	}
+	if jsonEqual(state, redacted) {
+		return state, nil
+	}
+	return redacted, nil
+}
+
+// jsonEqual compares two JSON documents ignoring formatting; JSONB output is not
+// byte-for-byte what encoding/json produces
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return string(ca) == string(cb)
+}
+
+// redactValue erases the personal fields of every object that belongs to the subject
+// (the event's own entity when own is set, or any object carrying one of their emails)
+// and any other string containing one of their emails
+func redactValue(v interface{}, emails []string, own bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if own || objectHasEmail(t, emails) {
+			for key := range t {
+				if personalKeys[key] && t[key] != nil {
+					t[key] = erasedValue
+				}
+			}
+		}
+		for key, child := range t {
+			t[key] = redactValue(child, emails, false)
+		}
+		return t
+	case []interface{}:
+		for i, child := range t {
+			t[i] = redactValue(child, emails, false)
+		}
+		return t
+	case string:
+		lower := strings.ToLower(t)
+		for _, email := range emails {
+			if strings.Contains(lower, email) {
+				return erasedValue
+			}
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func objectHasEmail(object map[string]interface{}, emails []string) bool {
+	for _, key := range []string{"email", "client_email"} {
+		value, ok := object[key].(string)
+		if !ok {
+			continue
+		}
+		for _, email := range emails {
+			if strings.EqualFold(strings.TrimSpace(value), email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scrubZoomMeetings removes client details from the Zoom meetings of bookings, returning the
+// IDs of the bookings whose meetings were scrubbed. It stops at the first failure unless
+// keepGoing is set, in which case failures are logged and counted on the booking.
+func (h *APIHandlers) scrubZoomMeetings(ctx context.Context, bookingIDs []int64, keepGoing bool) (scrubbed []int64, err error) {
+	type meeting struct {
+		bookingID int64
+		link      string
+	}
+	var meetings []meeting
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+	err = eachRow(dbCtx, h.DB, "SELECT id, zoom_link FROM bookings WHERE id = ANY($1) AND zoom_link IS NOT NULL AND zoom_link <> ''",
+		[]interface{}{pq.Array(bookingIDs)},
+		func(row rowScanner) error {
+			var m meeting
+			if err := row.Scan(&m.bookingID, &m.link); err != nil {
+				return err
+			}
+			meetings = append(meetings, m)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range meetings {
+		if h.ZoomService != nil {
+			if err := h.ZoomService.ScrubMeeting(ctx, m.link); err != nil {
+				err = fmt.Errorf("%w for booking %d: %v", ErrZoomScrubFailed, m.bookingID, err)
+				if !keepGoing {
+					return scrubbed, err
+				}
+				h.recordZoomScrubFailure(ctx, m.bookingID, err)
+				continue
+			}
+		}
+		scrubbed = append(scrubbed, m.bookingID)
+	}
+	return scrubbed, nil
+}
+
+// recordZoomScrubFailure counts a failed scrub of a booking's Zoom meeting, so retention
+// stops retrying it after maxZoomScrubAttempts
+func (h *APIHandlers) recordZoomScrubFailure(ctx context.Context, bookingID int64, scrubErr error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	var failures int
+	err := h.DB.QueryRowContext(dbCtx,
+		"UPDATE bookings SET zoom_scrub_failures = zoom_scrub_failures + 1 WHERE id = $1 RETURNING zoom_scrub_failures",
+		bookingID,
+	).Scan(&failures)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording failed Zoom scrub", "booking_id", bookingID, "error", err)
+		return
+	}
+	if failures >= maxZoomScrubAttempts {
+		slog.ErrorContext(ctx, "Retention gave up on a booking whose Zoom meeting cannot be scrubbed; delete the meeting and erase the booking by hand",
+			"booking_id", bookingID, "attempts", failures, "error", scrubErr)
+		return
+	}
+	slog.WarnContext(ctx, "Retention could not scrub a Zoom meeting; retrying on the next run",
+		"booking_id", bookingID, "attempts", failures, "error", scrubErr)
+}
+
+// anonymizeBookings replaces the personal data of bookings with placeholders and detaches
+// them from their client. Slot, session type and status stay for the coach's statistics.
+// Notes on the bookings are deleted and their payments keep only the amounts.
+func anonymizeBookings(ctx context.Context, tx *sql.Tx, ids []int64) (bookings, notes int64, err error) {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE bookings SET name = $1, email = $2, zoom_link = NULL, status_reason = NULL, confirmation_token = NULL,
+			client_id = NULL, anonymized_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($3)`,
+		ErasedName, ErasedEmail, pq.Array(ids),
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	bookings, _ = res.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE booking_reschedules SET reason = NULL WHERE booking_id = ANY($1)", pq.Array(ids)); err != nil {
+		return 0, 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE payments SET email = $1 WHERE booking_id = ANY($2)", ErasedEmail, pq.Array(ids)); err != nil {
+		return 0, 0, err
+	}
+	res, err = tx.ExecContext(ctx, "DELETE FROM session_notes WHERE booking_id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, 0, err
+	}
+	notes, _ = res.RowsAffected()
+	return bookings, notes, nil
+}
+
+// ErasureReport counts what an erasure removed or anonymized
+type ErasureReport struct {
+	Clients         int64 `json:"clients"`
+	Bookings        int64 `json:"bookings"`
+	Notes           int64 `json:"notes"`
+	WaitlistEntries int64 `json:"waitlist_entries"`
+	Payments        int64 `json:"payments"`
+	Packages        int64 `json:"packages"`
+	AuditEvents     int64 `json:"audit_events"`
+	ZoomMeetings    int64 `json:"zoom_meetings"`
+}
+
+// eraseClient removes a person's data on request. Their clients, notes and waitlist
+// entries are deleted; bookings, payments and packages are kept for the coach's records
+// but anonymized (packages are voided); the audit log is redacted and Zoom meeting topics
+// scrubbed. Upcoming bookings must be cancelled first. When a Zoom meeting cannot be
+// scrubbed nothing is erased, so the request can simply be retried.
+func (h *APIHandlers) eraseClient(ctx context.Context, audit auditor, email string) (*ErasureReport, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	subject, err := findDataSubject(dbCtx, h.DB, email)
+	if err != nil {
+		return nil, err
+	}
+	if subject.empty() {
+		return nil, ErrNoPersonalData
+	}
+
+	var upcoming int
+	err = h.DB.QueryRowContext(dbCtx,
+		`SELECT COUNT(*) FROM bookings WHERE id = ANY($1) AND slot_time > CURRENT_TIMESTAMP
+			AND status IN ('pending', 'confirmed')`,
+		pq.Array(subject.BookingIDs),
+	).Scan(&upcoming)
+	if err != nil {
+		return nil, err
+	}
+	if upcoming > 0 {
+		return nil, ErrUpcomingBookings
+	}
+
+	scrubbed, err := h.scrubZoomMeetings(ctx, subject.BookingIDs, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dbCtx, cancel = h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &ErasureReport{ZoomMeetings: int64(len(scrubbed))}
+	if report.AuditEvents, err = redactAuditEvents(dbCtx, tx, subject.auditEntities(), subject.Emails); err != nil {
+		return nil, err
+	}
+	if report.Bookings, report.Notes, err = anonymizeBookings(dbCtx, tx, subject.BookingIDs); err != nil {
+		return nil, err
+	}
+
+	emailKeys := make([]string, len(subject.Emails))
+	for i, e := range subject.Emails {
+		emailKeys[i] = "email:" + e
+	}
+	statements := []struct {
+		count *int64
+		query string
+		args  []interface{}
+	}{
+		{&report.Payments, "UPDATE payments SET email = $1 WHERE id = ANY($2)", []interface{}{ErasedEmail, pq.Array(subject.PaymentIDs)}},
+		{&report.Packages, "UPDATE packages SET client_email = NULL, voided_at = COALESCE(voided_at, CURRENT_TIMESTAMP) WHERE id = ANY($1)",
+			[]interface{}{pq.Array(subject.PackageIDs)}},
+		{&report.WaitlistEntries, "DELETE FROM waitlist_entries WHERE id = ANY($1)", []interface{}{pq.Array(subject.WaitlistIDs)}},
+		{nil, "DELETE FROM rate_limit_buckets WHERE key = ANY($1)", []interface{}{pq.Array(emailKeys)}},
+		{nil, "UPDATE bookings SET client_id = NULL WHERE client_id = ANY($1)", []interface{}{pq.Array(subject.ClientIDs)}},
+		{&report.Notes, "DELETE FROM session_notes WHERE client_id = ANY($1)", []interface{}{pq.Array(subject.ClientIDs)}},
+		{&report.Clients, "DELETE FROM clients WHERE id = ANY($1)", []interface{}{pq.Array(subject.ClientIDs)}},
+	}
+	for _, s := range statements {
+		res, err := tx.ExecContext(dbCtx, s.query, s.args...)
+		if err != nil {
+			return nil, err
+		}
+		if s.count != nil {
+			n, _ := res.RowsAffected()
+			*s.count += n
+		}
+	}
+
+	entityID := ""
+	if len(subject.ClientIDs) > 0 {
+		entityID = strconv.FormatInt(subject.ClientIDs[0], 10)
+	}
+	if err := audit.record(dbCtx, tx, AuditClientErased, "client", entityID, nil, report); err != nil {
+		return nil, err
+	}
+	return report, tx.Commit()
+}
+
+// EraseClient serves POST /api/admin/gdpr/erase with {"email": ...}
+func (h *APIHandlers) EraseClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.eraseClient(r.Context(), auditorFromRequest(r), req.Email)
+	switch {
+	case errors.Is(err, ErrNoPersonalData):
+		http.Error(w, "No data stored for this email", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrUpcomingBookings):
+		http.Error(w, "Client has upcoming bookings; cancel them before erasing", http.StatusConflict)
+		return
+	case errors.Is(err, ErrZoomScrubFailed):
+		http.Error(w, "Failed to scrub Zoom meetings; nothing was erased, try again later", http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "Error erasing client data", "error", err)
+		return
+	case err != nil:
+		http.Error(w, "Failed to erase client data", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error erasing client data", "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Erased client data", "bookings", report.Bookings, "clients", report.Clients)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// DataExport is everything stored about one email address
+type DataExport struct {
+	Email       string           `json:"email"`
+	ExportedAt  time.Time        `json:"exported_at"`
+	Clients     []Client         `json:"clients"`
+	Bookings    []BookingDetails `json:"bookings"`
+	Reschedules []Reschedule     `json:"reschedules"`
+	Notes       []SessionNote    `json:"notes"`
+	// NotesEncrypted counts notes that could not be included because no encryption key is configured
+	NotesEncrypted int              `json:"notes_encrypted,omitempty"`
+	Waitlist       []WaitlistEntry  `json:"waitlist"`
+	Payments       []Payment        `json:"payments"`
+	Packages       []SessionPackage `json:"packages"`
+	AuditEvents    []AuditEvent     `json:"audit_events"`
+}
+
+func (h *APIHandlers) exportClientData(ctx context.Context, email string) (*DataExport, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	subject, err := findDataSubject(ctx, h.DB, email)
+	if err != nil {
+		return nil, err
+	}
+	if subject.empty() {
+		return nil, ErrNoPersonalData
+	}
+
+	export := &DataExport{
+		Email:       subject.Emails[0],
+		ExportedAt:  time.Now().UTC(),
+		Clients:     []Client{},
+		Bookings:    []BookingDetails{},
+		Reschedules: []Reschedule{},
+		Notes:       []SessionNote{},
+		Waitlist:    []WaitlistEntry{},
+		Payments:    []Payment{},
+		Packages:    []SessionPackage{},
+		AuditEvents: []AuditEvent{},
+	}
+	clientIDs, bookingIDs := pq.Array(subject.ClientIDs), pq.Array(subject.BookingIDs)
+
+	queries := []struct {
+		query string
+		args  []interface{}
+		scan  func(row rowScanner) error
+	}{
+		{"SELECT " + clientColumns + " FROM clients c WHERE c.id = ANY($1) ORDER BY c.id", []interface{}{clientIDs},
+			func(row rowScanner) error {
+				c, err := scanClient(row)
+				if err == nil {
+					export.Clients = append(export.Clients, *c)
+				}
+				return err
+			}},
+		{"SELECT " + bookingDetailsColumns + " FROM bookings WHERE id = ANY($1) ORDER BY slot_time, id", []interface{}{bookingIDs},
+			func(row rowScanner) error {
+				b, err := scanBookingDetails(row)
+				if err == nil {
+					export.Bookings = append(export.Bookings, *b)
+				}
+				return err
+			}},
+		{"SELECT " + rescheduleColumns + " FROM booking_reschedules WHERE booking_id = ANY($1) ORDER BY id", []interface{}{bookingIDs},
+			func(row rowScanner) error {
+				rs, err := scanReschedule(row)
+				if err == nil {
+					export.Reschedules = append(export.Reschedules, *rs)
+				}
+				return err
+			}},
+		{"SELECT " + waitlistEntryColumns + " FROM waitlist_entries WHERE id = ANY($1) ORDER BY id", []interface{}{pq.Array(subject.WaitlistIDs)},
+			func(row rowScanner) error {
+				e, err := scanWaitlistEntry(row)
+				if err == nil {
+					export.Waitlist = append(export.Waitlist, *e)
+				}
+				return err
+			}},
+		{"SELECT " + paymentColumns + " FROM payments WHERE id = ANY($1) ORDER BY id", []interface{}{pq.Array(subject.PaymentIDs)},
+			func(row rowScanner) error {
+				p, err := scanPayment(row)
+				if err == nil {
+					export.Payments = append(export.Payments, *p)
+				}
+				return err
+			}},
+		{"SELECT " + packageColumns + " FROM packages WHERE id = ANY($1) ORDER BY id", []interface{}{pq.Array(subject.PackageIDs)},
+			func(row rowScanner) error {
+				p, err := scanPackage(row)
+				if err == nil {
+					export.Packages = append(export.Packages, *p)
+				}
+				return err
+			}},
+	}
+
+	notesQuery := " FROM session_notes WHERE client_id = ANY($1) OR booking_id = ANY($2)"
+	if h.Notes != nil {
+		queries = append(queries, struct {
+			query string
+			args  []interface{}
+			scan  func(row rowScanner) error
+		}{"SELECT " + noteColumns + notesQuery + " ORDER BY id", []interface{}{clientIDs, bookingIDs},
+			func(row rowScanner) error {
+				n, err := h.scanNote(row)
+				if err == nil {
+					export.Notes = append(export.Notes, *n)
+				}
+				return err
+			}})
+	} else if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(*)"+notesQuery, clientIDs, bookingIDs).Scan(&export.NotesEncrypted); err != nil {
+		return nil, err
+	}
+
+	auditQuery, auditArgs := subjectAuditQuery(subject.auditEntities(), subject.Emails)
+	queries = append(queries, struct {
+		query string
+		args  []interface{}
+		scan  func(row rowScanner) error
+	}{auditQuery, auditArgs, func(row rowScanner) error {
+		e, err := scanAuditEvent(row)
+		if err == nil {
+			export.AuditEvents = append(export.AuditEvents, *e)
+		}
+		return err
+	}})
+
+	for _, q := range queries {
+		if err := eachRow(ctx, h.DB, q.query, q.args, q.scan); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// ExportClientData serves GET /api/admin/gdpr/export?email=..., returning everything
+// stored about the email as a JSON download
+func (h *APIHandlers) ExportClientData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+	if email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.exportClientData(r.Context(), email)
+	if errors.Is(err, ErrNoPersonalData) {
+		http.Error(w, "No data stored for this email", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to export client data", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error exporting client data", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="client-data.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+// ApplyRetention anonymizes what is older than RetentionMonths: past bookings, payments of
+// released holds and finished waitlist entries. Clients left with no bookings, no active
+// waitlist entry or package and no contact within the period are erased entirely. Bookings
+// whose Zoom meeting cannot be scrubbed are retried on later runs, up to
+// maxZoomScrubAttempts times.
+func (h *APIHandlers) ApplyRetention(ctx context.Context) (int, error) {
+	if h.RetentionMonths <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, -h.RetentionMonths, 0)
+	audit := systemAuditor("retention")
+
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	candidates, err := queryIDs(dbCtx, h.DB,
+		fmt.Sprintf(`SELECT id FROM bookings WHERE anonymized_at IS NULL AND status <> 'pending' AND slot_time < $1
+			AND zoom_scrub_failures < $2
+			ORDER BY slot_time LIMIT %d`, retentionBatch),
+		cutoff, maxZoomScrubAttempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	// Bookings without a meeting need no scrubbing; those after a failed scrub wait for the next run
+	bookingIDs, err := queryIDs(dbCtx, h.DB, "SELECT id FROM bookings WHERE id = ANY($1) AND COALESCE(zoom_link, '') = ''",
+		pq.Array(candidates))
+	if err != nil {
+		return 0, err
+	}
+	scrubbed, err := h.scrubZoomMeetings(ctx, candidates, true)
+	if err != nil {
+		return 0, err
+	}
+	bookingIDs = append(bookingIDs, scrubbed...)
+
+	dbCtx, cancel = h.dbContext(ctx)
+	defer cancel()
+
+	waitlistIDs, err := queryIDs(dbCtx, h.DB,
+		"SELECT id FROM waitlist_entries WHERE status NOT IN ('waiting', 'offered') AND created_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	paymentIDs, err := queryIDs(dbCtx, h.DB,
+		"SELECT id FROM payments WHERE booking_id IS NULL AND email <> $1 AND created_at < $2", ErasedEmail, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	entities := map[string][]string{
+		"booking":        idStrings(bookingIDs),
+		"waitlist_entry": idStrings(waitlistIDs),
+		"payment":        idStrings(paymentIDs),
+	}
+	if _, err := redactAuditEvents(dbCtx, tx, entities, nil); err != nil {
+		return 0, err
+	}
+	anonymized, _, err := anonymizeBookings(dbCtx, tx, bookingIDs)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(dbCtx, "DELETE FROM waitlist_entries WHERE id = ANY($1)", pq.Array(waitlistIDs)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(dbCtx, "UPDATE payments SET email = $1 WHERE id = ANY($2)", ErasedEmail, pq.Array(paymentIDs)); err != nil {
+		return 0, err
+	}
+	after := map[string]int{"retention_months": h.RetentionMonths}
+	for _, id := range bookingIDs {
+		if err := audit.record(dbCtx, tx, AuditBookingAnonymized, "booking", strconv.FormatInt(id, 10), nil, after); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Clients whose every booking has been anonymized and who have not been in touch since the cutoff
+	dbCtx, cancel = h.dbContext(ctx)
+	defer cancel()
+	var emails []string
+	err = eachRow(dbCtx, h.DB, fmt.Sprintf(`SELECT c.email FROM clients c
+		WHERE c.merged_into_id IS NULL AND c.last_seen_at < $1
+			AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.client_id = c.id)
+			AND NOT EXISTS (SELECT 1 FROM clients m JOIN waitlist_entries w ON LOWER(TRIM(w.email)) = m.email
+				WHERE (m.id = c.id OR m.merged_into_id = c.id) AND w.status IN ('waiting', 'offered'))
+			AND NOT EXISTS (SELECT 1 FROM clients m JOIN packages p ON LOWER(p.client_email) = m.email
+				WHERE (m.id = c.id OR m.merged_into_id = c.id) AND p.voided_at IS NULL AND p.remaining_sessions > 0
+					AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP))
+		ORDER BY c.last_seen_at LIMIT %d`, retentionBatch),
+		[]interface{}{cutoff},
+		func(row rowScanner) error {
+			var email string
+			err := row.Scan(&email)
+			emails = append(emails, email)
+			return err
+		})
+	if err != nil {
+		return int(anonymized), err
+	}
+	for _, email := range emails {
+		if _, err := h.eraseClient(ctx, audit, email); err != nil && !errors.Is(err, ErrNoPersonalData) {
+			return int(anonymized), err
+		}
+	}
+
+	return int(anonymized) + len(emails), nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Component statuses reported by /readyz
+const (
+	ComponentOK       = "ok"
+	ComponentDisabled = "disabled"
+	ComponentFailed   = "failed"
+)
+
+// DefaultReadinessTimeout bounds the database checks of a readiness probe
+const DefaultReadinessTimeout = time.Second
+
+// ComponentStatus is the state of one dependency in the readiness report. Only required
+// components decide whether the instance is ready.
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	Required   bool   `json:"required"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// ConfigReporter is implemented by services that report their configuration on /readyz
+type ConfigReporter interface {
+	ConfigStatus() ComponentStatus
+}
+
+// ReadinessReport is the /readyz response
+type ReadinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+func (h *APIHandlers) readinessTimeout() time.Duration {
+	if h.ReadinessTimeout > 0 {
+		return h.ReadinessTimeout
+	}
+	return DefaultReadinessTimeout
+}
+
+// checkDatabase pings the database
+func (h *APIHandlers) checkDatabase(ctx context.Context) ComponentStatus {
+	start := time.Now()
+	err := h.DB.PingContext(ctx)
+	status := ComponentStatus{Status: ComponentOK, Required: true, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		// The error can name the database host; keep it out of the public response
+		slog.ErrorContext(ctx, "Readiness check: database unreachable", "error", err)
+		status.Status = ComponentFailed
+		status.Detail = "database unreachable"
+	}
+	return status
+}
+
+// checkMigrations verifies the schema most recently applied to the database is the one
+// this binary applies at startup. It fails when no version is recorded, or when another
+// binary has since applied a different schema, e.g. a newer release during a rollout.
+func (h *APIHandlers) checkMigrations(ctx context.Context) ComponentStatus {
+	if h.SchemaVersion == "" {
+		return ComponentStatus{Status: ComponentDisabled, Detail: "schema version unknown"}
+	}
+	status := ComponentStatus{Status: ComponentOK, Required: true, Detail: "schema version " + h.SchemaVersion}
+
+	var latest string
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT version FROM schema_migrations ORDER BY applied_at DESC, version LIMIT 1",
+	).Scan(&latest)
+	switch {
+	case err == sql.ErrNoRows:
+		status.Status = ComponentFailed
+		status.Detail = "no schema version has been applied"
+	case err != nil:
+		slog.ErrorContext(ctx, "Readiness check: error reading schema version", "error", err)
+		status.Status = ComponentFailed
+		status.Detail = "schema version could not be read"
+	case latest != h.SchemaVersion:
+		status.Status = ComponentFailed
+		status.Detail = "schema version " + latest + " has been applied; this binary expects " + h.SchemaVersion
+	}
+	return status
+}
+
+// configStatus reports an optional service's configuration, if it can
+func configStatus(service interface{}) (ComponentStatus, bool) {
+	reporter, ok := service.(ConfigReporter)
+	if !ok {
+		return ComponentStatus{}, false
+	}
+	status := reporter.ConfigStatus()
+	status.Required = false
+	return status, true
+}
+
+// Readiness reports whether the instance can serve traffic: the database answers within
+// the readiness timeout and its schema is current. SMTP and Zoom are reported for
+// information and never make the instance unready. Returns 503 when not ready.
+func (h *APIHandlers) Readiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.readinessTimeout())
+	defer cancel()
+
+	report := ReadinessReport{Status: ComponentOK, Components: map[string]ComponentStatus{}}
+	report.Components["database"] = h.checkDatabase(ctx)
+	if report.Components["database"].Status == ComponentOK {
+		report.Components["migrations"] = h.checkMigrations(ctx)
+	} else {
+		report.Components["migrations"] = ComponentStatus{Status: ComponentFailed, Required: true, Detail: "database unreachable"}
+	}
+	if status, ok := configStatus(h.EmailService); ok {
+		report.Components["smtp"] = status
+	}
+	if status, ok := configStatus(h.ZoomService); ok {
+		report.Components["zoom"] = status
+	}
+
+	code := http.StatusOK
+	for _, c := range report.Components {
+		if c.Required && c.Status == ComponentFailed {
+			report.Status = ComponentFailed
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Log formats accepted by NewLogger
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+const redactedValue = "[redacted]"
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	// Secrets carried in URLs: confirmation and claim tokens, Zoom meeting passwords
+	urlSecretPattern = regexp.MustCompile(`(?i)\b(token|pwd|password|secret|key)=[^&\s"']+`)
+	bearerPattern    = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// sensitiveKeyParts mark log attributes whose whole value is a secret
+var sensitiveKeyParts = []string{"token", "secret", "password", "authorization", "api_key", "signature", "cookie"}
+
+// NewLogger returns a logger writing JSON (or text) records at level. Every record made
+// with a request context carries the request ID, and email addresses, tokens and other
+// secrets are redacted from the message and attributes.
+func NewLogger(w io.Writer, level slog.Leveler, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	if format == LogFormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler adds the request ID stored in the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr masks email addresses (keeping the first character and the domain, enough to
+// tell clients apart while debugging) and drops secrets
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+
+	key := strings.ToLower(a.Key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return slog.String(a.Key, redactedValue)
+		}
+	}
+
+	var s string
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s = a.Value.String()
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			s = v.Error()
+		case interface{ String() string }:
+			s = v.String()
+		default:
+			return a
+		}
+	default:
+		return a
+	}
+	return slog.String(a.Key, RedactString(s))
+}
+
+// RedactString masks email addresses and secrets in free text such as error messages
+func RedactString(s string) string {
+	s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	s = urlSecretPattern.ReplaceAllString(s, "$1="+redactedValue)
+	return bearerPattern.ReplaceAllString(s, "$1 "+redactedValue)
+}
+
+// probePaths are the health check endpoints
+var probePaths = map[string]bool{"/health": true, "/healthz": true, "/readyz": true}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// LogRequests logs one record per request with its method, path, status and duration.
+// Wrap it inside WithRequestID so the record carries the request ID.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if probePaths[r.URL.Path] && status < http.StatusBadRequest {
+			// Load balancers probe every few seconds; only failures are worth reading
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", clientIP(r),
+		)
+	})
+}
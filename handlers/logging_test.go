@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Booking created", "Booking created"},
+		{"email", "sent to anna.koval@example.com", "sent to a***@example.com"},
+		{"two emails", "merged a@x.io into bob@y.org", "merged a***@x.io into b***@y.org"},
+		{"token in URL", "GET /confirm?token=abc123&x=1", "GET /confirm?token=[redacted]&x=1"},
+		{"zoom password", "https://zoom.us/j/123?pwd=Zx9", "https://zoom.us/j/123?pwd=[redacted]"},
+		{"case insensitive key", "/maps?KEY=abc", "/maps?KEY=[redacted]"},
+		{"bearer header", "Authorization: Bearer eyJhbGciOi.x-y", "Authorization: Bearer [redacted]"},
+		{"basic header", "basic dXNlcjpwYXNz", "basic [redacted]"},
+		{"not an email", "user@localhost", "user@localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactString(tt.in); got != tt.want {
+				t.Errorf("RedactString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactAttr(t *testing.T) {
+	link, _ := url.Parse("https://example.com/claim?token=secret1")
+
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want slog.Value
+	}{
+		{"email string", slog.String("email", "anna@example.com"), slog.StringValue("a***@example.com")},
+		{"sensitive key", slog.String("confirmation_token", "abc"), slog.StringValue(redactedValue)},
+		{"sensitive key any case", slog.Int("Stripe-Signature", 5), slog.StringValue(redactedValue)},
+		{"error", slog.Any("error", errors.New("smtp: rejected anna@example.com")), slog.StringValue("smtp: rejected a***@example.com")},
+		{"stringer", slog.Any("url", link), slog.StringValue("https://example.com/claim?token=[redacted]")},
+		{"number untouched", slog.Int("count", 3), slog.IntValue(3)},
+		{"duration untouched", slog.Duration("took", time.Second), slog.DurationValue(time.Second)},
+		{"other any untouched", slog.Any("ids", []int{1, 2}), slog.AnyValue([]int{1, 2})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactAttr(nil, tt.attr)
+			if got.Key != tt.attr.Key {
+				t.Errorf("key = %q, want %q", got.Key, tt.attr.Key)
+			}
+			if got.Value.String() != tt.want.String() {
+				t.Errorf("value = %q, want %q", got.Value.String(), tt.want.String())
+			}
+		})
+	}
+}
+
+func TestNewLoggerRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, slog.LevelInfo, LogFormatJSON)
+	logger.Info("Sent confirmation to anna@example.com", slog.Group("smtp", slog.String("password", "hunter2")))
+
+	out := buf.String()
+	for _, leaked := range []string{"anna@example.com", "hunter2"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("log record contains %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "a***@example.com") {
+		t.Errorf("log record lacks the masked email: %s", out)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunMaintenance periodically runs the background jobs until ctx is cancelled: expiring
+// pending holds and their unpaid checkouts, passing unclaimed waitlist offers on,
+// finalizing bulk operations whose undo window has passed, retrying stalled refunds,
+// anonymizing data past its retention period and pruning refilled rate limit buckets. A
+// pass that is under way when ctx is cancelled runs to completion, so returning means the
+// jobs are drained.
+func (h *APIHandlers) RunMaintenance(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	stop := ctx.Done()
+	ctx = context.WithoutCancel(ctx)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := h.ExpirePendingHolds(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error expiring pending holds", "error", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "Expired unconfirmed booking holds", "count", n)
+			}
+
+			unpaid, err := h.ExpireUnpaidCheckouts(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error expiring unpaid checkouts", "error", err)
+			} else if unpaid > 0 {
+				slog.InfoContext(ctx, "Expired unpaid checkouts", "count", unpaid)
+			}
+
+			offers, err := h.ExpireWaitlistOffers(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error expiring waitlist offers", "error", err)
+			} else if offers > 0 {
+				slog.InfoContext(ctx, "Expired unclaimed waitlist offers", "count", offers)
+			}
+
+			finalized, err := h.FinalizeBulkOperations(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error finalizing bulk operations", "error", err)
+			} else if finalized > 0 {
+				slog.InfoContext(ctx, "Finalized bulk operations", "count", finalized)
+			}
+
+			refunds, err := h.RetryStalledRefunds(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error retrying stalled refunds", "error", err)
+			} else if refunds > 0 {
+				slog.InfoContext(ctx, "Retried stalled refunds", "count", refunds)
+			}
+
+			retained, err := h.ApplyRetention(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error applying data retention", "error", err)
+			} else if retained > 0 {
+				slog.InfoContext(ctx, "Anonymized bookings and clients past the retention period", "count", retained)
+			}
+
+			buckets, err := h.PruneRateLimitBuckets(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error pruning rate limit buckets", "error", err)
+			} else if buckets > 0 {
+				slog.DebugContext(ctx, "Pruned refilled rate limit buckets", "count", buckets)
+			}
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded for email sends and Zoom API calls
+const (
+	OutcomeSuccess  = "success"
+	OutcomeNotFound = "not_found"
+	OutcomeFailure  = "failure"
+)
+
+// Reasons a public booking is rejected with 409 Conflict
+const (
+	conflictBooked = "booked"
+	conflictBuffer = "buffer"
+	conflictLimit  = "limit"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// labelSeparator joins label values into a series key; it cannot appear in valid UTF-8
+const labelSeparator = "\xff"
+
+// counterVec is a counter partitioned by label values
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, labelSeparator)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	c.add(1, labelValues...)
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, "", ""), formatValue(c.values[key]))
+	}
+}
+
+// histogram is one series of a histogramVec
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// histogramVec is a latency histogram partitioned by label values
+type histogramVec struct {
+	name, help string
+	labels     []string
+	bounds     []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, bounds: latencyBuckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(d time.Duration, labelValues ...string) {
+	seconds := d.Seconds()
+	key := strings.Join(labelValues, labelSeparator)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{buckets: make([]uint64, len(h.bounds))}
+		h.series[key] = s
+	}
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += seconds
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatValue(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, "", ""), s.count)
+	}
+}
+
+// metrics holds everything exposed on /metrics. It is package-level so the middleware and
+// the email and Zoom services, which have no access to APIHandlers, can record into it.
+var metrics = struct {
+	httpRequests      *counterVec
+	httpDuration      *histogramVec
+	bookingsCreated   *counterVec
+	bookingsCancelled *counterVec
+	slotConflicts     *counterVec
+	emailSends        *counterVec
+	emailDuration     *histogramVec
+	zoomCalls         *counterVec
+	zoomDuration      *histogramVec
+}{
+	httpRequests: newCounterVec("coach_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "code"),
+	httpDuration: newHistogramVec("coach_http_request_duration_seconds",
+		"HTTP request latency by route and method.", "route", "method"),
+	bookingsCreated: newCounterVec("coach_bookings_created_total",
+		"Bookings created, by source and initial status (pending bookings await email confirmation or payment).", "source", "status"),
+	bookingsCancelled: newCounterVec("coach_bookings_cancelled_total",
+		"Bookings cancelled, by cancelled status.", "status"),
+	slotConflicts: newCounterVec("coach_booking_slot_conflicts_total",
+		"Public bookings rejected with 409 Conflict because the slot was taken, too close to another session or over a booking limit.", "reason"),
+	emailSends: newCounterVec("coach_email_sends_total",
+		"Emails sent via SMTP, by outcome.", "outcome"),
+	emailDuration: newHistogramVec("coach_email_send_duration_seconds",
+		"SMTP send latency."),
+	zoomCalls: newCounterVec("coach_zoom_api_calls_total",
+		"Zoom API calls by operation and outcome.", "operation", "outcome"),
+	zoomDuration: newHistogramVec("coach_zoom_api_call_duration_seconds",
+		"Zoom API call latency by operation.", "operation"),
+}
+
+// ObserveEmailSend records the outcome and latency of sending one email
+func ObserveEmailSend(err error, d time.Duration) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeFailure
+	}
+	metrics.emailSends.inc(outcome)
+	metrics.emailDuration.observe(d)
+}
+
+// ObserveZoomCall records the outcome (OutcomeSuccess, OutcomeNotFound or OutcomeFailure)
+// and latency of one Zoom API call, e.g. operation "oauth_token" or "create_meeting"
+func ObserveZoomCall(operation, outcome string, d time.Duration) {
+	metrics.zoomCalls.inc(operation, outcome)
+	metrics.zoomDuration.observe(d, operation)
+}
+
+// slotConflict rejects a public booking with 409 Conflict and counts it by reason
+func slotConflict(w http.ResponseWriter, reason, message string) {
+	metrics.slotConflicts.inc(reason)
+	http.Error(w, message, http.StatusConflict)
+}
+
+// metricMethods are the HTTP methods recorded by name; any other counts as "other"
+var metricMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// CollectMetrics counts requests and their latency per route of mux. Routes are the
+// registered patterns rather than request paths, and methods outside the standard set are
+// counted as "other", so unknown URLs and made-up methods cannot add series.
+func CollectMetrics(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		method := r.Method
+		if !metricMethods[method] {
+			method = "other"
+		}
+		metrics.httpRequests.inc(route, method, strconv.Itoa(status))
+		metrics.httpDuration.observe(time.Since(start), route, method)
+	})
+}
+
+// Metrics serves all metrics in the Prometheus text exposition format, including the
+// database connection pool statistics at the time of the scrape
+func (h *APIHandlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	metrics.httpRequests.write(out)
+	metrics.httpDuration.write(out)
+	metrics.bookingsCreated.write(out)
+	metrics.bookingsCancelled.write(out)
+	metrics.slotConflicts.write(out)
+	metrics.emailSends.write(out)
+	metrics.emailDuration.write(out)
+	metrics.zoomCalls.write(out)
+	metrics.zoomDuration.write(out)
+
+	stats := h.DB.Stats()
+	writeSample(out, "coach_db_max_open_connections", "Maximum number of open database connections.", "gauge", float64(stats.MaxOpenConnections))
+	writeSample(out, "coach_db_open_connections", "Open database connections, in use and idle.", "gauge", float64(stats.OpenConnections))
+	writeSample(out, "coach_db_in_use_connections", "Database connections currently in use.", "gauge", float64(stats.InUse))
+	writeSample(out, "coach_db_idle_connections", "Idle database connections.", "gauge", float64(stats.Idle))
+	writeSample(out, "coach_db_wait_count_total", "Times a request waited for a database connection.", "counter", float64(stats.WaitCount))
+	writeSample(out, "coach_db_wait_duration_seconds_total", "Total time spent waiting for a database connection.", "counter", stats.WaitDuration.Seconds())
+	writeSample(out, "coach_db_max_idle_closed_total", "Connections closed because of the idle pool limit.", "counter", float64(stats.MaxIdleClosed))
+	writeSample(out, "coach_db_max_idle_time_closed_total", "Connections closed because of the idle time limit.", "counter", float64(stats.MaxIdleTimeClosed))
+	writeSample(out, "coach_db_max_lifetime_closed_total", "Connections closed because of the lifetime limit.", "counter", float64(stats.MaxLifetimeClosed))
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes a single unlabelled sample
+func writeSample(w io.Writer, name, help, kind string, value float64) {
+	writeHeader(w, name, help, kind)
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+}
+
+// formatLabels renders the label set of the series key, plus an extra label when extraName is set
+func formatLabels(names []string, key, extraName, extraValue string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, names[i]+`="`+escapeLabelValue(value)+`"`)
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	actorKey
+	clientIPKey
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID assigns every request an ID, reusing a well-formed incoming X-Request-ID,
+// stores it in the request context and echoes it in the response
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			token, err := newToken()
+			if err == nil {
+				requestID = token[:16]
+			}
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts short IDs made of URL-safe characters so that clients cannot
+// inject arbitrary data into logs and audit records
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// TrustedProxies are the networks of reverse proxies whose X-Forwarded-For is believed
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges, e.g.
+// "10.0.0.0/8, 192.168.1.7". An empty string trusts no proxy.
+func ParseTrustedProxies(value string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			ip := net.ParseIP(part)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", part)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// contains reports whether addr is the address of a trusted proxy
+func (p TrustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithClientIP stores the client's address in the request context for rate limits, audit
+// records and request logs. X-Forwarded-For is only believed when the connection comes from
+// a trusted proxy: its entries are read from the right, skipping further trusted proxies,
+// and the first other address is the client. Otherwise the peer address is used, so a
+// client reaching the app directly cannot choose its own address.
+func WithClientIP(proxies TrustedProxies, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey, resolveClientIP(r, proxies))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func resolveClientIP(r *http.Request, proxies TrustedProxies) string {
+	ip := remoteIP(r)
+	if !proxies.contains(ip) {
+		return ip
+	}
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			// A malformed entry cannot be followed further
+			break
+		}
+		ip = hop
+		if !proxies.contains(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// remoteIP returns the host part of the peer address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit actions for session notes. Note bodies are never written to the audit log.
+const (
+	AuditNoteSaved   = "note.saved"
+	AuditNoteDeleted = "note.deleted"
+)
+
+// noteCiphertextPrefix versions the stored format so the scheme can change later
+const noteCiphertextPrefix = "v1:"
+
+var (
+	ErrNoteNotFound       = errors.New("note not found")
+	ErrClientNotesNeedKey = errors.New("clients.notes holds plaintext notes; set NOTES_ENCRYPTION_KEY to move them into encrypted client notes")
+)
+
+// NoteCipher encrypts session notes at rest with AES-256-GCM
+type NoteCipher struct {
+	aead cipher.AEAD
+}
+
+// NewNoteCipher builds a cipher from a 32-byte key given as 64 hex characters or base64
+func NewNoteCipher(key string) (*NoteCipher, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		raw, err = base64.StdEncoding.DecodeString(key)
+	}
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, encoded as hex or base64")
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &NoteCipher{aead: aead}, nil
+}
+
+// Encrypt returns the stored form of a note body
+func (c *NoteCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return noteCiphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt
+func (c *NoteCipher) Decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, noteCiphertextPrefix) {
+		return "", fmt.Errorf("unknown note format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, noteCiphertextPrefix))
+	if err != nil {
+		return "", err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("note ciphertext too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SessionNote is a private Markdown note the coach attaches to a booking or a client
+type SessionNote struct {
+	ID        int       `json:"id"`
+	BookingID int       `json:"booking_id,omitempty"`
+	ClientID  int       `json:"client_id,omitempty"`
+	Body      string    `json:"body"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// noteAuditState is what the audit log keeps of a note: who it belongs to, not what it says
+func noteAuditState(n *SessionNote) map[string]interface{} {
+	if n == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":          n.ID,
+		"booking_id":  n.BookingID,
+		"client_id":   n.ClientID,
+		"body_length": len(n.Body),
+	}
+}
+
+func (h *APIHandlers) scanNote(row rowScanner) (*SessionNote, error) {
+	var n SessionNote
+	var bookingID, clientID sql.NullInt64
+	var body string
+	if err := row.Scan(&n.ID, &bookingID, &clientID, &body, &n.CreatedBy, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		return nil, err
+	}
+	plaintext, err := h.Notes.Decrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt note %d: %w", n.ID, err)
+	}
+	n.BookingID = int(bookingID.Int64)
+	n.ClientID = int(clientID.Int64)
+	n.Body = plaintext
+	return &n, nil
+}
+
+const noteColumns = "id, booking_id, client_id, body_encrypted, created_by, created_at, updated_at"
+
+func (h *APIHandlers) loadNote(ctx context.Context, q queryRower, id int) (*SessionNote, error) {
+	n, err := h.scanNote(q.QueryRowContext(ctx, "SELECT "+noteColumns+" FROM session_notes WHERE id = $1 FOR UPDATE", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoteNotFound
+	}
+	return n, err
+}
+
+// MoveClientNotes moves the plaintext clients.notes column of databases created before client
+// notes were encrypted into encrypted client notes, drops the column and removes it from the
+// client states in the audit log. A note the client already has is not added again. It
+// returns the number of notes moved, and does nothing once the column is gone.
+func MoveClientNotes(ctx context.Context, tx *sql.Tx, notes *NoteCipher) (int, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'clients' AND column_name = 'notes')`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	type clientNote struct {
+		clientID int
+		body     string
+	}
+	var pending []clientNote
+	err = eachRow(ctx, tx, "SELECT id, notes FROM clients WHERE TRIM(COALESCE(notes, '')) <> '' ORDER BY id FOR UPDATE", nil,
+		func(row rowScanner) error {
+			var n clientNote
+			err := row.Scan(&n.clientID, &n.body)
+			pending = append(pending, n)
+			return err
+		})
+	if err != nil {
+		return 0, err
+	}
+	if len(pending) > 0 && notes == nil {
+		return 0, ErrClientNotesNeedKey
+	}
+
+	audit := systemAuditor("client-notes-migration")
+	moved := 0
+	for _, n := range pending {
+		var existing []string
+		err := eachRow(ctx, tx, "SELECT body_encrypted FROM session_notes WHERE client_id = $1", []interface{}{n.clientID},
+			func(row rowScanner) error {
+				var stored string
+				if err := row.Scan(&stored); err != nil {
+					return err
+				}
+				body, err := notes.Decrypt(stored)
+				existing = append(existing, body)
+				return err
+			})
+		if err != nil {
+			return moved, fmt.Errorf("reading notes of client %d: %w", n.clientID, err)
+		}
+		if slices.Contains(existing, n.body) {
+			continue
+		}
+
+		encrypted, err := notes.Encrypt(n.body)
+		if err != nil {
+			return moved, err
+		}
+		note := SessionNote{ClientID: n.clientID, Body: n.body}
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO session_notes (client_id, body_encrypted, created_by) VALUES ($1, $2, $3) RETURNING id",
+			n.clientID, encrypted, audit.actor.String(),
+		).Scan(&note.ID)
+		if err != nil {
+			return moved, err
+		}
+		if err := audit.record(ctx, tx, AuditNoteSaved, "note", strconv.Itoa(note.ID), nil, noteAuditState(&note)); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	// Client states were recorded with their notes, including both sides of a merge.
+	// audit_events is append-only; this setting lets the trigger accept redactions in this transaction.
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.audit_redaction', 'on', true)"); err != nil {
+		return moved, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE audit_events SET
+			before_state = before_state - 'notes' #- '{source,notes}' #- '{target,notes}',
+			after_state = after_state - 'notes' #- '{source,notes}' #- '{target,notes}'
+		WHERE entity_type = 'client'
+			AND (before_state - 'notes' #- '{source,notes}' #- '{target,notes}' IS DISTINCT FROM before_state
+				OR after_state - 'notes' #- '{source,notes}' #- '{target,notes}' IS DISTINCT FROM after_state)`)
+	if err != nil {
+		return moved, err
+	}
+
+	_, err = tx.ExecContext(ctx, "ALTER TABLE clients DROP COLUMN notes")
+	return moved, err
+}
+
+// notesEnabled rejects the request when no encryption key is configured
+func (h *APIHandlers) notesEnabled(w http.ResponseWriter) bool {
+	if h.Notes == nil {
+		http.Error(w, "Session notes are disabled: set NOTES_ENCRYPTION_KEY", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// SessionNotes serves /api/admin/notes. GET lists notes for a booking_id or client_id (a
+// client's list includes the notes on their bookings), or searches all notes with q; since
+// bodies are encrypted, search decrypts and matches in memory. POST creates a note for a
+// booking_id or client_id, or updates the body of an existing note by id.
+func (h *APIHandlers) SessionNotes(w http.ResponseWriter, r *http.Request) {
+	if !h.notesEnabled(w) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.listNotes(w, r)
+	case http.MethodPost:
+		h.saveNote(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIHandlers) listNotes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+
+	query := "SELECT " + noteColumns + " FROM session_notes"
+	var args []interface{}
+	if value := q.Get("booking_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid booking_id", http.StatusBadRequest)
+			return
+		}
+		query += " WHERE booking_id = $1"
+		args = append(args, id)
+	} else if value := q.Get("client_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid client_id", http.StatusBadRequest)
+			return
+		}
+		query += " WHERE client_id = $1 OR booking_id IN (SELECT id FROM bookings WHERE client_id = $1)"
+		args = append(args, id)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	search := strings.ToLower(strings.TrimSpace(q.Get("q")))
+	if search == "" && len(args) == 0 {
+		http.Error(w, "booking_id, client_id or q is required", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying notes", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	notes := []SessionNote{}
+	for rows.Next() {
+		n, err := h.scanNote(rows)
+		if err != nil {
+			http.Error(w, "Failed to read notes", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error reading note", "error", err)
+			return
+		}
+		if search != "" && !strings.Contains(strings.ToLower(n.Body), search) {
+			continue
+		}
+		notes = append(notes, *n)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying notes", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+}
+
+func (h *APIHandlers) saveNote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	var req struct {
+		ID        int    `json:"id"`
+		BookingID int    `json:"booking_id"`
+		ClientID  int    `json:"client_id"`
+		Body      string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		http.Error(w, "body is required", http.StatusBadRequest)
+		return
+	}
+	if req.ID == 0 && (req.BookingID == 0) == (req.ClientID == 0) {
+		http.Error(w, "Exactly one of booking_id and client_id is required", http.StatusBadRequest)
+		return
+	}
+
+	encrypted, err := h.Notes.Encrypt(req.Body)
+	if err != nil {
+		http.Error(w, "Failed to save note", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error encrypting note", "error", err)
+		return
+	}
+
+	audit := auditorFromRequest(r)
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var before *SessionNote
+	id := req.ID
+	if id != 0 {
+		before, err = h.loadNote(ctx, tx, id)
+		if err == ErrNoteNotFound {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "UPDATE session_notes SET body_encrypted = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", encrypted, id)
+		}
+	} else {
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO session_notes (booking_id, client_id, body_encrypted, created_by)
+			VALUES ($1, $2, $3, $4) RETURNING id`,
+			sql.NullInt64{Int64: int64(req.BookingID), Valid: req.BookingID != 0},
+			sql.NullInt64{Int64: int64(req.ClientID), Valid: req.ClientID != 0},
+			encrypted, audit.actor.String(),
+		).Scan(&id)
+		if err != nil && strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Booking or client not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	var after *SessionNote
+	if err == nil {
+		after, err = h.loadNote(ctx, tx, id)
+	}
+	if err == nil {
+		err = audit.record(ctx, tx, AuditNoteSaved, "note", strconv.Itoa(id), noteAuditState(before), noteAuditState(after))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to save note", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error saving note", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.ID == 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(after)
+}
+
+// DeleteSessionNote deletes a note by id
+func (h *APIHandlers) DeleteSessionNote(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	if !h.notesEnabled(w) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := h.loadNote(ctx, tx, req.ID)
+	if err == ErrNoteNotFound {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "DELETE FROM session_notes WHERE id = $1", req.ID)
+	}
+	if err == nil {
+		err = auditorFromRequest(r).record(ctx, tx, AuditNoteDeleted, "note", strconv.Itoa(req.ID), noteAuditState(before), nil)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error deleting note", "note_id", req.ID, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Note deleted",
+	})
+}
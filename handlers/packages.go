@@ -0,0 +1,730 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discount kinds
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// Audit actions for packages and discount codes
+const (
+	AuditPackageSaved      = "package.saved"
+	AuditPackageRedeemed   = "package.redeemed"
+	AuditPackageRestored   = "package.restored"
+	AuditDiscountCodeSaved = "discount_code.saved"
+	AuditDiscountCodeUsed  = "discount_code.used"
+)
+
+var (
+	ErrCodeNotFound      = errors.New("code not found")
+	ErrCodeNotApplicable = errors.New("code does not apply to this booking")
+	ErrCodeUsedUp        = errors.New("code is used up or no longer valid")
+)
+
+// SessionPackage is a prepaid bundle of sessions of one session type, redeemed one
+// session at a time with its code. ClientEmail, when set, restricts who may redeem it.
+type SessionPackage struct {
+	ID                int        `json:"id"`
+	Code              string     `json:"code"`
+	ClientEmail       string     `json:"client_email,omitempty"`
+	SessionType       string     `json:"session_type"`
+	TotalSessions     int        `json:"total_sessions"`
+	RemainingSessions int        `json:"remaining_sessions"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Note              string     `json:"note,omitempty"`
+	CreatedBy         string     `json:"created_by"`
+	CreatedAt         time.Time  `json:"created_at"`
+	VoidedAt          *time.Time `json:"voided_at,omitempty"`
+}
+
+// DiscountCode lowers the price of a paid session. Amount is a percentage for percent codes
+// and cents in Currency for fixed codes; MaxUses of 0 means unlimited, an empty SessionType
+// applies to every paid session type.
+type DiscountCode struct {
+	ID          int        `json:"id"`
+	Code        string     `json:"code"`
+	Kind        string     `json:"kind"`
+	Amount      int        `json:"amount"`
+	Currency    string     `json:"currency,omitempty"`
+	SessionType string     `json:"session_type,omitempty"`
+	MaxUses     int        `json:"max_uses"`
+	UsedCount   int        `json:"used_count"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidUntil  *time.Time `json:"valid_until,omitempty"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// normalizeCode makes codes case-insensitive
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newPackageCode returns a random code that is easy to read out over the phone
+func newPackageCode() (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	return "PKG-" + strings.ToUpper(token[:8]), nil
+}
+
+const packageColumns = `id, code, COALESCE(client_email, ''), session_type, total_sessions, remaining_sessions,
+	expires_at, COALESCE(note, ''), created_by, created_at, voided_at`
+
+func scanPackage(row rowScanner) (*SessionPackage, error) {
+	var p SessionPackage
+	var expiresAt, voidedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.Code, &p.ClientEmail, &p.SessionType, &p.TotalSessions, &p.RemainingSessions,
+		&expiresAt, &p.Note, &p.CreatedBy, &p.CreatedAt, &voidedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.ExpiresAt = nullTimePtr(expiresAt)
+	p.VoidedAt = nullTimePtr(voidedAt)
+	return &p, nil
+}
+
+const discountCodeColumns = `id, code, kind, amount, COALESCE(currency, ''), COALESCE(session_type, ''), max_uses, used_count,
+	valid_from, valid_until, active, created_at`
+
+func scanDiscountCode(row rowScanner) (*DiscountCode, error) {
+	var d DiscountCode
+	var validFrom, validUntil sql.NullTime
+	err := row.Scan(&d.ID, &d.Code, &d.Kind, &d.Amount, &d.Currency, &d.SessionType, &d.MaxUses, &d.UsedCount,
+		&validFrom, &validUntil, &d.Active, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.ValidFrom = nullTimePtr(validFrom)
+	d.ValidUntil = nullTimePtr(validUntil)
+	return &d, nil
+}
+
+// codeRedemption is a validated code for a booking about to be created, and the price the
+// client pays with it. A zero redemption (no code) leaves the session type's price.
+type codeRedemption struct {
+	PackageID      int
+	DiscountCodeID int
+	PriceCents     int
+}
+
+// resolveCode checks a package or discount code against the booking without using it up;
+// the use is taken atomically by redeem when the booking is inserted
+func (h *APIHandlers) resolveCode(ctx context.Context, code string, slotType SessionType, email string) (*codeRedemption, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	redemption := &codeRedemption{PriceCents: slotType.PriceCents}
+	code = normalizeCode(code)
+	if code == "" {
+		return redemption, nil
+	}
+	now := time.Now()
+
+	pkg, err := scanPackage(h.DB.QueryRowContext(ctx, "SELECT "+packageColumns+" FROM packages WHERE code = $1", code))
+	if err == nil {
+		if err := pkg.check(slotType, email, now); err != nil {
+			return nil, err
+		}
+		redemption.PackageID = pkg.ID
+		redemption.PriceCents = 0
+		return redemption, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	discount, err := scanDiscountCode(h.DB.QueryRowContext(ctx, "SELECT "+discountCodeColumns+" FROM discount_codes WHERE code = $1", code))
+	if err == sql.ErrNoRows {
+		return nil, ErrCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	price, err := discount.price(slotType, now)
+	if err != nil {
+		return nil, err
+	}
+	redemption.DiscountCodeID = discount.ID
+	redemption.PriceCents = price
+	return redemption, nil
+}
+
+// check reports whether the package can pay for a session of slotType booked by email
+func (p *SessionPackage) check(slotType SessionType, email string, now time.Time) error {
+	switch {
+	case p.SessionType != slotType.Key:
+		return ErrCodeNotApplicable
+	case p.ClientEmail != "" && !strings.EqualFold(p.ClientEmail, strings.TrimSpace(email)):
+		return ErrCodeNotApplicable
+	case p.VoidedAt != nil, p.ExpiresAt != nil && !p.ExpiresAt.After(now), p.RemainingSessions <= 0:
+		return ErrCodeUsedUp
+	}
+	return nil
+}
+
+// price returns what a session of slotType costs with the discount, never below zero
+func (d *DiscountCode) price(slotType SessionType, now time.Time) (int, error) {
+	switch {
+	case !slotType.Paid():
+		return 0, ErrCodeNotApplicable
+	case d.SessionType != "" && d.SessionType != slotType.Key:
+		return 0, ErrCodeNotApplicable
+	case d.Kind == DiscountFixed && !strings.EqualFold(d.Currency, slotType.Currency):
+		return 0, ErrCodeNotApplicable
+	case !d.Active, d.ValidFrom != nil && d.ValidFrom.After(now),
+		d.ValidUntil != nil && !d.ValidUntil.After(now),
+		d.MaxUses > 0 && d.UsedCount >= d.MaxUses:
+		return 0, ErrCodeUsedUp
+	}
+
+	price := slotType.PriceCents - d.Amount
+	if d.Kind == DiscountPercent {
+		price = slotType.PriceCents * (100 - d.Amount) / 100
+	}
+	if price < 0 {
+		price = 0
+	}
+	return price, nil
+}
+
+// redeem takes one use of the code for a booking inside the booking's transaction. The
+// conditional UPDATE decrements atomically, so concurrent bookings cannot overdraw a code.
+func (c *codeRedemption) redeem(ctx context.Context, tx *sql.Tx, audit auditor, bookingID int) error {
+	if c == nil {
+		return nil
+	}
+
+	if c.PackageID != 0 {
+		var remaining int
+		err := tx.QueryRowContext(ctx,
+			`UPDATE packages SET remaining_sessions = remaining_sessions - 1
+			WHERE id = $1 AND remaining_sessions > 0 AND voided_at IS NULL
+				AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+			RETURNING remaining_sessions`,
+			c.PackageID,
+		).Scan(&remaining)
+		if err == sql.ErrNoRows {
+			return ErrCodeUsedUp
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE bookings SET package_id = $1 WHERE id = $2", c.PackageID, bookingID); err != nil {
+			return err
+		}
+		after := map[string]int{"booking_id": bookingID, "remaining_sessions": remaining}
+		if err := audit.record(ctx, tx, AuditPackageRedeemed, "package", strconv.Itoa(c.PackageID), nil, after); err != nil {
+			return err
+		}
+	}
+
+	if c.DiscountCodeID != 0 {
+		var used int
+		err := tx.QueryRowContext(ctx,
+			`UPDATE discount_codes SET used_count = used_count + 1
+			WHERE id = $1 AND active AND (max_uses = 0 OR used_count < max_uses)
+				AND (valid_from IS NULL OR valid_from <= CURRENT_TIMESTAMP)
+				AND (valid_until IS NULL OR valid_until > CURRENT_TIMESTAMP)
+			RETURNING used_count`,
+			c.DiscountCodeID,
+		).Scan(&used)
+		if err == sql.ErrNoRows {
+			return ErrCodeUsedUp
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE bookings SET discount_code_id = $1 WHERE id = $2", c.DiscountCodeID, bookingID); err != nil {
+			return err
+		}
+		after := map[string]int{"booking_id": bookingID, "used_count": used}
+		if err := audit.record(ctx, tx, AuditDiscountCodeUsed, "discount_code", strconv.Itoa(c.DiscountCodeID), nil, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseRedemptions gives back the package credit and discount use of a booking that did
+// not happen, as part of the transaction releasing or cancelling it
+func releaseRedemptions(ctx context.Context, tx *sql.Tx, audit auditor, b *Booking) error {
+	if b.PackageID != 0 {
+		var remaining int
+		err := tx.QueryRowContext(ctx,
+			`UPDATE packages SET remaining_sessions = remaining_sessions + 1
+			WHERE id = $1 AND remaining_sessions < total_sessions RETURNING remaining_sessions`,
+			b.PackageID,
+		).Scan(&remaining)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			after := map[string]int{"booking_id": b.ID, "remaining_sessions": remaining}
+			if err := audit.record(ctx, tx, AuditPackageRestored, "package", strconv.Itoa(b.PackageID), nil, after); err != nil {
+				return err
+			}
+		}
+	}
+	if b.DiscountCodeID != 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE discount_codes SET used_count = GREATEST(used_count - 1, 0) WHERE id = $1", b.DiscountCodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settleCoachCancellation gives the client back what they spent on a booking the coach
+// cancelled: the payment is refunded and any package credit or discount use restored
+func (h *APIHandlers) settleCoachCancellation(ctx context.Context, audit auditor, bookingID int) {
+	h.refundBooking(ctx, audit, bookingID)
+
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err == nil {
+		defer tx.Rollback()
+		var booking *Booking
+		booking, err = loadBooking(dbCtx, tx, bookingID, true)
+		if err == nil {
+			err = releaseRedemptions(dbCtx, tx, audit, booking)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to restore package credit", "booking_id", bookingID, "error", err)
+	}
+}
+
+// codeError writes the response for a code that cannot be used, reporting whether err was one
+func codeError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrCodeNotFound):
+		http.Error(w, "Unknown code", http.StatusBadRequest)
+	case errors.Is(err, ErrCodeNotApplicable):
+		http.Error(w, "Code does not apply to this booking", http.StatusBadRequest)
+	case errors.Is(err, ErrCodeUsedUp):
+		http.Error(w, "Code is used up or no longer valid", http.StatusConflict)
+	default:
+		return false
+	}
+	return true
+}
+
+// codeTaken reports whether code is already used by a package or discount code other than
+// the one being saved, since both share the booking form's code field
+func codeTaken(ctx context.Context, tx *sql.Tx, code, table string, id int) (bool, error) {
+	var taken bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM packages WHERE code = $1 AND NOT ($2 = 'packages' AND id = $3))
+			OR EXISTS (SELECT 1 FROM discount_codes WHERE code = $1 AND NOT ($2 = 'discount_codes' AND id = $3))`,
+		code, table, id,
+	).Scan(&taken)
+	return taken, err
+}
+
+// Packages serves /api/admin/packages. GET lists packages, newest first (filters: email,
+// active=true for unvoided, unexpired packages with sessions left). POST creates a package
+// (session_type, total_sessions, optional client_email, expires_at, note and code; a code is
+// generated when omitted) or, with id, updates its client_email, remaining_sessions,
+// expires_at and note, or voids it with void: true.
+func (h *APIHandlers) Packages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listPackages(w, r)
+	case http.MethodPost:
+		h.savePackage(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIHandlers) listPackages(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+	query := "SELECT " + packageColumns + " FROM packages WHERE 1=1"
+	var args []interface{}
+	if email := strings.TrimSpace(q.Get("email")); email != "" {
+		args = append(args, email)
+		query += fmt.Sprintf(" AND LOWER(client_email) = LOWER($%d)", len(args))
+	}
+	if q.Get("active") == "true" {
+		query += " AND voided_at IS NULL AND remaining_sessions > 0 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)"
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying packages", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	packages := []SessionPackage{}
+	for rows.Next() {
+		p, err := scanPackage(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning package", "error", err)
+			return
+		}
+		packages = append(packages, *p)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying packages", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(packages)
+}
+
+func (h *APIHandlers) savePackage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	var req struct {
+		ID                int     `json:"id"`
+		Code              string  `json:"code"`
+		ClientEmail       *string `json:"client_email"`
+		SessionType       string  `json:"session_type"`
+		TotalSessions     int     `json:"total_sessions"`
+		RemainingSessions *int    `json:"remaining_sessions"`
+		ExpiresAt         *string `json:"expires_at"`
+		Note              *string `json:"note"`
+		Void              bool    `json:"void"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// An empty expires_at clears the expiry
+	var expiresAt sql.NullTime
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		t, err := parseTimeParam(*req.ExpiresAt, h.Policy.location())
+		if err != nil {
+			http.Error(w, "Invalid expires_at", http.StatusBadRequest)
+			return
+		}
+		if len(*req.ExpiresAt) == len("2006-01-02") {
+			// A bare date is valid through the end of that day
+			t = t.AddDate(0, 0, 1)
+		}
+		expiresAt = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+
+	audit := auditorFromRequest(r)
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var before *SessionPackage
+	id := req.ID
+	if id == 0 {
+		if req.SessionType == "" {
+			req.SessionType = DefaultSessionType
+		}
+		if req.TotalSessions <= 0 {
+			http.Error(w, "total_sessions must be positive", http.StatusBadRequest)
+			return
+		}
+		code := normalizeCode(req.Code)
+		if code == "" {
+			if code, err = newPackageCode(); err != nil {
+				http.Error(w, "Failed to save package", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "Error generating package code", "error", err)
+				return
+			}
+		}
+		taken, err := codeTaken(ctx, tx, code, "packages", 0)
+		if err == nil && taken {
+			http.Error(w, "Code is already in use", http.StatusConflict)
+			return
+		}
+		clientEmail := ""
+		if req.ClientEmail != nil {
+			clientEmail = strings.ToLower(strings.TrimSpace(*req.ClientEmail))
+		}
+		note := ""
+		if req.Note != nil {
+			note = *req.Note
+		}
+		if err == nil {
+			err = tx.QueryRowContext(ctx,
+				`INSERT INTO packages (code, client_email, session_type, total_sessions, remaining_sessions, expires_at, note, created_by)
+				VALUES ($1, $2, $3, $4, $4, $5, $6, $7) RETURNING id`,
+				code, nullString(clientEmail), req.SessionType, req.TotalSessions, expiresAt, nullString(note), audit.actor.String(),
+			).Scan(&id)
+			if err != nil && strings.Contains(err.Error(), "foreign key") {
+				http.Error(w, "Unknown session type", http.StatusBadRequest)
+				return
+			}
+		}
+		if err != nil {
+			http.Error(w, "Failed to save package", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating package", "error", err)
+			return
+		}
+	} else {
+		before, err = scanPackage(tx.QueryRowContext(ctx, "SELECT "+packageColumns+" FROM packages WHERE id = $1 FOR UPDATE", id))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Package not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error loading package", "package_id", id, "error", err)
+			return
+		}
+
+		clientEmail, note, remaining := before.ClientEmail, before.Note, before.RemainingSessions
+		if req.ClientEmail != nil {
+			clientEmail = strings.ToLower(strings.TrimSpace(*req.ClientEmail))
+		}
+		if req.Note != nil {
+			note = *req.Note
+		}
+		if req.RemainingSessions != nil {
+			remaining = *req.RemainingSessions
+		}
+		if remaining < 0 || remaining > before.TotalSessions {
+			http.Error(w, "remaining_sessions must be between 0 and total_sessions", http.StatusBadRequest)
+			return
+		}
+		if req.ExpiresAt == nil && before.ExpiresAt != nil {
+			expiresAt = sql.NullTime{Time: *before.ExpiresAt, Valid: true}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE packages SET client_email = $1, remaining_sessions = $2, expires_at = $3, note = $4,
+				voided_at = CASE WHEN $5 THEN COALESCE(voided_at, CURRENT_TIMESTAMP) ELSE voided_at END
+			WHERE id = $6`,
+			nullString(clientEmail), remaining, expiresAt, nullString(note), req.Void, id,
+		)
+		if err != nil {
+			http.Error(w, "Failed to save package", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error updating package", "package_id", id, "error", err)
+			return
+		}
+	}
+
+	after, err := scanPackage(tx.QueryRowContext(ctx, "SELECT "+packageColumns+" FROM packages WHERE id = $1", id))
+	if err == nil {
+		err = audit.record(ctx, tx, AuditPackageSaved, "package", strconv.Itoa(id), before, after)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to save package", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error saving package", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.ID == 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(after)
+}
+
+// DiscountCodes serves /api/admin/discount-codes. GET lists all codes. POST creates a code
+// or, with id, replaces an existing code's settings (used_count is kept).
+func (h *APIHandlers) DiscountCodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listDiscountCodes(w, r)
+	case http.MethodPost:
+		h.saveDiscountCode(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIHandlers) listDiscountCodes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	rows, err := h.DB.QueryContext(ctx, "SELECT "+discountCodeColumns+" FROM discount_codes ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.Error("Error querying discount codes", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	codes := []DiscountCode{}
+	for rows.Next() {
+		d, err := scanDiscountCode(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.Error("Error scanning discount code", "error", err)
+			return
+		}
+		codes = append(codes, *d)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.Error("Error querying discount codes", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *APIHandlers) saveDiscountCode(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	var req struct {
+		DiscountCode
+		ValidFrom  string `json:"valid_from"`
+		ValidUntil string `json:"valid_until"`
+	}
+	req.Active = true
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Code = normalizeCode(req.Code)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	switch {
+	case req.Code == "":
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	case req.Kind == DiscountPercent && (req.Amount <= 0 || req.Amount > 100):
+		http.Error(w, "Percent discounts need an amount between 1 and 100", http.StatusBadRequest)
+		return
+	case req.Kind == DiscountFixed && (req.Amount <= 0 || len(req.Currency) != 3):
+		http.Error(w, "Fixed discounts need a positive amount in cents and a currency", http.StatusBadRequest)
+		return
+	case req.Kind != DiscountPercent && req.Kind != DiscountFixed:
+		http.Error(w, "kind must be percent or fixed", http.StatusBadRequest)
+		return
+	case req.MaxUses < 0:
+		http.Error(w, "max_uses must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Kind == DiscountPercent {
+		req.Currency = ""
+	}
+
+	var validFrom, validUntil sql.NullTime
+	loc := h.Policy.location()
+	if req.ValidFrom != "" {
+		t, err := parseTimeParam(req.ValidFrom, loc)
+		if err != nil {
+			http.Error(w, "Invalid valid_from", http.StatusBadRequest)
+			return
+		}
+		validFrom = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+	if req.ValidUntil != "" {
+		t, err := parseTimeParam(req.ValidUntil, loc)
+		if err != nil {
+			http.Error(w, "Invalid valid_until", http.StatusBadRequest)
+			return
+		}
+		if len(req.ValidUntil) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		validUntil = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+
+	audit := auditorFromRequest(r)
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var before *DiscountCode
+	id := req.ID
+	if id != 0 {
+		before, err = scanDiscountCode(tx.QueryRowContext(ctx, "SELECT "+discountCodeColumns+" FROM discount_codes WHERE id = $1 FOR UPDATE", id))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Discount code not found", http.StatusNotFound)
+			return
+		}
+	}
+	var taken bool
+	if err == nil {
+		taken, err = codeTaken(ctx, tx, req.Code, "discount_codes", id)
+	}
+	if err == nil && taken {
+		http.Error(w, "Code is already in use", http.StatusConflict)
+		return
+	}
+
+	if err == nil && id == 0 {
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO discount_codes (code, kind, amount, currency, session_type, max_uses, valid_from, valid_until, active)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			req.Code, req.Kind, req.Amount, nullString(req.Currency), nullString(req.SessionType), req.MaxUses,
+			validFrom, validUntil, req.Active,
+		).Scan(&id)
+	} else if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE discount_codes SET code = $1, kind = $2, amount = $3, currency = $4, session_type = $5, max_uses = $6,
+				valid_from = $7, valid_until = $8, active = $9
+			WHERE id = $10`,
+			req.Code, req.Kind, req.Amount, nullString(req.Currency), nullString(req.SessionType), req.MaxUses,
+			validFrom, validUntil, req.Active, id,
+		)
+	}
+
+	var after *DiscountCode
+	if err == nil {
+		after, err = scanDiscountCode(tx.QueryRowContext(ctx, "SELECT "+discountCodeColumns+" FROM discount_codes WHERE id = $1", id))
+	}
+	if err == nil {
+		err = audit.record(ctx, tx, AuditDiscountCodeSaved, "discount_code", strconv.Itoa(id), before, after)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Unknown session type", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to save discount code", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error saving discount code", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.ID == 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(after)
+}
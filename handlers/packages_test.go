@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiscountCodePrice(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	coaching := SessionType{Key: "coaching", PriceCents: 5000, Currency: "EUR"}
+	free := SessionType{Key: DefaultSessionType}
+
+	tests := []struct {
+		name     string
+		discount DiscountCode
+		slotType SessionType
+		want     int
+		wantErr  error
+	}{
+		{"percent", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true}, coaching, 4000, nil},
+		{"percent rounds down", DiscountCode{Kind: DiscountPercent, Amount: 33, Active: true}, SessionType{Key: "coaching", PriceCents: 999, Currency: "EUR"}, 669, nil},
+		{"full percent", DiscountCode{Kind: DiscountPercent, Amount: 100, Active: true}, coaching, 0, nil},
+		{"fixed", DiscountCode{Kind: DiscountFixed, Amount: 1500, Currency: "EUR", Active: true}, coaching, 3500, nil},
+		{"fixed currency is case-insensitive", DiscountCode{Kind: DiscountFixed, Amount: 1500, Currency: "eur", Active: true}, coaching, 3500, nil},
+		{"fixed above the price", DiscountCode{Kind: DiscountFixed, Amount: 9000, Currency: "EUR", Active: true}, coaching, 0, nil},
+		{"fixed in another currency", DiscountCode{Kind: DiscountFixed, Amount: 1500, Currency: "USD", Active: true}, coaching, 0, ErrCodeNotApplicable},
+		{"free session type", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true}, free, 0, ErrCodeNotApplicable},
+		{"matching session type", DiscountCode{Kind: DiscountPercent, Amount: 50, SessionType: "coaching", Active: true}, coaching, 2500, nil},
+		{"other session type", DiscountCode{Kind: DiscountPercent, Amount: 20, SessionType: "intensive", Active: true}, coaching, 0, ErrCodeNotApplicable},
+		{"inactive", DiscountCode{Kind: DiscountPercent, Amount: 20}, coaching, 0, ErrCodeUsedUp},
+		{"not valid yet", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true, ValidFrom: &future}, coaching, 0, ErrCodeUsedUp},
+		{"no longer valid", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true, ValidUntil: &past}, coaching, 0, ErrCodeUsedUp},
+		{"used up", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true, MaxUses: 3, UsedCount: 3}, coaching, 0, ErrCodeUsedUp},
+		{"uses left", DiscountCode{Kind: DiscountPercent, Amount: 20, Active: true, MaxUses: 3, UsedCount: 2}, coaching, 4000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.discount.price(tt.slotType, now)
+			if err != tt.wantErr {
+				t.Fatalf("price() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("price() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionPackageCheck(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	coaching := SessionType{Key: "coaching", PriceCents: 5000, Currency: "EUR"}
+
+	tests := []struct {
+		name    string
+		pkg     SessionPackage
+		email   string
+		wantErr error
+	}{
+		{"usable", SessionPackage{SessionType: "coaching", RemainingSessions: 2}, "anna@example.com", nil},
+		{"client's own package", SessionPackage{SessionType: "coaching", ClientEmail: "Anna@Example.com", RemainingSessions: 1}, " anna@example.com ", nil},
+		{"someone else's package", SessionPackage{SessionType: "coaching", ClientEmail: "anna@example.com", RemainingSessions: 1}, "olena@example.com", ErrCodeNotApplicable},
+		{"other session type", SessionPackage{SessionType: "intensive", RemainingSessions: 1}, "anna@example.com", ErrCodeNotApplicable},
+		{"no sessions left", SessionPackage{SessionType: "coaching"}, "anna@example.com", ErrCodeUsedUp},
+		{"expired", SessionPackage{SessionType: "coaching", RemainingSessions: 1, ExpiresAt: &past}, "anna@example.com", ErrCodeUsedUp},
+		{"not expired yet", SessionPackage{SessionType: "coaching", RemainingSessions: 1, ExpiresAt: &future}, "anna@example.com", nil},
+		{"voided", SessionPackage{SessionType: "coaching", RemainingSessions: 1, VoidedAt: &past}, "anna@example.com", ErrCodeUsedUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.pkg.check(coaching, tt.email, now); err != tt.wantErr {
+				t.Errorf("check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
+	"time"
 )
 
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
@@ -245,6 +247,44 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
             display: block;
         }
 
+        .waitlist-toggle {
+            max-width: 500px;
+            margin: 30px auto 0;
+        }
+
+        .waitlist-intro {
+            color: #666;
+            margin-bottom: 20px;
+            text-align: center;
+        }
+
+        .form-row {
+            display: flex;
+            gap: 12px;
+        }
+
+        .form-row .form-group {
+            flex: 1;
+        }
+
+        .weekday-options {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px;
+        }
+
+        .weekday-options label {
+            display: flex;
+            align-items: center;
+            gap: 4px;
+            font-weight: normal;
+            margin: 0;
+        }
+
+        .weekday-options input {
+            width: auto;
+        }
+
         .form-group {
             margin-bottom: 20px;
         }
@@ -594,9 +634,80 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
                     <input type="email" id="email" placeholder="your.email@example.com" required>
                 </div>
 
+                <div class="form-group">
+                    <label for="bookingCode">Код пакета або промокод (необов'язково)</label>
+                    <input type="text" id="bookingCode" placeholder="Напр. PKG-1A2B3C4D" autocomplete="off">
+                </div>
+
+                <div class="form-group" style="position: absolute; left: -10000px;" aria-hidden="true">
+                    <label for="website">Website</label>
+                    <input type="text" id="website" tabindex="-1" autocomplete="off">
+                </div>
+
                 <button class="btn btn-primary" onclick="confirmBooking()">Підтвердити бронювання</button>
                 <button class="btn btn-secondary" onclick="cancelBooking()">Скасувати</button>
             </div>
+
+            <div class="waitlist-toggle">
+                <button class="btn btn-secondary" onclick="toggleWaitlist()">Не знайшли зручного часу? Станьте у список очікування</button>
+            </div>
+
+            <div id="waitlistForm" class="booking-form">
+                <p class="waitlist-intro">Вкажіть, коли вам зручно. Щойно звільниться відповідний час, ми надішлемо вам лист із посиланням для бронювання.</p>
+
+                <div class="form-group">
+                    <label for="waitlistName">Ваше ім'я та прізвище</label>
+                    <input type="text" id="waitlistName" placeholder="Введіть ваше повне ім'я та прізвище" required>
+                </div>
+
+                <div class="form-group">
+                    <label for="waitlistEmail">Ваш email</label>
+                    <input type="email" id="waitlistEmail" placeholder="your.email@example.com" required>
+                </div>
+
+                <div class="form-row">
+                    <div class="form-group">
+                        <label for="waitlistFrom">З дати</label>
+                        <input type="date" id="waitlistFrom">
+                    </div>
+                    <div class="form-group">
+                        <label for="waitlistTo">По дату</label>
+                        <input type="date" id="waitlistTo">
+                    </div>
+                </div>
+
+                <div class="form-group">
+                    <label>Дні тижня</label>
+                    <div class="weekday-options" id="waitlistWeekdays">
+                        <label><input type="checkbox" value="1"> Пн</label>
+                        <label><input type="checkbox" value="2"> Вт</label>
+                        <label><input type="checkbox" value="3"> Ср</label>
+                        <label><input type="checkbox" value="4"> Чт</label>
+                        <label><input type="checkbox" value="5"> Пт</label>
+                        <label><input type="checkbox" value="6"> Сб</label>
+                        <label><input type="checkbox" value="0"> Нд</label>
+                    </div>
+                </div>
+
+                <div class="form-row">
+                    <div class="form-group">
+                        <label for="waitlistStart">Час з</label>
+                        <input type="time" id="waitlistStart" value="09:00" step="1800">
+                    </div>
+                    <div class="form-group">
+                        <label for="waitlistEnd">Час до</label>
+                        <input type="time" id="waitlistEnd" value="20:30" step="1800">
+                    </div>
+                </div>
+
+                <div class="form-group" style="position: absolute; left: -10000px;" aria-hidden="true">
+                    <label for="waitlistWebsite">Website</label>
+                    <input type="text" id="waitlistWebsite" tabindex="-1" autocomplete="off">
+                </div>
+
+                <button class="btn btn-primary" onclick="joinWaitlist()">Стати у список очікування</button>
+                <button class="btn btn-secondary" onclick="toggleWaitlist()">Скасувати</button>
+            </div>
         </div>
     </div>
 
@@ -893,6 +1004,7 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
             document.getElementById('bookingForm').classList.remove('active');
             document.getElementById('name').value = '';
             document.getElementById('email').value = '';
+            document.getElementById('bookingCode').value = '';
 
             if (selectedDay) {
                 document.getElementById('timeSlotsPanel').classList.add('active');
@@ -924,11 +1036,30 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
                     body: JSON.stringify({
                         slot_time: selectedSlot.slot_time,
                         name: name,
-                        email: email
+                        email: email,
+                        code: document.getElementById('bookingCode').value.trim(),
+                        website: document.getElementById('website').value
                     })
                 });
 
-                if (response.ok) {
+                if (response.status === 202) {
+                    const data = await response.json();
+                    if (data.checkout_url) {
+                        // Paid session: the booking is confirmed once the payment goes through
+                        window.location.href = data.checkout_url;
+                        return;
+                    }
+
+                    showMessage('Майже готово! Ми надіслали лист на вашу email адресу — перейдіть за посиланням у ньому, щоб підтвердити бронювання.', 'success');
+
+                    markSlotAsBooked(selectedSlot.slot_time);
+                    const wasSelectedDay = selectedDay;
+                    cancelBooking();
+                    if (wasSelectedDay) {
+                        backToCalendar();
+                    }
+                    renderCalendar();
+                } else if (response.ok) {
                     showMessage('Бронювання підтверджено! Ви отримаєте лист-підтвердження найближчим часом.', 'success');
 
                     // Mark the slot as unavailable in local data
@@ -944,8 +1075,15 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
                     }
                     renderCalendar();
                 } else if (response.status === 409) {
+                    const error = await response.text();
+                    if (error.startsWith('Code')) {
+                        showMessage('Цей код вже використано або термін його дії минув.', 'error');
+                        return;
+                    }
                     showMessage('Цей слот вже заброньовано. Будь ласка, оберіть інший час.', 'error');
                     loadSlots(); // Reload slots to get fresh data
+                } else if (response.status === 429) {
+                    showMessage('Забагато спроб бронювання. Будь ласка, спробуйте пізніше.', 'error');
                 } else {
                     const error = await response.text();
                     showMessage('Не вдалося створити бронювання: ' + error, 'error');
@@ -956,6 +1094,64 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        function toggleWaitlist() {
+            document.getElementById('waitlistForm').classList.toggle('active');
+        }
+
+        // Join the waitlist with a single preferred window; empty fields match any date or day
+        async function joinWaitlist() {
+            const name = document.getElementById('waitlistName').value.trim();
+            const email = document.getElementById('waitlistEmail').value.trim();
+
+            if (!name || !email) {
+                showMessage('Будь ласка, заповніть всі поля', 'error');
+                return;
+            }
+
+            if (!validateEmail(email)) {
+                showMessage('Будь ласка, введіть дійсну email адресу', 'error');
+                return;
+            }
+
+            const weekdays = Array.from(document.querySelectorAll('#waitlistWeekdays input:checked'))
+                .map(input => parseInt(input.value, 10));
+            const preferred = {
+                from: document.getElementById('waitlistFrom').value,
+                to: document.getElementById('waitlistTo').value,
+                weekdays: weekdays,
+                start_time: document.getElementById('waitlistStart').value,
+                end_time: document.getElementById('waitlistEnd').value
+            };
+
+            try {
+                const response = await fetch('/api/waitlist', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({
+                        name: name,
+                        email: email,
+                        windows: [preferred],
+                        website: document.getElementById('waitlistWebsite').value
+                    })
+                });
+
+                if (response.ok) {
+                    showMessage('Вас додано до списку очікування. Ми напишемо, щойно звільниться відповідний час.', 'success');
+                    toggleWaitlist();
+                } else if (response.status === 429) {
+                    showMessage('Забагато спроб. Будь ласка, спробуйте пізніше.', 'error');
+                } else {
+                    const error = await response.text();
+                    showMessage('Не вдалося додати до списку очікування: ' + error, 'error');
+                }
+            } catch (error) {
+                console.error('Error joining waitlist:', error);
+                showMessage('Не вдалося додати до списку очікування. Будь ласка, спробуйте ще раз.', 'error');
+            }
+        }
+
         function markSlotAsBooked(slotTime) {
             // Update in allSlots array
             const slot = allSlots.find(s => s.slot_time === slotTime);
@@ -989,8 +1185,28 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
             }, 5000);
         }
 
+        // Show the outcome of an emailed confirmation link
+        function showBookingStatusFromURL() {
+            const status = new URLSearchParams(window.location.search).get('booking');
+            if (status === 'confirmed') {
+                showMessage('Бронювання підтверджено! Ви отримаєте лист-підтвердження найближчим часом.', 'success');
+            } else if (status === 'expired') {
+                showMessage('Час на підтвердження минув, і слот було звільнено. Будь ласка, оберіть час ще раз.', 'error');
+            } else if (status === 'invalid') {
+                showMessage('Посилання для підтвердження недійсне.', 'error');
+            }
+
+            const payment = new URLSearchParams(window.location.search).get('payment');
+            if (payment === 'success') {
+                showMessage('Дякуємо за оплату! Щойно платіж буде підтверджено, ви отримаєте лист-підтвердження.', 'success');
+            } else if (payment === 'cancelled') {
+                showMessage('Оплату скасовано. Слот залишається зарезервованим ще деякий час, після чого його буде звільнено.', 'error');
+            }
+        }
+
         // Initialize page - display timezone and load slots
         displayTimezone();
+        showBookingStatusFromURL();
         loadSlots();
     </script>
 </body>
@@ -1182,6 +1398,16 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             background: #ffebee;
         }
 
+        .slot-card.pending {
+            border-left: 4px dashed #2196f3;
+            background: #f3f8fd;
+        }
+
+        .slot-card.buffer {
+            border-left: 4px dashed #9e9e9e;
+            background: #f5f5f5;
+        }
+
         .slot-info h4 {
             font-size: 1.1rem;
             margin-bottom: 8px;
@@ -1226,6 +1452,16 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             color: #c62828;
         }
 
+        .status-badge.pending {
+            background: #e3f2fd;
+            color: #1565c0;
+        }
+
+        .status-badge.buffer {
+            background: #eeeeee;
+            color: #616161;
+        }
+
         .slot-actions {
             display: flex;
             gap: 10px;
@@ -1434,6 +1670,99 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             font-weight: 600;
         }
 
+        .notes-list {
+            display: flex;
+            flex-direction: column;
+            gap: 10px;
+            margin-bottom: 12px;
+        }
+
+        .note-item {
+            background: #f8f8f8;
+            border-radius: 6px;
+            padding: 10px 12px;
+        }
+
+        .note-body {
+            white-space: pre-wrap;
+            color: #333;
+            font-size: 0.95rem;
+        }
+
+        .note-meta {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            margin-top: 6px;
+            font-size: 0.8rem;
+            color: #888;
+        }
+
+        .note-delete {
+            background: none;
+            border: none;
+            color: #f44336;
+            cursor: pointer;
+            font-size: 0.8rem;
+        }
+
+        .note-input {
+            width: 100%;
+            padding: 10px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+            font-family: inherit;
+            font-size: 0.95rem;
+            margin-bottom: 10px;
+            resize: vertical;
+        }
+
+        .notes-search {
+            display: flex;
+            gap: 10px;
+            flex-wrap: wrap;
+            margin-bottom: 20px;
+        }
+
+        .notes-search input {
+            flex: 1;
+            min-width: 200px;
+            padding: 10px;
+            border: 1px solid #ddd;
+            border-radius: 6px;
+            font-size: 0.95rem;
+        }
+
+        .notes-search .notes-list {
+            width: 100%;
+        }
+
+        .feature-flags {
+            display: flex;
+            gap: 10px;
+            flex-wrap: wrap;
+            margin-bottom: 20px;
+        }
+
+        .feature-flag {
+            display: flex;
+            align-items: center;
+            gap: 6px;
+        }
+
+        .feature-flag .flag-default {
+            font-size: 0.8rem;
+            color: #888;
+        }
+
+        .feature-flag .flag-reset {
+            background: none;
+            border: none;
+            color: var(--primary-start);
+            cursor: pointer;
+            font-size: 0.8rem;
+        }
+
         .slot-card {
             cursor: pointer;
         }
@@ -1501,6 +1830,18 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
                     <h3 id="blockedSlots">-</h3>
                     <p>Заблоковані</p>
                 </div>
+                <div class="stat-card">
+                    <h3 id="bufferSlots">-</h3>
+                    <p>Буфер між сесіями</p>
+                </div>
+            </div>
+
+            <div id="featureFlags" class="feature-flags"></div>
+
+            <div class="notes-search">
+                <input type="search" id="notesSearchInput" placeholder="Пошук у нотатках..." onkeydown="if (event.key === 'Enter') searchNotes()">
+                <button class="filter-btn" onclick="searchNotes()">Шукати</button>
+                <div id="notesSearchResults" class="notes-list"></div>
             </div>
 
             <div class="filters">
@@ -1508,6 +1849,7 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
                 <button class="filter-btn" onclick="filterSlots('available')">Доступні</button>
                 <button class="filter-btn" onclick="filterSlots('booked')">Заброньовані</button>
                 <button class="filter-btn" onclick="filterSlots('blocked')">Заблоковані</button>
+                <button class="filter-btn" onclick="filterSlots('buffer')">Буфер</button>
             </div>
 
             <div id="loading" class="loading">
@@ -1546,6 +1888,26 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
                     <div class="modal-detail-label">Email клієнта</div>
                     <div class="modal-detail-value" id="modalEmail"></div>
                 </div>
+                <div class="modal-detail-row">
+                    <div class="modal-detail-label">Змінити статус</div>
+                    <div class="slot-actions">
+                        <button class="action-btn unblock" onclick="updateBookingStatus('completed')">Проведено</button>
+                        <button class="action-btn block" onclick="updateBookingStatus('no_show')">Не з'явився</button>
+                        <button class="action-btn cancel" onclick="updateBookingStatus('cancelled_by_client')">Скасовано клієнтом</button>
+                    </div>
+                </div>
+                <div class="modal-detail-row">
+                    <div class="modal-detail-label">Нотатки до сесії</div>
+                    <div id="bookingNotes" class="notes-list"></div>
+                    <textarea id="bookingNoteInput" class="note-input" rows="4" placeholder="Нотатка у форматі Markdown..."></textarea>
+                    <button class="action-btn unblock" onclick="saveNote('booking')">Зберегти нотатку</button>
+                </div>
+                <div class="modal-detail-row">
+                    <div class="modal-detail-label">Нотатки про клієнта</div>
+                    <div id="clientNotes" class="notes-list"></div>
+                    <textarea id="clientNoteInput" class="note-input" rows="4" placeholder="Нотатка у форматі Markdown..."></textarea>
+                    <button class="action-btn unblock" onclick="saveNote('client')">Зберегти нотатку</button>
+                </div>
             </div>
         </div>
     </div>
@@ -1576,11 +1938,172 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             });
         }
 
+        let modalSlot = null;
+
         function openBookingModal(slot) {
+            modalSlot = slot;
             document.getElementById('modalDateTime').textContent = formatDateTime(slot.slot_time);
             document.getElementById('modalName').textContent = slot.name || 'N/A';
             document.getElementById('modalEmail').textContent = slot.email || 'N/A';
+            document.getElementById('bookingNoteInput').value = '';
+            document.getElementById('clientNoteInput').value = '';
             document.getElementById('bookingModal').classList.add('active');
+            loadNotes();
+        }
+
+        // Private session notes: built with textContent so note text is never interpreted as HTML
+        function renderNotes(containerId, notes) {
+            const container = document.getElementById(containerId);
+            container.innerHTML = '';
+            notes.forEach(note => {
+                const item = document.createElement('div');
+                item.className = 'note-item';
+
+                const body = document.createElement('div');
+                body.className = 'note-body';
+                body.textContent = note.body;
+                item.appendChild(body);
+
+                const meta = document.createElement('div');
+                meta.className = 'note-meta';
+                const info = document.createElement('span');
+                info.textContent = formatDateTime(note.updated_at) + ' · ' + note.created_by;
+                meta.appendChild(info);
+
+                const deleteBtn = document.createElement('button');
+                deleteBtn.className = 'note-delete';
+                deleteBtn.textContent = 'Видалити';
+                deleteBtn.onclick = () => deleteNote(note.id);
+                meta.appendChild(deleteBtn);
+
+                item.appendChild(meta);
+                container.appendChild(item);
+            });
+        }
+
+        async function fetchNotes(query) {
+            const response = await fetch('/api/admin/notes?' + query);
+            if (response.status === 503) {
+                throw new Error('Нотатки вимкнено: не налаштовано ключ шифрування');
+            }
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            return response.json();
+        }
+
+        async function loadNotes() {
+            renderNotes('bookingNotes', []);
+            renderNotes('clientNotes', []);
+            if (!modalSlot || !modalSlot.booking_id) {
+                return;
+            }
+
+            try {
+                renderNotes('bookingNotes', await fetchNotes('booking_id=' + modalSlot.booking_id));
+                if (modalSlot.client_id) {
+                    const clientNotes = await fetchNotes('client_id=' + modalSlot.client_id);
+                    renderNotes('clientNotes', clientNotes.filter(note => note.client_id));
+                }
+            } catch (error) {
+                console.error('Error loading notes:', error);
+                showMessage('Не вдалося завантажити нотатки: ' + error.message, 'error');
+            }
+        }
+
+        async function saveNote(target) {
+            if (!modalSlot || !modalSlot.booking_id) {
+                return;
+            }
+
+            const input = document.getElementById(target + 'NoteInput');
+            const body = input.value.trim();
+            if (!body) {
+                return;
+            }
+
+            const payload = { body: body };
+            if (target === 'booking') {
+                payload.booking_id = modalSlot.booking_id;
+            } else if (modalSlot.client_id) {
+                payload.client_id = modalSlot.client_id;
+            } else {
+                showMessage('Клієнта для цього бронювання не знайдено', 'error');
+                return;
+            }
+
+            try {
+                const response = await fetch('/api/admin/notes', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify(payload)
+                });
+
+                if (response.ok) {
+                    input.value = '';
+                    showMessage('Нотатку збережено', 'success');
+                    await loadNotes();
+                } else {
+                    const error = await response.text();
+                    showMessage('Не вдалося зберегти нотатку: ' + error, 'error');
+                }
+            } catch (error) {
+                console.error('Error saving note:', error);
+                showMessage('Не вдалося зберегти нотатку. Будь ласка, спробуйте ще раз.', 'error');
+            }
+        }
+
+        async function deleteNote(id) {
+            if (!confirm('Видалити цю нотатку?')) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/api/admin/notes/delete', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ id: id })
+                });
+
+                if (response.ok) {
+                    showMessage('Нотатку видалено', 'success');
+                    if (document.getElementById('bookingModal').classList.contains('active')) {
+                        await loadNotes();
+                    }
+                    if (document.getElementById('notesSearchInput').value.trim()) {
+                        await searchNotes();
+                    }
+                } else {
+                    const error = await response.text();
+                    showMessage('Не вдалося видалити нотатку: ' + error, 'error');
+                }
+            } catch (error) {
+                console.error('Error deleting note:', error);
+                showMessage('Не вдалося видалити нотатку. Будь ласка, спробуйте ще раз.', 'error');
+            }
+        }
+
+        async function searchNotes() {
+            const query = document.getElementById('notesSearchInput').value.trim();
+            if (!query) {
+                renderNotes('notesSearchResults', []);
+                return;
+            }
+
+            try {
+                const notes = await fetchNotes('q=' + encodeURIComponent(query));
+                renderNotes('notesSearchResults', notes);
+                if (notes.length === 0) {
+                    showMessage('Нічого не знайдено', 'success');
+                }
+            } catch (error) {
+                console.error('Error searching notes:', error);
+                showMessage('Не вдалося виконати пошук: ' + error.message, 'error');
+            }
         }
 
         function closeModal() {
@@ -1627,13 +2150,15 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
                 total: allSlots.length,
                 available: allSlots.filter(s => s.status === 'available').length,
                 booked: allSlots.filter(s => s.status === 'booked').length,
-                blocked: allSlots.filter(s => s.status === 'blocked').length
+                blocked: allSlots.filter(s => s.status === 'blocked').length,
+                buffer: allSlots.filter(s => s.status === 'buffer').length
             };
 
             document.getElementById('totalSlots').textContent = stats.total;
             document.getElementById('availableSlots').textContent = stats.available;
             document.getElementById('bookedSlots').textContent = stats.booked;
             document.getElementById('blockedSlots').textContent = stats.blocked;
+            document.getElementById('bufferSlots').textContent = stats.buffer;
         }
 
         function filterSlots(filter) {
@@ -1666,7 +2191,7 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
                 slotCard.className = 'slot-card ' + slot.status;
 
                 // Add click handler for booked slots to open modal
-                if (slot.status === 'booked') {
+                if (slot.status === 'booked' || slot.status === 'pending') {
                     slotCard.onclick = function() {
                         openBookingModal(slot);
                     };
@@ -1674,16 +2199,16 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
 
                 let detailsHTML = '<span class="status-badge ' + slot.status + '">' + slot.status + '</span>';
 
-                if (slot.status === 'booked' && slot.name && slot.email) {
+                if ((slot.status === 'booked' || slot.status === 'pending') && slot.name && slot.email) {
                     detailsHTML += '<div class="slot-detail">👤 ' + slot.name + '</div>';
                 }
 
                 let actionsHTML = '';
-                if (slot.status === 'available') {
+                if (slot.status === 'available' || slot.status === 'buffer') {
                     actionsHTML = '<button class="action-btn block" onclick="event.stopPropagation(); blockSlot(\'' + slot.slot_time + '\')">Заблокувати</button>';
                 } else if (slot.status === 'blocked') {
                     actionsHTML = '<button class="action-btn unblock" onclick="event.stopPropagation(); unblockSlot(\'' + slot.slot_time + '\')">Розблокувати</button>';
-                } else if (slot.status === 'booked') {
+                } else if (slot.status === 'booked' || slot.status === 'pending') {
                     actionsHTML = '<button class="action-btn cancel" onclick="event.stopPropagation(); cancelBooking(\'' + slot.slot_time + '\', \'' + slot.name + '\')">Скасувати</button>';
                 }
 
@@ -1771,6 +2296,124 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        async function updateBookingStatus(status, reason, override) {
+            if (!modalSlot || !modalSlot.booking_id) {
+                return;
+            }
+
+            if (status === 'cancelled_by_client' && reason === undefined) {
+                reason = prompt('Причина скасування (необов\'язково):') || '';
+            }
+
+            try {
+                const response = await fetch('/api/admin/bookings/status', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify({ id: modalSlot.booking_id, status: status, reason: reason || '', override: !!override })
+                });
+
+                if (response.status === 409 && status === 'cancelled_by_client' && !override) {
+                    const error = await response.text();
+                    if (error.includes('cut-off') && confirm('Термін безкоштовного скасування минув. Все одно позначити як скасоване клієнтом?')) {
+                        await updateBookingStatus(status, reason, true);
+                        return;
+                    }
+                    showMessage('Не вдалося оновити статус: ' + error, 'error');
+                } else if (response.ok) {
+                    showMessage('Статус бронювання оновлено', 'success');
+                    closeModal();
+                    await loadSlots();
+                } else {
+                    const error = await response.text();
+                    showMessage('Не вдалося оновити статус: ' + error, 'error');
+                }
+            } catch (error) {
+                console.error('Error updating booking status:', error);
+                showMessage('Не вдалося оновити статус. Будь ласка, спробуйте ще раз.', 'error');
+            }
+        }
+
+        const featureFlagLabels = {
+            booking_open: 'Бронювання відкрите',
+            maintenance_mode: 'Технічні роботи',
+            create_zoom_meeting: 'Створювати Zoom зустрічі',
+            send_confirmation_email: 'Надсилати листи клієнтам'
+        };
+
+        async function loadFeatureFlags() {
+            try {
+                const response = await fetch('/api/admin/flags');
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                renderFeatureFlags(await response.json());
+            } catch (error) {
+                console.error('Error loading feature flags:', error);
+                showMessage('Не вдалося завантажити налаштування. Будь ласка, оновіть сторінку.', 'error');
+            }
+        }
+
+        function renderFeatureFlags(flags) {
+            const container = document.getElementById('featureFlags');
+            container.innerHTML = '';
+            flags.forEach(flag => {
+                const item = document.createElement('div');
+                item.className = 'feature-flag';
+
+                const button = document.createElement('button');
+                button.className = 'filter-btn' + (flag.enabled ? ' active' : '');
+                button.textContent = (featureFlagLabels[flag.key] || flag.key) + ': ' + (flag.enabled ? 'увімк.' : 'вимк.');
+                button.onclick = () => saveFeatureFlag({ key: flag.key, enabled: !flag.enabled });
+                item.appendChild(button);
+
+                if (flag.overridden) {
+                    const reset = document.createElement('button');
+                    reset.className = 'flag-reset';
+                    reset.textContent = '↺ за замовчуванням (' + (flag.default ? 'увімк.' : 'вимк.') + ')';
+                    reset.title = 'Змінено: ' + (flag.updated_by || '') + ' ' + (flag.updated_at ? formatDateTime(flag.updated_at) : '');
+                    reset.onclick = () => saveFeatureFlag({ key: flag.key, reset: true });
+                    item.appendChild(reset);
+                } else {
+                    const note = document.createElement('span');
+                    note.className = 'flag-default';
+                    note.textContent = 'за замовчуванням';
+                    item.appendChild(note);
+                }
+
+                container.appendChild(item);
+            });
+        }
+
+        async function saveFeatureFlag(change) {
+            if (change.key === 'maintenance_mode' && change.enabled &&
+                !confirm('Увімкнути режим технічних робіт? Сторінка бронювання стане недоступною для клієнтів.')) {
+                return;
+            }
+
+            try {
+                const response = await fetch('/api/admin/flags', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json'
+                    },
+                    body: JSON.stringify(change)
+                });
+
+                if (response.ok) {
+                    showMessage('Налаштування збережено', 'success');
+                    await loadFeatureFlags();
+                } else {
+                    const error = await response.text();
+                    showMessage('Не вдалося зберегти налаштування: ' + error, 'error');
+                }
+            } catch (error) {
+                console.error('Error saving feature flag:', error);
+                showMessage('Не вдалося зберегти налаштування. Будь ласка, спробуйте ще раз.', 'error');
+            }
+        }
+
         function showMessage(text, type) {
             const messageDiv = document.getElementById('message');
             messageDiv.textContent = text;
@@ -1808,9 +2451,10 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
             changeTheme(savedTheme);
         }
 
-        // Display timezone, load theme and load slots when page loads
+        // Display timezone, load theme, feature flags and slots when page loads
         loadSavedTheme();
         displayTimezone();
+        loadFeatureFlags();
         loadSlots();
     </script>
 </body>
@@ -1819,6 +2463,95 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, html)
 }
 
+// confirmBookingPage asks the client to confirm a held booking. The emailed link only opens
+// this page; the booking is confirmed by the form's POST, so link scanners and prefetching
+// mail clients cannot confirm it.
+var confirmBookingPage = template.Must(template.New("confirm").Parse(`
+<!DOCTYPE html>
+<html lang="uk">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>Підтвердження бронювання</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
+            background: linear-gradient(135deg, #800020 0%, #5c0011 100%);
+            min-height: 100vh;
+            margin: 0;
+            padding: 20px;
+            box-sizing: border-box;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+        }
+
+        .card {
+            max-width: 480px;
+            background: white;
+            border-radius: 16px;
+            box-shadow: 0 20px 60px rgba(0,0,0,0.3);
+            padding: 40px;
+            text-align: center;
+        }
+
+        h1 {
+            color: #800020;
+            font-size: 1.6rem;
+            margin: 0 0 16px;
+        }
+
+        p {
+            color: #333;
+            line-height: 1.5;
+        }
+
+        button {
+            margin-top: 20px;
+            padding: 14px 32px;
+            border: none;
+            border-radius: 8px;
+            background: #800020;
+            color: white;
+            font-size: 1.1rem;
+            cursor: pointer;
+        }
+
+        button:hover {
+            background: #a0153e;
+        }
+    </style>
+</head>
+<body>
+    <div class="card">
+        <h1>Підтвердження бронювання</h1>
+        <p>{{.Name}}, ви бронюєте сесію на <strong>{{.SlotTime}}</strong>.</p>
+        <p>Натисніть кнопку нижче, щоб підтвердити бронювання.</p>
+        <form method="POST" action="/api/bookings/confirm">
+            <input type="hidden" name="token" value="{{.Token}}">
+            <button type="submit">Підтвердити</button>
+        </form>
+    </div>
+</body>
+</html>
+`))
+
+// renderConfirmBookingPage writes the confirmation page for the booking held by token
+func renderConfirmBookingPage(w http.ResponseWriter, name string, slotTime time.Time, token string) {
+	// The URL carries the token: keep the page out of caches and referrers
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	confirmBookingPage.Execute(w, map[string]string{
+		"Name":     name,
+		"SlotTime": slotTime.Format("02.01.2006 15:04"),
+		"Token":    token,
+	})
+}
+
+// HealthHandler is the liveness probe: it answers as long as the process can serve
+// requests, without touching the database. Use Readiness to decide where to route traffic.
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "OK")
@@ -0,0 +1,765 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Payment statuses
+const (
+	PaymentPending      = "pending"
+	PaymentPaid         = "paid"
+	PaymentExpired      = "expired"
+	PaymentRefunding    = "refunding"
+	PaymentRefunded     = "refunded"
+	PaymentRefundFailed = "refund_failed"
+)
+
+// Audit actions for payments
+const (
+	AuditPaymentCreated      = "payment.created"
+	AuditPaymentPaid         = "payment.paid"
+	AuditPaymentExpired      = "payment.expired"
+	AuditPaymentRefunded     = "payment.refunded"
+	AuditPaymentRefundFailed = "payment.refund_failed"
+	AuditBookingHoldReleased = "booking.hold_released"
+)
+
+// DefaultPaymentHold is how long a paid booking holds its slot while the client pays
+const DefaultPaymentHold = 30 * time.Minute
+
+// refundRetryAfter is how long a payment may stay refunding before maintenance retries the
+// refund; well past the payment client's timeout, so a call still under way is not repeated
+const refundRetryAfter = 5 * time.Minute
+
+var (
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrInvalidWebhook  = errors.New("invalid payment webhook")
+
+	// errPaymentHandled reports a webhook delivered again after it was processed
+	errPaymentHandled = errors.New("payment already handled")
+)
+
+// Payment event types reported by a provider's webhook
+const (
+	PaymentEventPaid    = "paid"
+	PaymentEventExpired = "expired"
+)
+
+// CheckoutRequest describes what the client is asked to pay for a held booking
+type CheckoutRequest struct {
+	BookingID     int
+	Description   string
+	AmountCents   int
+	Currency      string
+	CustomerEmail string
+	SuccessURL    string
+	CancelURL     string
+	ExpiresAt     time.Time
+}
+
+// Checkout is a hosted payment page created by the provider
+type Checkout struct {
+	ID  string
+	URL string
+}
+
+// PaymentEvent is a verified webhook notification. Type is empty for events the
+// application does not act on.
+type PaymentEvent struct {
+	Type       string
+	CheckoutID string
+	PaymentID  string
+}
+
+// PaymentProvider takes payments for paid session types
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	// ExpireCheckout closes a checkout so it can no longer be paid
+	ExpireCheckout(ctx context.Context, checkoutID string) error
+	// ParseWebhook verifies a webhook request and returns the event it carries
+	ParseWebhook(r *http.Request) (*PaymentEvent, error)
+	// Refund returns a payment in full, returning the provider's refund ID
+	Refund(ctx context.Context, paymentID string) (string, error)
+}
+
+// Payment is a checkout for a paid booking and what became of it
+type Payment struct {
+	ID          int        `json:"id"`
+	BookingID   int        `json:"booking_id,omitempty"`
+	Provider    string     `json:"provider"`
+	CheckoutID  string     `json:"checkout_id"`
+	PaymentID   string     `json:"payment_id,omitempty"`
+	RefundID    string     `json:"refund_id,omitempty"`
+	AmountCents int        `json:"amount_cents"`
+	Currency    string     `json:"currency"`
+	Status      string     `json:"status"`
+	SlotTime    time.Time  `json:"slot_time"`
+	Email       string     `json:"email"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	RefundedAt  *time.Time `json:"refunded_at,omitempty"`
+}
+
+const paymentColumns = `id, booking_id, provider, checkout_id, COALESCE(payment_id, ''), COALESCE(refund_id, ''),
+	amount_cents, currency, status, slot_time, email, COALESCE(error, ''), created_at, paid_at, refunded_at`
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var p Payment
+	var bookingID sql.NullInt64
+	var paidAt, refundedAt sql.NullTime
+	err := row.Scan(&p.ID, &bookingID, &p.Provider, &p.CheckoutID, &p.PaymentID, &p.RefundID,
+		&p.AmountCents, &p.Currency, &p.Status, &p.SlotTime, &p.Email, &p.Error, &p.CreatedAt, &paidAt, &refundedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.BookingID = int(bookingID.Int64)
+	p.PaidAt = nullTimePtr(paidAt)
+	p.RefundedAt = nullTimePtr(refundedAt)
+	return &p, nil
+}
+
+func loadPayment(ctx context.Context, q queryRower, where string, arg interface{}, lock bool) (*Payment, error) {
+	query := "SELECT " + paymentColumns + " FROM payments WHERE " + where
+	if lock {
+		query += " FOR UPDATE"
+	}
+	p, err := scanPayment(q.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return p, err
+}
+
+func (h *APIHandlers) paymentHold() time.Duration {
+	if h.PaymentHold > 0 {
+		return h.PaymentHold
+	}
+	return DefaultPaymentHold
+}
+
+// createPaymentHold holds the slot as a pending booking and sends the client to the
+// provider's checkout for the price after any discount code. The booking is confirmed by
+// the provider's webhook once paid.
+func (h *APIHandlers) createPaymentHold(w http.ResponseWriter, r *http.Request, req BookingRequest, guard *slotGuard, redemption *codeRedemption) {
+	slotType, slotTime := guard.slotType, guard.slotTime
+	if h.Payments == nil {
+		http.Error(w, "Paid sessions are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	audit := auditorFromRequest(r)
+	expiresAt := time.Now().Add(h.paymentHold())
+	id, err := h.insertRedeemedBooking(r.Context(), audit, AuditBookingHeld, guard, redemption,
+		`INSERT INTO bookings (slot_time, name, email, duration, session_type, status, hold_expires_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6)`,
+		slotTime.UTC(), req.Name, req.Email, slotType.DurationMinutes, slotType.Key, expiresAt.UTC(),
+	)
+	if err != nil {
+		if !slotTakenError(w, err) && !codeError(w, err) {
+			http.Error(w, "Failed to create booking", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error creating payment hold", "error", err)
+		}
+		return
+	}
+
+	baseURL := h.PublicBaseURL
+	checkout, err := h.Payments.CreateCheckout(r.Context(), CheckoutRequest{
+		BookingID:     id,
+		Description:   fmt.Sprintf("%s, %s", slotType.Name, slotTime.In(h.Policy.location()).Format("02.01.2006 15:04")),
+		AmountCents:   redemption.PriceCents,
+		Currency:      slotType.Currency,
+		CustomerEmail: req.Email,
+		SuccessURL:    baseURL + "/?payment=success",
+		CancelURL:     baseURL + "/?payment=cancelled",
+		ExpiresAt:     expiresAt,
+	})
+	if err == nil {
+		err = h.savePayment(r.Context(), audit, id, checkout.ID, redemption.PriceCents, slotType.Currency, slotTime, req.Email)
+		if err != nil {
+			if expireErr := h.Payments.ExpireCheckout(context.WithoutCancel(r.Context()), checkout.ID); expireErr != nil {
+				slog.WarnContext(r.Context(), "Failed to expire checkout", "checkout_id", checkout.ID, "error", expireErr)
+			}
+		}
+	}
+	if err != nil {
+		// Release the hold even if the client has disconnected, so the slot is not stuck
+		if releaseErr := h.releaseHold(context.WithoutCancel(r.Context()), audit, id); releaseErr != nil {
+			slog.WarnContext(r.Context(), "Failed to release hold", "booking_id", id, "error", releaseErr)
+		}
+		http.Error(w, "Failed to start payment", http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "Error creating checkout", "booking_id", id, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":         "Booking pending payment",
+		"status":          "pending_payment",
+		"checkout_url":    checkout.URL,
+		"hold_expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (h *APIHandlers) savePayment(ctx context.Context, audit auditor, bookingID int, checkoutID string, amountCents int, currency string, slotTime time.Time, email string) error {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO payments (booking_id, provider, checkout_id, amount_cents, currency, slot_time, email)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		bookingID, h.Payments.Name(), checkoutID, amountCents, currency, slotTime.UTC(), email,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	payment, err := loadPayment(ctx, tx, "id = $1", id, false)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(ctx, tx, AuditPaymentCreated, "payment", strconv.Itoa(id), nil, payment); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// releaseHold expires a pending booking that will not be confirmed, freeing its slot and
+// giving back any package credit or discount use it took
+func (h *APIHandlers) releaseHold(ctx context.Context, audit auditor, bookingID int) error {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := loadBooking(ctx, tx, bookingID, true)
+	if err == ErrBookingNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if before.Status != StatusPending {
+		return nil
+	}
+
+	if err := releaseRedemptions(ctx, tx, audit, before); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE bookings SET status = 'expired', status_updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		bookingID,
+	)
+	if err != nil {
+		return err
+	}
+	after, err := loadBooking(ctx, tx, bookingID, false)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(ctx, tx, AuditBookingHoldReleased, "booking", strconv.Itoa(bookingID), before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PaymentWebhook receives payment notifications from the provider. Paid checkouts confirm
+// their booking; a payment arriving after its hold was released is refunded right away.
+// Expired checkouts release the hold.
+func (h *APIHandlers) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Payments == nil {
+		http.Error(w, "Payments are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	event, err := h.Payments.ParseWebhook(r)
+	if err != nil {
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "Rejected payment webhook", "error", err)
+		return
+	}
+
+	switch event.Type {
+	case PaymentEventPaid:
+		err = h.handlePaymentPaid(r.Context(), event)
+	case PaymentEventExpired:
+		err = h.handlePaymentExpired(r.Context(), event)
+	}
+	if errors.Is(err, ErrPaymentNotFound) {
+		// Not one of ours, e.g. another application on the same account
+		slog.InfoContext(r.Context(), "Ignoring payment webhook for unknown checkout", "checkout_id", event.CheckoutID)
+		err = nil
+	}
+	if err != nil {
+		// A non-2xx response makes the provider retry the webhook
+		http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error processing payment webhook", "checkout_id", event.CheckoutID, "error", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *APIHandlers) handlePaymentPaid(ctx context.Context, event *PaymentEvent) error {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+	payment, err := loadPayment(dbCtx, h.DB, "checkout_id = $1", event.CheckoutID, false)
+	if err != nil {
+		return err
+	}
+	if payment.Status != PaymentPending {
+		return nil
+	}
+
+	var booking *Booking
+	if payment.BookingID != 0 {
+		booking, err = loadBooking(dbCtx, h.DB, payment.BookingID, false)
+		if err != nil && err != ErrBookingNotFound {
+			return err
+		}
+	}
+	zoomLink := ""
+	if booking != nil && booking.Status == StatusPending {
+		zoomLink = h.createZoomMeeting(ctx, booking.Name, booking.Email, booking.SlotTime)
+	}
+
+	confirmed, err := h.confirmPaidBooking(ctx, payment.ID, event.PaymentID, zoomLink)
+	if errors.Is(err, errPaymentHandled) {
+		h.deleteZoomMeeting(ctx, zoomLink)
+		return nil
+	}
+	if err != nil {
+		h.deleteZoomMeeting(ctx, zoomLink)
+		return err
+	}
+
+	if !confirmed {
+		// The hold is gone, so the client paid for a slot they no longer have
+		h.deleteZoomMeeting(ctx, zoomLink)
+		slog.WarnContext(ctx, "Payment arrived after its hold was released; refunding", "payment_id", payment.ID)
+		return h.refundPayment(ctx, systemAuditor("payments"), payment.ID)
+	}
+
+	dbCtx, cancel = h.dbContext(ctx)
+	defer cancel()
+	booking, err = loadBooking(dbCtx, h.DB, payment.BookingID, false)
+	if err != nil {
+		slog.WarnContext(ctx, "Booking confirmed but could not be loaded for the confirmation email", "booking_id", payment.BookingID, "error", err)
+		return nil
+	}
+	h.sendConfirmationEmail(ctx, booking.ID, booking.Name, booking.Email, booking.SlotTime, booking.Duration(), true, zoomLink)
+	slog.InfoContext(ctx, "Booking confirmed by payment", "booking_id", booking.ID, "payment_id", payment.ID)
+	return nil
+}
+
+// confirmPaidBooking records a payment as paid and confirms its booking if the booking still
+// holds its slot. It reports whether the booking was confirmed.
+func (h *APIHandlers) confirmPaidBooking(ctx context.Context, paymentID int, providerPaymentID, zoomLink string) (bool, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadPayment(ctx, tx, "id = $1", paymentID, true)
+	if err != nil {
+		return false, err
+	}
+	if before.Status != PaymentPending {
+		return false, errPaymentHandled
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE payments SET status = 'paid', payment_id = $1, paid_at = CURRENT_TIMESTAMP WHERE id = $2",
+		nullString(providerPaymentID), paymentID,
+	)
+	if err != nil {
+		return false, err
+	}
+	after, err := loadPayment(ctx, tx, "id = $1", paymentID, false)
+	if err != nil {
+		return false, err
+	}
+	audit := systemAuditor("payments")
+	if err := audit.record(ctx, tx, AuditPaymentPaid, "payment", strconv.Itoa(paymentID), before, after); err != nil {
+		return false, err
+	}
+
+	confirmed := false
+	if before.BookingID != 0 {
+		// The hold may have lapsed moments ago; as long as the booking still exists it keeps the slot
+		bookingBefore, err := loadBooking(ctx, tx, before.BookingID, true)
+		if err != nil && err != ErrBookingNotFound {
+			return false, err
+		}
+		if bookingBefore != nil && bookingBefore.Status == StatusPending {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE bookings SET status = 'confirmed', zoom_link = $1, hold_expires_at = NULL,
+					confirmed_at = CURRENT_TIMESTAMP, status_updated_at = CURRENT_TIMESTAMP
+				WHERE id = $2`,
+				nullString(zoomLink), before.BookingID,
+			)
+			if err != nil {
+				return false, err
+			}
+			bookingAfter, err := loadBooking(ctx, tx, before.BookingID, false)
+			if err != nil {
+				return false, err
+			}
+			if err := audit.record(ctx, tx, AuditBookingConfirmed, "booking", strconv.Itoa(before.BookingID), bookingBefore, bookingAfter); err != nil {
+				return false, err
+			}
+			confirmed = true
+		}
+	}
+
+	return confirmed, tx.Commit()
+}
+
+func (h *APIHandlers) handlePaymentExpired(ctx context.Context, event *PaymentEvent) error {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	audit := systemAuditor("payments")
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := loadPayment(dbCtx, tx, "checkout_id = $1", event.CheckoutID, true)
+	if err != nil {
+		return err
+	}
+	if before.Status != PaymentPending {
+		return nil
+	}
+	if _, err := tx.ExecContext(dbCtx, "UPDATE payments SET status = 'expired' WHERE id = $1", before.ID); err != nil {
+		return err
+	}
+	after, err := loadPayment(dbCtx, tx, "id = $1", before.ID, false)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(dbCtx, tx, AuditPaymentExpired, "payment", strconv.Itoa(before.ID), before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if before.BookingID != 0 {
+		return h.releaseHold(ctx, audit, before.BookingID)
+	}
+	return nil
+}
+
+// refundPayment refunds a paid payment in full. The payment is marked refunding and
+// committed before the provider is called, so no row lock is held across the call; the
+// outcome is recorded in a second transaction. A failed refund is recorded as
+// refund_failed so the coach can settle it by hand.
+func (h *APIHandlers) refundPayment(ctx context.Context, audit auditor, paymentID int) error {
+	before, err := h.startRefund(ctx, paymentID, PaymentPaid)
+	if err != nil || before == nil {
+		return err
+	}
+	return h.finishRefund(ctx, audit, before)
+}
+
+// startRefund moves a payment from status from to refunding, returning its state before
+// the move, or nil when the payment is no longer in that status
+func (h *APIHandlers) startRefund(ctx context.Context, paymentID int, from string) (*Payment, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadPayment(dbCtx, tx, "id = $1", paymentID, true)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != from {
+		return nil, nil
+	}
+	_, err = tx.ExecContext(dbCtx,
+		"UPDATE payments SET status = 'refunding', refund_requested_at = CURRENT_TIMESTAMP WHERE id = $1",
+		paymentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return before, tx.Commit()
+}
+
+// finishRefund asks the provider for the refund of a payment marked refunding and records
+// the outcome. The provider's idempotency key makes a repeated call for the same payment
+// return the original refund.
+func (h *APIHandlers) finishRefund(ctx context.Context, audit auditor, before *Payment) error {
+	refundID, refundErr := h.Payments.Refund(ctx, before.PaymentID)
+
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	action := AuditPaymentRefunded
+	var res sql.Result
+	if refundErr != nil {
+		action = AuditPaymentRefundFailed
+		res, err = tx.ExecContext(dbCtx,
+			"UPDATE payments SET status = 'refund_failed', error = $1 WHERE id = $2 AND status = 'refunding'",
+			refundErr.Error(), before.ID,
+		)
+	} else {
+		res, err = tx.ExecContext(dbCtx,
+			"UPDATE payments SET status = 'refunded', refund_id = $1, refunded_at = CURRENT_TIMESTAMP, error = NULL WHERE id = $2 AND status = 'refunding'",
+			refundID, before.ID,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Another pass already recorded the outcome
+		return err
+	}
+
+	after, err := loadPayment(dbCtx, tx, "id = $1", before.ID, false)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(dbCtx, tx, action, "payment", strconv.Itoa(before.ID), before, after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if refundErr != nil {
+		return fmt.Errorf("refund of payment %d failed: %w", before.ID, refundErr)
+	}
+	slog.InfoContext(ctx, "Payment refunded", "payment_id", before.ID, "refund_id", refundID)
+	return nil
+}
+
+// RetryStalledRefunds finishes refunds left in the refunding status for longer than
+// refundRetryAfter, e.g. by a restart between marking the payment and recording the
+// provider's answer. It returns the number of refunds retried.
+func (h *APIHandlers) RetryStalledRefunds(ctx context.Context) (int, error) {
+	if h.Payments == nil {
+		return 0, nil
+	}
+
+	dbCtx, cancel := h.dbContext(ctx)
+	rows, err := h.DB.QueryContext(dbCtx,
+		"SELECT id FROM payments WHERE status = 'refunding' AND refund_requested_at < $1",
+		time.Now().Add(-refundRetryAfter),
+	)
+	if err != nil {
+		cancel()
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			cancel()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	retried := 0
+	for _, id := range ids {
+		before, err := h.startRefund(ctx, id, PaymentRefunding)
+		if err != nil {
+			return retried, err
+		}
+		if before == nil {
+			continue
+		}
+		retried++
+		if err := h.finishRefund(ctx, systemAuditor("payments"), before); err != nil {
+			slog.ErrorContext(ctx, "Error retrying refund", "payment_id", id, "error", err)
+		}
+	}
+	return retried, nil
+}
+
+// refundBooking refunds the payment of a booking the coach cancelled, if it was paid
+func (h *APIHandlers) refundBooking(ctx context.Context, audit auditor, bookingID int) {
+	if h.Payments == nil {
+		return
+	}
+	dbCtx, cancel := h.dbContext(ctx)
+	payment, err := loadPayment(dbCtx, h.DB, "booking_id = $1 AND status = 'paid'", bookingID, false)
+	cancel()
+	if err == ErrPaymentNotFound {
+		return
+	}
+	if err == nil {
+		err = h.refundPayment(ctx, audit, payment.ID)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to refund booking", "booking_id", bookingID, "error", err)
+	}
+}
+
+// ExpireUnpaidCheckouts closes the checkouts of payment holds expired by ExpirePendingHolds,
+// so they can no longer be paid. It returns the number of payments expired.
+func (h *APIHandlers) ExpireUnpaidCheckouts(ctx context.Context) (int, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	if h.Payments == nil {
+		return 0, nil
+	}
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(dbCtx, "SELECT "+paymentColumns+` FROM payments
+		WHERE status = 'pending' AND (booking_id IS NULL OR booking_id IN (SELECT id FROM bookings WHERE status = 'expired'))
+		FOR UPDATE`)
+	if err != nil {
+		return 0, err
+	}
+	var expired []*Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	audit := systemAuditor("payments")
+	for _, p := range expired {
+		if _, err := tx.ExecContext(dbCtx, "UPDATE payments SET status = 'expired' WHERE id = $1", p.ID); err != nil {
+			return 0, err
+		}
+		after := *p
+		after.Status = PaymentExpired
+		if err := audit.record(dbCtx, tx, AuditPaymentExpired, "payment", strconv.Itoa(p.ID), p, after); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range expired {
+		if err := h.Payments.ExpireCheckout(ctx, p.CheckoutID); err != nil {
+			slog.WarnContext(ctx, "Failed to expire checkout", "checkout_id", p.CheckoutID, "error", err)
+		}
+	}
+	return len(expired), nil
+}
+
+// ListPayments lists payments for the admin, newest first. Filters: booking_id, status.
+func (h *APIHandlers) ListPayments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	q := r.URL.Query()
+	query := "SELECT " + paymentColumns + " FROM payments WHERE 1=1"
+	var args []interface{}
+	if value := q.Get("booking_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid booking_id", http.StatusBadRequest)
+			return
+		}
+		args = append(args, id)
+		query += fmt.Sprintf(" AND booking_id = $%d", len(args))
+	}
+	if value := q.Get("status"); value != "" {
+		args = append(args, value)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT 200"
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying payments", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning payment", "error", err)
+			return
+		}
+		payments = append(payments, *p)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying payments", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+}
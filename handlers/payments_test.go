@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePaymentProvider takes no real money. Its checkout URL is the success URL, and its
+// webhook accepts an unsigned JSON PaymentEvent ({"type": "paid", "checkout_id": "..."}).
+type fakePaymentProvider struct{}
+
+func (p *fakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakePaymentProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	return &Checkout{ID: fmt.Sprintf("fake_cs_%d", req.BookingID), URL: req.SuccessURL}, nil
+}
+
+func (p *fakePaymentProvider) ExpireCheckout(ctx context.Context, checkoutID string) error {
+	return nil
+}
+
+func (p *fakePaymentProvider) ParseWebhook(r *http.Request) (*PaymentEvent, error) {
+	var body struct {
+		Type       string `json:"type"`
+		CheckoutID string `json:"checkout_id"`
+		PaymentID  string `json:"payment_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.CheckoutID == "" {
+		return nil, ErrInvalidWebhook
+	}
+	return &PaymentEvent{Type: body.Type, CheckoutID: body.CheckoutID, PaymentID: body.PaymentID}, nil
+}
+
+func (p *fakePaymentProvider) Refund(ctx context.Context, paymentID string) (string, error) {
+	return "fake_re_" + paymentID, nil
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		payments PaymentProvider
+		method   string
+		body     string
+		want     int
+	}{
+		{"payments disabled", nil, http.MethodPost, `{"type":"paid","checkout_id":"cs_1"}`, http.StatusServiceUnavailable},
+		{"wrong method", &fakePaymentProvider{}, http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"malformed body", &fakePaymentProvider{}, http.MethodPost, `{"type":`, http.StatusBadRequest},
+		{"missing checkout", &fakePaymentProvider{}, http.MethodPost, `{"type":"paid"}`, http.StatusBadRequest},
+		{"ignored event type", &fakePaymentProvider{}, http.MethodPost, `{"type":"refunded","checkout_id":"cs_1"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &APIHandlers{Payments: tt.payments}
+			r := httptest.NewRequest(tt.method, "/api/payments/webhook", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.PaymentWebhook(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSlotTooSoon         = errors.New("slot is within the minimum notice period")
+	ErrSlotTooFar          = errors.New("slot is beyond the advance booking window")
+	ErrDailyLimitReached   = errors.New("no more sessions available on this day")
+	ErrWeeklyLimitReached  = errors.New("no more sessions available this week")
+	ErrCancellationTooLate = errors.New("the cancellation cut-off has passed")
+)
+
+// BookingPolicy holds the scheduling rules applied to public bookings.
+// A zero value for any field disables that rule.
+type BookingPolicy struct {
+	MinNotice          time.Duration // earliest a slot may start relative to now
+	MaxAdvance         time.Duration // latest a slot may start relative to now
+	MaxPerDay          int           // sessions the coach takes per calendar day
+	MaxPerWeek         int           // sessions the coach takes per Monday-based week
+	CancellationCutoff time.Duration // client cancellations recorded later than this before the slot need override
+	Location           *time.Location
+}
+
+func (p *BookingPolicy) location() *time.Location {
+	if p == nil || p.Location == nil {
+		return time.UTC
+	}
+	return p.Location
+}
+
+// CheckWindow verifies the slot respects the minimum notice and advance booking window.
+func (p *BookingPolicy) CheckWindow(slotTime, now time.Time) error {
+	if p == nil {
+		return nil
+	}
+	if p.MinNotice > 0 && slotTime.Before(now.Add(p.MinNotice)) {
+		return ErrSlotTooSoon
+	}
+	if p.MaxAdvance > 0 && slotTime.After(now.Add(p.MaxAdvance)) {
+		return ErrSlotTooFar
+	}
+	return nil
+}
+
+// CheckCaps verifies that adding one more session keeps the day and week under their caps.
+func (p *BookingPolicy) CheckCaps(dayCount, weekCount int) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxPerDay > 0 && dayCount >= p.MaxPerDay {
+		return ErrDailyLimitReached
+	}
+	if p.MaxPerWeek > 0 && weekCount >= p.MaxPerWeek {
+		return ErrWeeklyLimitReached
+	}
+	return nil
+}
+
+// CheckCancellation verifies a client cancellation is recorded before the cut-off. Clients
+// cannot cancel online; this guards the admin recording a late cancellation by mistake.
+func (p *BookingPolicy) CheckCancellation(slotTime, now time.Time) error {
+	if p == nil || p.CancellationCutoff <= 0 {
+		return nil
+	}
+	if slotTime.Before(now.Add(p.CancellationCutoff)) {
+		return ErrCancellationTooLate
+	}
+	return nil
+}
+
+// DayBounds returns the start and end of the calendar day containing t in the policy location.
+func (p *BookingPolicy) DayBounds(t time.Time) (time.Time, time.Time) {
+	local := t.In(p.location())
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// WeekBounds returns the start (Monday) and end of the week containing t in the policy location.
+func (p *BookingPolicy) WeekBounds(t time.Time) (time.Time, time.Time) {
+	dayStart, _ := p.DayBounds(t)
+	offset := (int(dayStart.Weekday()) + 6) % 7
+	start := dayStart.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// hasCaps reports whether any daily or weekly cap is configured.
+func (p *BookingPolicy) hasCaps() bool {
+	return p != nil && (p.MaxPerDay > 0 || p.MaxPerWeek > 0)
+}
+
+// slotCounter tallies booked sessions per day and week for cap checks.
+type slotCounter struct {
+	policy *BookingPolicy
+	days   map[int64]int
+	weeks  map[int64]int
+}
+
+func newSlotCounter(policy *BookingPolicy) *slotCounter {
+	return &slotCounter{
+		policy: policy,
+		days:   make(map[int64]int),
+		weeks:  make(map[int64]int),
+	}
+}
+
+func (c *slotCounter) add(t time.Time) {
+	day, _ := c.policy.DayBounds(t)
+	week, _ := c.policy.WeekBounds(t)
+	c.days[day.Unix()]++
+	c.weeks[week.Unix()]++
+}
+
+func (c *slotCounter) check(t time.Time) error {
+	day, _ := c.policy.DayBounds(t)
+	week, _ := c.policy.WeekBounds(t)
+	return c.policy.CheckCaps(c.days[day.Unix()], c.weeks[week.Unix()])
+}
+
+// checkBookingCaps counts existing bookings around slotTime and applies the daily and weekly caps.
+func (h *APIHandlers) checkBookingCaps(ctx context.Context, slotTime time.Time) error {
+	if !h.Policy.hasCaps() {
+		return nil
+	}
+
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	return h.Policy.countCaps(ctx, h.DB, slotTime)
+}
+
+// countCaps is checkBookingCaps reading through q, so a transaction holding the week's
+// booking lock counts bookings no other request can add to until it commits
+func (p *BookingPolicy) countCaps(ctx context.Context, q queryRower, slotTime time.Time) error {
+	dayStart, dayEnd := p.DayBounds(slotTime)
+	weekStart, weekEnd := p.WeekBounds(slotTime)
+
+	var dayCount, weekCount int
+	err := q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM bookings WHERE slot_time >= $1 AND slot_time < $2 AND "+occupyingBookingSQL,
+		dayStart.UTC(), dayEnd.UTC(),
+	).Scan(&dayCount)
+	if err != nil {
+		return err
+	}
+	err = q.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM bookings WHERE slot_time >= $1 AND slot_time < $2 AND "+occupyingBookingSQL,
+		weekStart.UTC(), weekEnd.UTC(),
+	).Scan(&weekCount)
+	if err != nil {
+		return err
+	}
+
+	return p.CheckCaps(dayCount, weekCount)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckWindow(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	policy := &BookingPolicy{MinNotice: 12 * time.Hour, MaxAdvance: 30 * 24 * time.Hour}
+
+	tests := []struct {
+		name   string
+		policy *BookingPolicy
+		slot   time.Time
+		want   error
+	}{
+		{"inside the window", policy, now.Add(24 * time.Hour), nil},
+		{"exactly the minimum notice", policy, now.Add(12 * time.Hour), nil},
+		{"short of the minimum notice", policy, now.Add(11*time.Hour + 30*time.Minute), ErrSlotTooSoon},
+		{"exactly the advance window", policy, now.Add(30 * 24 * time.Hour), nil},
+		{"beyond the advance window", policy, now.Add(30*24*time.Hour + 30*time.Minute), ErrSlotTooFar},
+		{"no rules", &BookingPolicy{}, now.Add(time.Minute), nil},
+		{"nil policy", nil, now.Add(365 * 24 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.CheckWindow(tt.slot, now); got != tt.want {
+				t.Errorf("CheckWindow(%s) = %v, want %v", tt.slot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCaps(t *testing.T) {
+	policy := &BookingPolicy{MaxPerDay: 3, MaxPerWeek: 10}
+
+	tests := []struct {
+		name      string
+		policy    *BookingPolicy
+		dayCount  int
+		weekCount int
+		want      error
+	}{
+		{"room left", policy, 2, 9, nil},
+		{"day full", policy, 3, 5, ErrDailyLimitReached},
+		{"week full", policy, 1, 10, ErrWeeklyLimitReached},
+		{"both full reports the day", policy, 3, 10, ErrDailyLimitReached},
+		{"no caps", &BookingPolicy{}, 100, 100, nil},
+		{"nil policy", nil, 100, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.CheckCaps(tt.dayCount, tt.weekCount); got != tt.want {
+				t.Errorf("CheckCaps(%d, %d) = %v, want %v", tt.dayCount, tt.weekCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCancellation(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	policy := &BookingPolicy{CancellationCutoff: 24 * time.Hour}
+
+	tests := []struct {
+		name   string
+		policy *BookingPolicy
+		slot   time.Time
+		want   error
+	}{
+		{"before the cut-off", policy, now.Add(48 * time.Hour), nil},
+		{"exactly the cut-off", policy, now.Add(24 * time.Hour), nil},
+		{"after the cut-off", policy, now.Add(23 * time.Hour), ErrCancellationTooLate},
+		{"no cut-off", &BookingPolicy{}, now.Add(time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.CheckCancellation(tt.slot, now); got != tt.want {
+				t.Errorf("CheckCancellation(%s) = %v, want %v", tt.slot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayBounds(t *testing.T) {
+	policy := &BookingPolicy{Location: testLocation(t)}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "winter day",
+			t:         time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			// 23:30 UTC is already the next day in Amsterdam
+			name:      "local day differs from UTC",
+			t:         time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go forward on 29 March: the day is 23 hours long
+			name:      "spring forward",
+			t:         time.Date(2026, 3, 29, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 29, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go back on 25 October: the day is 25 hours long
+			name:      "fall back",
+			t:         time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 25, 23, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := policy.DayBounds(tt.t)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("DayBounds(%s) = %s to %s, want %s to %s", tt.t, start.UTC(), end.UTC(), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestWeekBounds(t *testing.T) {
+	policy := &BookingPolicy{Location: testLocation(t)}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "monday",
+			t:         time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			// 22:30 UTC on Sunday is still Sunday evening in Amsterdam
+			name:      "sunday evening",
+			t:         time.Date(2026, 3, 8, 22, 30, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			// 23:30 UTC on Sunday is already Monday in Amsterdam
+			name:      "sunday night in UTC",
+			t:         time.Date(2026, 3, 8, 23, 30, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 15, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week ending with spring forward",
+			t:         time.Date(2026, 3, 29, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 3, 22, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 29, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week ending with fall back",
+			t:         time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2026, 10, 18, 22, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 10, 25, 23, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := policy.WeekBounds(tt.t)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("WeekBounds(%s) = %s to %s, want %s to %s", tt.t, start.UTC(), end.UTC(), tt.wantStart, tt.wantEnd)
+			}
+			if start.In(policy.Location).Weekday() != time.Monday {
+				t.Errorf("WeekBounds(%s) starts on %s", tt.t, start.In(policy.Location).Weekday())
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst tokens that refills completely every Per
+type RateLimit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Enabled reports whether the limit is configured
+func (l RateLimit) Enabled() bool {
+	return l.Burst > 0 && l.Per > 0
+}
+
+// refillRate returns the tokens added per second
+func (l RateLimit) refillRate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+func (l RateLimit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Burst, l.Per)
+}
+
+// ParseRateLimit parses limits written as "<burst>/<duration>", e.g. "10/1h".
+// An empty string or "off" disables the limit.
+func ParseRateLimit(value string) (RateLimit, error) {
+	if value == "" || strings.EqualFold(value, "off") {
+		return RateLimit{}, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("expected <burst>/<duration>, got %q", value)
+	}
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid burst %q", parts[0])
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid duration %q", parts[1])
+	}
+	return RateLimit{Burst: burst, Per: per}, nil
+}
+
+// RateLimitStore keeps token buckets. Take consumes one token from the bucket for key and,
+// when the bucket is empty, reports how long until the next token is available.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// rateLimitPruner is implemented by stores that need buckets which have refilled completely
+// removed periodically; dropping such a bucket does not change any limit
+type rateLimitPruner interface {
+	Prune(ctx context.Context) (int64, error)
+}
+
+// takeToken applies the token bucket algorithm to a bucket last updated at updatedAt
+func takeToken(tokens float64, updatedAt, now time.Time, limit RateLimit) (float64, bool, time.Duration) {
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.refillRate())
+	}
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	wait := time.Duration((1 - tokens) / limit.refillRate() * float64(time.Second))
+	return tokens, false, wait
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	per       time.Duration // refill period of the bucket's limit: idle this long, it is full
+}
+
+// MemoryRateLimitStore keeps buckets in process memory; suitable for single-instance deployments
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	tokens, allowed, retryAfter := takeToken(bucket.tokens, bucket.updatedAt, now, limit)
+	bucket.tokens = tokens
+	bucket.updatedAt = now
+	bucket.per = limit.Per
+
+	// Periodically drop buckets that have been idle long enough to refill completely.
+	// Buckets under different limits share the map, so each is judged by its own period.
+	s.takes++
+	if s.takes%1000 == 0 {
+		s.prune(now)
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// prune drops buckets idle for longer than their own refill period; s.mu must be held
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	for k, b := range s.buckets {
+		if now.Sub(b.updatedAt) > b.per {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+// PostgresRateLimitStore keeps buckets in the rate_limit_buckets table so that all
+// instances of a multi-instance deployment share the same limits
+type PostgresRateLimitStore struct {
+	DB      *sql.DB
+	Timeout time.Duration // bounds each Take and Prune; 0 uses DefaultDBTimeout
+}
+
+func (s *PostgresRateLimitStore) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDBTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO rate_limit_buckets (key, tokens, updated_at, full_at) VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Burst),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var tokens float64
+	var updatedAt, now time.Time
+	err = tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at, CURRENT_TIMESTAMP FROM rate_limit_buckets WHERE key = $1 FOR UPDATE",
+		key,
+	).Scan(&tokens, &updatedAt, &now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, allowed, retryAfter := takeToken(tokens, updatedAt, now, limit)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE rate_limit_buckets SET tokens = $1, updated_at = $2, full_at = $3 WHERE key = $4",
+		tokens, now, now.Add(limit.Per), key,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, nil
+}
+
+// Prune deletes buckets that have refilled completely; a later Take recreates them full
+func (s *PostgresRateLimitStore) Prune(ctx context.Context) (int64, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at < CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RateLimiter applies per-IP and per-email limits to public booking requests
+type RateLimiter struct {
+	Store    RateLimitStore
+	PerIP    RateLimit
+	PerEmail RateLimit
+}
+
+// allow consumes a token for key under limit. Store failures are logged and let the
+// request through so that a database hiccup does not take bookings down.
+func (l *RateLimiter) allow(ctx context.Context, key string, limit RateLimit) (bool, time.Duration) {
+	if l == nil || l.Store == nil || !limit.Enabled() {
+		return true, 0
+	}
+	allowed, retryAfter, err := l.Store.Take(ctx, key, limit)
+	if err != nil {
+		slog.WarnContext(ctx, "Rate limit store error", "key", key, "error", err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// AllowIP consumes a token from the bucket for the client IP
+func (l *RateLimiter) AllowIP(ctx context.Context, ip string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	return l.allow(ctx, "ip:"+ip, l.PerIP)
+}
+
+// AllowEmail consumes a token from the bucket for the (normalized) email address
+func (l *RateLimiter) AllowEmail(ctx context.Context, email string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	return l.allow(ctx, "email:"+strings.ToLower(strings.TrimSpace(email)), l.PerEmail)
+}
+
+// PruneRateLimitBuckets removes rate limit buckets that have refilled completely from
+// stores that do not clean up after themselves
+func (h *APIHandlers) PruneRateLimitBuckets(ctx context.Context) (int64, error) {
+	if h.RateLimiter == nil {
+		return 0, nil
+	}
+	pruner, ok := h.RateLimiter.Store.(rateLimitPruner)
+	if !ok {
+		return 0, nil
+	}
+	return pruner.Prune(ctx)
+}
+
+// tooManyRequests writes a 429 response with a Retry-After header in whole seconds
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+}
+
+// clientIP returns the address of the client resolved by WithClientIP, or the peer address
+// when the request did not pass through it
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{"", RateLimit{}, false},
+		{"off", RateLimit{}, false},
+		{"OFF", RateLimit{}, false},
+		{"10/1h", RateLimit{Burst: 10, Per: time.Hour}, false},
+		{"3/90s", RateLimit{Burst: 3, Per: 90 * time.Second}, false},
+		{"10", RateLimit{}, true},
+		{"0/1h", RateLimit{}, true},
+		{"-1/1h", RateLimit{}, true},
+		{"x/1h", RateLimit{}, true},
+		{"10/0s", RateLimit{}, true},
+		{"10/hour", RateLimit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRateLimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRateLimit(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTakeToken(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	limit := RateLimit{Burst: 10, Per: 10 * time.Minute} // one token a minute
+
+	tests := []struct {
+		name        string
+		tokens      float64
+		idle        time.Duration
+		wantTokens  float64
+		wantAllowed bool
+		wantRetry   time.Duration
+	}{
+		{"full bucket", 10, 0, 9, true, 0},
+		{"last token", 1, 0, 0, true, 0},
+		{"empty bucket", 0, 0, 0, false, time.Minute},
+		{"half a token", 0.5, 0, 0.5, false, 30 * time.Second},
+		{"refilled by idle time", 0, 2 * time.Minute, 1, true, 0},
+		{"refill capped at burst", 5, time.Hour, 9, true, 0},
+		{"clock went backwards", 0, -time.Minute, 0, false, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, allowed, retry := takeToken(tt.tokens, now.Add(-tt.idle), now, limit)
+			if math.Abs(tokens-tt.wantTokens) > 1e-9 {
+				t.Errorf("tokens = %v, want %v", tokens, tt.wantTokens)
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if retry != tt.wantRetry {
+				t.Errorf("retryAfter = %v, want %v", retry, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 2, Per: time.Hour}
+	ctx := context.Background()
+
+	for i, want := range []bool{true, true, false} {
+		allowed, retry, err := store.Take(ctx, "ip:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != want {
+			t.Fatalf("take %d: allowed = %v, want %v", i+1, allowed, want)
+		}
+		if !allowed && retry <= 0 {
+			t.Errorf("take %d: retryAfter = %v, want a positive wait", i+1, retry)
+		}
+	}
+	if allowed, _, _ := store.Take(ctx, "ip:2", limit); !allowed {
+		t.Error("a different key shares the exhausted bucket")
+	}
+}
+
+func TestMemoryRateLimitStorePrune(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.buckets["short:idle"] = &memoryBucket{updatedAt: now.Add(-2 * time.Minute), per: time.Minute}
+	store.buckets["short:recent"] = &memoryBucket{updatedAt: now.Add(-30 * time.Second), per: time.Minute}
+	store.buckets["long:idle"] = &memoryBucket{updatedAt: now.Add(-2 * time.Minute), per: time.Hour}
+
+	store.prune(now)
+
+	for key, want := range map[string]bool{"short:idle": false, "short:recent": true, "long:idle": true} {
+		if _, ok := store.buckets[key]; ok != want {
+			t.Errorf("bucket %q kept = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", proxies, "203.0.113.5:51000", "", "203.0.113.5"},
+		{"spoofed header from a direct client", proxies, "203.0.113.5:51000", "198.51.100.1", "203.0.113.5"},
+		{"spoofed header with no proxies trusted", nil, "10.0.0.2:51000", "198.51.100.1", "10.0.0.2"},
+		{"through a trusted proxy", proxies, "10.0.0.2:51000", "203.0.113.5", "203.0.113.5"},
+		{"client prepends a fake entry", proxies, "10.0.0.2:51000", "198.51.100.1, 203.0.113.5", "203.0.113.5"},
+		{"chain of trusted proxies", proxies, "10.0.0.2:51000", "203.0.113.5, 192.168.1.7, 10.1.2.3", "203.0.113.5"},
+		{"malformed entry stops the walk", proxies, "10.0.0.2:51000", "203.0.113.5, not-an-ip", "10.0.0.2"},
+		{"trusted proxy without header", proxies, "10.0.0.2:51000", "", "10.0.0.2"},
+		{"IPv6 peer", proxies, "[2001:db8::1]:51000", "198.51.100.1", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/bookings", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			var got string
+			WithClientIP(tt.proxies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutMiddleware(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/bookings", nil)
+	r.RemoteAddr = "203.0.113.5:51000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := clientIP(r); got != "203.0.113.5" {
+		t.Errorf("clientIP() = %q, want the peer address", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantCount int
+		wantErr   bool
+	}{
+		{"", 0, false},
+		{"10.0.0.0/8", 1, false},
+		{"10.0.0.0/8, 192.168.1.7, 2001:db8::/32", 3, false},
+		{"10.0.0.0/33", 0, true},
+		{"proxy.internal", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTrustedProxies(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTrustedProxies(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want %d entries", tt.value, got, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditBookingRescheduled records a booking moving to a new slot
+const AuditBookingRescheduled = "booking.rescheduled"
+
+var (
+	ErrNotReschedulable = errors.New("booking cannot be rescheduled in its current status")
+	ErrSlotUnavailable  = errors.New("slot is not available")
+)
+
+// Reschedule is an entry of a booking's reschedule history
+type Reschedule struct {
+	ID            int       `json:"id"`
+	BookingID     int       `json:"booking_id"`
+	OldSlotTime   time.Time `json:"old_slot_time"`
+	NewSlotTime   time.Time `json:"new_slot_time"`
+	Reason        string    `json:"reason,omitempty"`
+	RescheduledBy string    `json:"rescheduled_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// rescheduleBooking moves an active booking to a new slot in one transaction, bumping its
+// calendar sequence and recording the old time. Unless override is set, the new slot must not
+// be blocked or overlap another session or its buffer. It returns the booking as it was
+// before the move and the new calendar sequence.
+func (h *APIHandlers) rescheduleBooking(ctx context.Context, audit auditor, id int, slotTime time.Time, reason string, override bool) (*Booking, int, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	sessionTypes, err := h.loadSessionTypes(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadBooking(ctx, tx, id, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	if before.Status != StatusConfirmed && before.Status != StatusPending {
+		return before, 0, ErrNotReschedulable
+	}
+
+	if !override {
+		var blocked int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM blocked_slots WHERE slot_time = $1", slotTime).Scan(&blocked); err != nil {
+			return before, 0, err
+		}
+		if blocked > 0 {
+			return before, 0, ErrSlotUnavailable
+		}
+
+		guard := &slotGuard{slotTime: slotTime, slotType: sessionTypes[before.SessionType], types: sessionTypes, ignoreID: id}
+		if err := guard.check(ctx, tx, h.Policy); err != nil {
+			if errors.Is(err, ErrSlotBooked) || errors.Is(err, ErrSlotInBuffer) {
+				return before, 0, ErrSlotUnavailable
+			}
+			return before, 0, err
+		}
+	}
+
+	var sequence int
+	err = tx.QueryRowContext(ctx,
+		"UPDATE bookings SET slot_time = $1, calendar_sequence = calendar_sequence + 1 WHERE id = $2 RETURNING calendar_sequence",
+		slotTime, id,
+	).Scan(&sequence)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return before, 0, ErrSlotUnavailable
+		}
+		return before, 0, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO booking_reschedules (booking_id, old_slot_time, new_slot_time, reason, rescheduled_by)
+		VALUES ($1, $2, $3, $4, $5)`,
+		id, before.SlotTime, slotTime, nullString(reason), audit.actor.String(),
+	)
+	if err != nil {
+		return before, 0, err
+	}
+
+	after, err := loadBooking(ctx, tx, id, false)
+	if err != nil {
+		return before, 0, err
+	}
+	if err := audit.record(ctx, tx, AuditBookingRescheduled, "booking", strconv.Itoa(id), before, after); err != nil {
+		return before, 0, err
+	}
+
+	return before, sequence, tx.Commit()
+}
+
+// RescheduleBooking serves /api/admin/bookings/reschedule. POST moves a booking (id) to a new
+// slot_time, updates its Zoom meeting and emails the client an updated invite; override skips
+// the blocked and buffer checks. GET returns the reschedule history of a booking (?id=).
+func (h *APIHandlers) RescheduleBooking(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		h.listReschedules(w, r)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID       int    `json:"id"`
+		SlotTime string `json:"slot_time"`
+		Reason   string `json:"reason"`
+		Override bool   `json:"override"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ID == 0 || req.SlotTime == "" {
+		http.Error(w, "id and slot_time are required", http.StatusBadRequest)
+		return
+	}
+
+	slotTime, err := time.Parse(time.RFC3339, req.SlotTime)
+	if err != nil {
+		http.Error(w, "Invalid slot_time format", http.StatusBadRequest)
+		return
+	}
+	slotTimeUTC := slotTime.UTC()
+
+	before, sequence, err := h.rescheduleBooking(ctx, auditorFromRequest(r), req.ID, slotTimeUTC, req.Reason, req.Override)
+	switch {
+	case errors.Is(err, ErrBookingNotFound):
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotReschedulable):
+		http.Error(w, "Cannot reschedule a booking with status "+before.Status, http.StatusConflict)
+		return
+	case errors.Is(err, ErrSlotUnavailable):
+		http.Error(w, "Slot is not available; set override to move the booking anyway", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to reschedule booking", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error rescheduling booking", "booking_id", req.ID, "error", err)
+		return
+	}
+
+	// The move is committed: update the meeting and notify the client even if the admin has disconnected
+	ctx = context.WithoutCancel(ctx)
+	zoomUpdated := false
+	if before.ZoomLink != "" && h.ZoomService != nil {
+		if err := h.ZoomService.UpdateMeeting(ctx, before.ZoomLink, slotTimeUTC); err != nil {
+			slog.WarnContext(r.Context(), "Booking rescheduled but failed to move Zoom meeting", "booking_id", req.ID, "error", err)
+		} else {
+			zoomUpdated = true
+		}
+	}
+
+	emailSent := false
+	if h.clientEmailsEnabled(ctx) {
+		loc := h.Policy.location()
+		err := h.EmailService.SendBookingRescheduled(ctx, before.Name, before.Email, before.SlotTime.In(loc), slotTimeUTC.In(loc),
+			before.Duration(), before.ZoomLink, CalendarUID(req.ID), sequence)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Booking rescheduled but failed to send update email", "booking_id", req.ID, "error", err)
+		} else {
+			emailSent = true
+		}
+	}
+
+	slog.InfoContext(r.Context(), "Booking rescheduled", "booking_id", req.ID, "from", before.SlotTime.UTC().Format(time.RFC3339), "to", slotTimeUTC.Format(time.RFC3339))
+	h.notifyWaitlist(r, before.SlotTime)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Booking rescheduled",
+		"id":            req.ID,
+		"old_slot_time": before.SlotTime.UTC(),
+		"slot_time":     slotTimeUTC,
+		"zoom_updated":  zoomUpdated,
+		"email_sent":    emailSent,
+	})
+}
+
+const rescheduleColumns = "id, booking_id, old_slot_time, new_slot_time, COALESCE(reason, ''), rescheduled_by, created_at"
+
+func scanReschedule(row rowScanner) (*Reschedule, error) {
+	var rs Reschedule
+	if err := row.Scan(&rs.ID, &rs.BookingID, &rs.OldSlotTime, &rs.NewSlotTime, &rs.Reason, &rs.RescheduledBy, &rs.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+func (h *APIHandlers) listReschedules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx, "SELECT "+rescheduleColumns+" FROM booking_reschedules WHERE booking_id = $1 ORDER BY id DESC", id)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying reschedules", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	history := []Reschedule{}
+	for rows.Next() {
+		rs, err := scanReschedule(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning reschedule", "error", err)
+			return
+		}
+		history = append(history, *rs)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying reschedules", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
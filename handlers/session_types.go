@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSlotBooked   = errors.New("slot already booked")
+	ErrSlotInBuffer = errors.New("slot is too close to another session")
+)
+
+// DefaultSessionType is the session type used when a request does not specify one
+const DefaultSessionType = "consultation"
+
+// SessionType describes a kind of session clients can book and the buffers kept around it.
+// Sessions with a positive price are paid through the payment provider when booked.
+type SessionType struct {
+	Key                 string `json:"key"`
+	Name                string `json:"name"`
+	DurationMinutes     int    `json:"duration_minutes"`
+	BufferBeforeMinutes int    `json:"buffer_before_minutes"`
+	BufferAfterMinutes  int    `json:"buffer_after_minutes"`
+	PriceCents          int    `json:"price_cents"`
+	Currency            string `json:"currency"`
+}
+
+// DefaultCurrency is the currency of session types that do not set one
+const DefaultCurrency = "EUR"
+
+// Paid reports whether booking the session requires payment
+func (s SessionType) Paid() bool {
+	return s.PriceCents > 0
+}
+
+func (s SessionType) Duration() time.Duration {
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+func (s SessionType) BufferBefore() time.Duration {
+	return time.Duration(s.BufferBeforeMinutes) * time.Minute
+}
+
+func (s SessionType) BufferAfter() time.Duration {
+	return time.Duration(s.BufferAfterMinutes) * time.Minute
+}
+
+// Slot occupancy relative to existing sessions
+const (
+	slotFree = iota
+	slotOverlapsSession
+	slotInBuffer
+)
+
+// occupiedSession is an existing booking as seen by the availability calculation
+type occupiedSession struct {
+	BookingID int
+	Start     time.Time
+	Duration  time.Duration
+	Type      SessionType
+}
+
+func (s occupiedSession) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// occupancy reports whether a session of the given type starting at start overlaps s itself
+// or only the buffer around it. The gap kept between two sessions is the larger of the
+// earlier session's after-buffer and the later session's before-buffer.
+func (s occupiedSession) occupancy(start time.Time, typ SessionType) int {
+	end := start.Add(typ.Duration())
+	if start.Before(s.End()) && s.Start.Before(end) {
+		return slotOverlapsSession
+	}
+
+	gapAfter := maxDuration(s.Type.BufferAfter(), typ.BufferBefore())
+	gapBefore := maxDuration(typ.BufferAfter(), s.Type.BufferBefore())
+	if start.Before(s.End().Add(gapAfter)) && s.Start.Add(-gapBefore).Before(end) {
+		return slotInBuffer
+	}
+	return slotFree
+}
+
+// slotOccupancy returns the strongest occupancy of a slot across all sessions
+func slotOccupancy(start time.Time, typ SessionType, sessions []occupiedSession) int {
+	result := slotFree
+	for _, s := range sessions {
+		switch s.occupancy(start, typ) {
+		case slotOverlapsSession:
+			return slotOverlapsSession
+		case slotInBuffer:
+			result = slotInBuffer
+		}
+	}
+	return result
+}
+
+// bookingLockKey is the first key of the advisory locks taken by lockBookingWeeks
+const bookingLockKey = 0x636f6163
+
+// lockBookingWeeks serializes the transactions booking around slotTime. It takes a
+// transaction-scoped advisory lock on each week a session or buffer touching slotTime can
+// start in, in ascending order, so checks made after it see every booking committed before.
+func lockBookingWeeks(ctx context.Context, tx execer, policy *BookingPolicy, slotTime time.Time) error {
+	first, _ := policy.WeekBounds(slotTime.AddDate(0, 0, -1))
+	last, _ := policy.WeekBounds(slotTime.AddDate(0, 0, 1))
+	for week := first; !week.After(last); week = week.AddDate(0, 0, 7) {
+		key := week.Year()*10000 + int(week.Month())*100 + week.Day()
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, $2)", bookingLockKey, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slotGuard repeats the overlap check, and the daily and weekly caps when caps is set, inside
+// the transaction that books a slot, so two racing requests cannot both pass the checks made
+// before it. ignoreID is the booking being moved, which does not conflict with itself.
+type slotGuard struct {
+	slotTime time.Time
+	slotType SessionType
+	types    map[string]SessionType
+	caps     bool
+	ignoreID int
+}
+
+// check locks the weeks around the slot and returns ErrSlotBooked or ErrSlotInBuffer if a
+// session overlaps it or its buffer, or the cap error if the day or week is full. A nil
+// guard checks nothing.
+func (g *slotGuard) check(ctx context.Context, tx *sql.Tx, policy *BookingPolicy) error {
+	if g == nil {
+		return nil
+	}
+	if err := lockBookingWeeks(ctx, tx, policy, g.slotTime); err != nil {
+		return err
+	}
+	if g.caps && policy.hasCaps() {
+		if err := policy.countCaps(ctx, tx, g.slotTime); err != nil {
+			return err
+		}
+	}
+
+	sessions, err := querySessions(ctx, tx, g.types, g.slotTime.AddDate(0, 0, -1), g.slotTime.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	others := sessions[:0]
+	for _, s := range sessions {
+		if s.BookingID != g.ignoreID {
+			others = append(others, s)
+		}
+	}
+	switch slotOccupancy(g.slotTime, g.slotType, others) {
+	case slotOverlapsSession:
+		return ErrSlotBooked
+	case slotInBuffer:
+		return ErrSlotInBuffer
+	}
+	return nil
+}
+
+// slotTakenError writes the response for a slot taken by another booking, reporting whether
+// err was one
+func slotTakenError(w http.ResponseWriter, err error) bool {
+	switch {
+	case isUniqueViolation(err), errors.Is(err, ErrSlotBooked):
+		slotConflict(w, conflictBooked, "Slot already booked")
+	case errors.Is(err, ErrSlotInBuffer):
+		slotConflict(w, conflictBuffer, "Slot is too close to another session")
+	case errors.Is(err, ErrDailyLimitReached), errors.Is(err, ErrWeeklyLimitReached):
+		slotConflict(w, conflictLimit, "Slot is not open for booking: "+err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+const sessionTypeColumns = "key, name, duration_minutes, buffer_before_minutes, buffer_after_minutes, price_cents, currency"
+
+func scanSessionType(row rowScanner, t *SessionType) error {
+	return row.Scan(&t.Key, &t.Name, &t.DurationMinutes, &t.BufferBeforeMinutes, &t.BufferAfterMinutes, &t.PriceCents, &t.Currency)
+}
+
+// loadSessionTypes returns all configured session types keyed by their key
+func (h *APIHandlers) loadSessionTypes(ctx context.Context) (map[string]SessionType, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	rows, err := h.DB.QueryContext(ctx, "SELECT "+sessionTypeColumns+" FROM session_types")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]SessionType)
+	for rows.Next() {
+		var t SessionType
+		if err := scanSessionType(rows, &t); err != nil {
+			return nil, err
+		}
+		types[t.Key] = t
+	}
+	return types, rows.Err()
+}
+
+// loadSessions returns booked sessions starting in [from, to) with their session type buffers
+func (h *APIHandlers) loadSessions(ctx context.Context, types map[string]SessionType, from, to time.Time) ([]occupiedSession, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	return querySessions(ctx, h.DB, types, from, to)
+}
+
+// querySessions is loadSessions reading through q, so a transaction sees its own view
+func querySessions(ctx context.Context, q querier, types map[string]SessionType, from, to time.Time) ([]occupiedSession, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT id, slot_time, duration, session_type FROM bookings WHERE slot_time >= $1 AND slot_time < $2 AND "+occupyingBookingSQL,
+		from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []occupiedSession
+	for rows.Next() {
+		var id, duration int
+		var slotTime time.Time
+		var typeKey string
+		if err := rows.Scan(&id, &slotTime, &duration, &typeKey); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, occupiedSession{
+			BookingID: id,
+			Start:     slotTime,
+			Duration:  time.Duration(duration) * time.Minute,
+			Type:      types[typeKey],
+		})
+	}
+	return sessions, rows.Err()
+}
+
+// SessionTypes lists session types (GET) or creates/updates one (POST)
+func (h *APIHandlers) SessionTypes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		types, err := h.loadSessionTypes(r.Context())
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error querying session types", "error", err)
+			return
+		}
+
+		list := make([]SessionType, 0, len(types))
+		for _, t := range types {
+			list = append(list, t)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var req SessionType
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.Key == "" || req.Name == "" {
+			http.Error(w, "key and name are required", http.StatusBadRequest)
+			return
+		}
+		if req.DurationMinutes <= 0 || req.BufferBeforeMinutes < 0 || req.BufferAfterMinutes < 0 {
+			http.Error(w, "duration_minutes must be positive and buffers must not be negative", http.StatusBadRequest)
+			return
+		}
+		if req.PriceCents < 0 {
+			http.Error(w, "price_cents must not be negative", http.StatusBadRequest)
+			return
+		}
+		req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+		if req.Currency == "" {
+			req.Currency = DefaultCurrency
+		}
+		if len(req.Currency) != 3 {
+			http.Error(w, "currency must be a three-letter ISO code", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.saveSessionType(r.Context(), auditorFromRequest(r), req); err != nil {
+			http.Error(w, "Failed to save session type", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error saving session type", "error", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveSessionType upserts a session type and records the change in the audit log
+func (h *APIHandlers) saveSessionType(ctx context.Context, audit auditor, t SessionType) error {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before *SessionType
+	var existing SessionType
+	err = scanSessionType(tx.QueryRowContext(ctx, "SELECT "+sessionTypeColumns+" FROM session_types WHERE key = $1 FOR UPDATE", t.Key), &existing)
+	if err == nil {
+		before = &existing
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO session_types (key, name, duration_minutes, buffer_before_minutes, buffer_after_minutes, price_cents, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key) DO UPDATE SET
+			name = EXCLUDED.name,
+			duration_minutes = EXCLUDED.duration_minutes,
+			buffer_before_minutes = EXCLUDED.buffer_before_minutes,
+			buffer_after_minutes = EXCLUDED.buffer_after_minutes,
+			price_cents = EXCLUDED.price_cents,
+			currency = EXCLUDED.currency`,
+		t.Key, t.Name, t.DurationMinutes, t.BufferBeforeMinutes, t.BufferAfterMinutes, t.PriceCents, t.Currency,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := audit.record(ctx, tx, AuditSessionTypeSaved, "session_type", t.Key, before, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sessionWindow returns the range of bookings relevant to the given slots, padded by a
+// week on either side so buffers and weekly caps at the edges are accounted for
+func sessionWindow(slots []AvailableSlot) (time.Time, time.Time) {
+	now := time.Now()
+	from, to := now, now
+	for _, slot := range slots {
+		slotTime, err := time.Parse(time.RFC3339, slot.SlotTime)
+		if err != nil {
+			continue
+		}
+		if slotTime.Before(from) {
+			from = slotTime
+		}
+		if slotTime.After(to) {
+			to = slotTime
+		}
+	}
+	return from.AddDate(0, 0, -7), to.AddDate(0, 0, 7)
+}
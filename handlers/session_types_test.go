@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionOccupancy(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 2, hour, min, 0, 0, time.UTC) }
+	short := SessionType{Key: "consultation", DurationMinutes: 30}
+	long := SessionType{Key: "coaching", DurationMinutes: 60}
+	buffered := SessionType{Key: "intensive", DurationMinutes: 60, BufferBeforeMinutes: 15, BufferAfterMinutes: 30}
+
+	tests := []struct {
+		name     string
+		existing occupiedSession
+		start    time.Time
+		typ      SessionType
+		want     int
+	}{
+		{"same start", occupiedSession{Start: at(10, 0), Duration: 30 * time.Minute, Type: short}, at(10, 0), short, slotOverlapsSession},
+		{"back to back after", occupiedSession{Start: at(10, 0), Duration: 30 * time.Minute, Type: short}, at(10, 30), short, slotFree},
+		{"back to back before", occupiedSession{Start: at(10, 0), Duration: 30 * time.Minute, Type: short}, at(9, 30), short, slotFree},
+		{"short inside a long session", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: long}, at(10, 30), short, slotOverlapsSession},
+		{"long session runs into the next", occupiedSession{Start: at(10, 30), Duration: 30 * time.Minute, Type: short}, at(10, 0), long, slotOverlapsSession},
+		{"inside the after-buffer", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(11, 0), short, slotInBuffer},
+		{"after the after-buffer", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(11, 30), short, slotFree},
+		{"ends inside the before-buffer", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(9, 30), short, slotInBuffer},
+		{"ends before the before-buffer", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(9, 0), short, slotFree},
+		// The new session's own buffers count as well: its 15-minute before-buffer reaches back into the 30-minute session
+		{"new session's before-buffer", occupiedSession{Start: at(10, 0), Duration: 30 * time.Minute, Type: short}, at(10, 30), buffered, slotInBuffer},
+		{"new session's after-buffer", occupiedSession{Start: at(10, 30), Duration: 30 * time.Minute, Type: short}, at(9, 30), buffered, slotInBuffer},
+		{"larger buffer wins", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(11, 15), buffered, slotInBuffer},
+		{"clear of both buffers", occupiedSession{Start: at(10, 0), Duration: time.Hour, Type: buffered}, at(11, 30), buffered, slotFree},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.existing.occupancy(tt.start, tt.typ); got != tt.want {
+				t.Errorf("occupancy(%s, %s) = %d, want %d", tt.start.Format("15:04"), tt.typ.Key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotOccupancy(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 2, hour, min, 0, 0, time.UTC) }
+	short := SessionType{Key: "consultation", DurationMinutes: 30}
+	buffered := SessionType{Key: "intensive", DurationMinutes: 60, BufferAfterMinutes: 30}
+	sessions := []occupiedSession{
+		{BookingID: 1, Start: at(9, 0), Duration: time.Hour, Type: buffered},
+		{BookingID: 2, Start: at(14, 0), Duration: 30 * time.Minute, Type: short},
+	}
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		sessions []occupiedSession
+		want     int
+	}{
+		{"no sessions", at(10, 0), nil, slotFree},
+		{"free between sessions", at(12, 0), sessions, slotFree},
+		{"buffer of the first", at(10, 0), sessions, slotInBuffer},
+		{"overlaps the second", at(14, 0), sessions, slotOverlapsSession},
+		{"overlap beats buffer", at(10, 0), append([]occupiedSession{{BookingID: 3, Start: at(10, 0), Duration: 30 * time.Minute, Type: short}}, sessions...), slotOverlapsSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slotOccupancy(tt.start, short, tt.sessions); got != tt.want {
+				t.Errorf("slotOccupancy(%s) = %d, want %d", tt.start.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
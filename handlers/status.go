@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Booking statuses
+const (
+	StatusPending           = "pending"
+	StatusConfirmed         = "confirmed"
+	StatusCancelledByClient = "cancelled_by_client"
+	StatusCancelledByCoach  = "cancelled_by_coach"
+	StatusCompleted         = "completed"
+	StatusNoShow            = "no_show"
+	StatusExpired           = "expired" // a pending hold that lapsed or was released unconfirmed
+)
+
+// occupyingBookingSQL matches bookings that currently hold their slot: everything except
+// cancelled and expired bookings and pending holds whose window has passed but which the
+// maintenance job has not expired yet
+const occupyingBookingSQL = "(status IN ('confirmed', 'completed', 'no_show') OR (status = 'pending' AND hold_expires_at > CURRENT_TIMESTAMP))"
+
+var (
+	ErrBookingNotFound   = errors.New("booking not found")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// statusTransitions lists the statuses each status may move to
+var statusTransitions = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusCancelledByClient, StatusCancelledByCoach},
+	StatusConfirmed: {StatusCancelledByClient, StatusCancelledByCoach, StatusCompleted, StatusNoShow},
+	StatusCompleted: {StatusNoShow},
+	StatusNoShow:    {StatusCompleted},
+}
+
+func canTransition(from, to string) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func isCancelledStatus(status string) bool {
+	return status == StatusCancelledByClient || status == StatusCancelledByCoach
+}
+
+// transitionBooking moves a booking to a new status, recording when, why and by whom.
+// Cancelling a booking also deletes its Zoom meeting, and a coach-side cancellation refunds
+// its payment. A client cancellation must respect the policy's cancellation cut-off unless
+// override is set. It returns the previous status.
+func (h *APIHandlers) transitionBooking(ctx context.Context, audit auditor, id int, to, reason string, override bool) (string, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := loadBooking(dbCtx, tx, id, true)
+	if err != nil {
+		return "", err
+	}
+
+	if !canTransition(before.Status, to) {
+		return before.Status, ErrInvalidTransition
+	}
+	if to == StatusCancelledByClient && !override {
+		if err := h.Policy.CheckCancellation(before.SlotTime, time.Now()); err != nil {
+			return before.Status, err
+		}
+	}
+
+	_, err = tx.ExecContext(dbCtx, `
+		UPDATE bookings SET
+			status = $1,
+			status_reason = $2,
+			status_updated_at = CURRENT_TIMESTAMP,
+			confirmed_at = CASE WHEN $1 = 'confirmed' THEN CURRENT_TIMESTAMP ELSE confirmed_at END,
+			cancelled_at = CASE WHEN $1 IN ('cancelled_by_client', 'cancelled_by_coach') THEN CURRENT_TIMESTAMP ELSE cancelled_at END,
+			hold_expires_at = NULL
+		WHERE id = $3`,
+		to, nullString(reason), id,
+	)
+	if err != nil {
+		return before.Status, err
+	}
+
+	after, err := loadBooking(dbCtx, tx, id, false)
+	if err != nil {
+		return before.Status, err
+	}
+	if err := audit.record(dbCtx, tx, AuditBookingStatusChanged, "booking", strconv.Itoa(id), before, after); err != nil {
+		return before.Status, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return before.Status, err
+	}
+
+	// The change is committed: finish its side effects even if the client has disconnected
+	ctx = context.WithoutCancel(ctx)
+	if isCancelledStatus(to) {
+		metrics.bookingsCancelled.inc(to)
+		h.deleteZoomMeeting(ctx, before.ZoomLink)
+	}
+	if to == StatusCancelledByCoach {
+		h.settleCoachCancellation(ctx, audit, id)
+	}
+
+	return before.Status, nil
+}
+
+// UpdateBookingStatus moves a booking through its lifecycle, e.g. marking it completed or no-show.
+// Recording a client cancellation inside BOOKING_CANCELLATION_CUTOFF needs override.
+func (h *APIHandlers) UpdateBookingStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID       int    `json:"id"`
+		Status   string `json:"status"`
+		Reason   string `json:"reason"`
+		Override bool   `json:"override"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ID == 0 || req.Status == "" {
+		http.Error(w, "id and status are required", http.StatusBadRequest)
+		return
+	}
+
+	from, err := h.transitionBooking(r.Context(), auditorFromRequest(r), req.ID, req.Status, req.Reason, req.Override)
+	switch {
+	case errors.Is(err, ErrBookingNotFound):
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrInvalidTransition):
+		http.Error(w, "Cannot change booking status from "+from+" to "+req.Status, http.StatusConflict)
+		return
+	case errors.Is(err, ErrCancellationTooLate):
+		http.Error(w, "The cancellation cut-off for this booking has passed; set override to record it anyway", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update booking status", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error updating booking status", "booking_id", req.ID, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Booking status changed", "booking_id", req.ID, "from", from, "to", req.Status)
+
+	if isCancelledStatus(req.Status) {
+		ctx, cancel := h.dbContext(r.Context())
+		booking, err := loadBooking(ctx, h.DB, req.ID, false)
+		cancel()
+		if err == nil {
+			h.notifyWaitlist(r, booking.SlotTime)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Booking status updated",
+		"id":              req.ID,
+		"previous_status": from,
+		"status":          req.Status,
+	})
+}
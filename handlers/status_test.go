@@ -0,0 +1,43 @@
+package handlers
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusPending, StatusConfirmed, true},
+		{StatusPending, StatusCancelledByClient, true},
+		{StatusPending, StatusCancelledByCoach, true},
+		{StatusPending, StatusCompleted, false},
+		{StatusPending, StatusExpired, false},
+		{StatusConfirmed, StatusCompleted, true},
+		{StatusConfirmed, StatusNoShow, true},
+		{StatusConfirmed, StatusCancelledByClient, true},
+		{StatusConfirmed, StatusPending, false},
+		{StatusConfirmed, StatusConfirmed, false},
+		{StatusCompleted, StatusNoShow, true},
+		{StatusNoShow, StatusCompleted, true},
+		{StatusCompleted, StatusConfirmed, false},
+		{StatusCancelledByClient, StatusConfirmed, false},
+		{StatusCancelledByCoach, StatusCancelledByClient, false},
+		{StatusExpired, StatusConfirmed, false},
+		{"unknown", StatusConfirmed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := canTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("canTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminalStatusesHaveNoTransitions(t *testing.T) {
+	for _, status := range []string{StatusCancelledByClient, StatusCancelledByCoach, StatusExpired} {
+		if to := statusTransitions[status]; len(to) > 0 {
+			t.Errorf("%s can move to %v, want no transitions", status, to)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailVerification enables double opt-in: public bookings are held as pending until the
+// client confirms them through the link emailed to them
+type EmailVerification struct {
+	Hold    time.Duration // how long an unconfirmed booking keeps its slot
+	BaseURL string        // public URL used in confirmation links; required
+}
+
+// newToken returns a random URL-safe token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// confirmationURL builds the link emailed to the client to confirm a pending booking
+func (v *EmailVerification) confirmationURL(token string) string {
+	return bookingConfirmationURL(v.BaseURL, token)
+}
+
+// bookingConfirmationURL builds a link to the confirmation page of the booking held by token
+func bookingConfirmationURL(baseURL, token string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/api/bookings/confirm?token=" + url.QueryEscape(token)
+}
+
+// heldBooking is a booking looked up by its confirmation token
+type heldBooking struct {
+	id              int
+	name, email     string
+	status          string
+	slotTime        time.Time
+	durationMinutes int
+	paid            bool // its session type has a price
+	holdExpiresAt   sql.NullTime
+}
+
+// bookingByToken returns the booking holding token, or nil if there is none
+func (h *APIHandlers) bookingByToken(ctx context.Context, token string) (*heldBooking, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	var b heldBooking
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT b.id, b.name, b.email, b.slot_time, b.duration, b.status, b.hold_expires_at, COALESCE(t.price_cents, 0) > 0
+		FROM bookings b LEFT JOIN session_types t ON t.key = b.session_type
+		WHERE b.confirmation_token = $1`,
+		token,
+	).Scan(&b.id, &b.name, &b.email, &b.slotTime, &b.durationMinutes, &b.status, &b.holdExpiresAt, &b.paid)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// closedStatus returns the home page status shown when b can no longer be confirmed, or ""
+// while it can
+func (b *heldBooking) closedStatus(now time.Time) string {
+	switch {
+	case b.status == StatusExpired:
+		return "expired"
+	case b.status != StatusPending:
+		return "confirmed"
+	case b.holdExpiresAt.Valid && b.holdExpiresAt.Time.Before(now):
+		return "expired"
+	}
+	return ""
+}
+
+// ConfirmBooking is the target of the emailed confirmation and waitlist claim links. GET shows
+// a page asking the client to confirm; its form POSTs the token back, which turns the pending
+// hold into a confirmed booking, creates the Zoom meeting and sends the usual confirmation email.
+func (h *APIHandlers) ConfirmBooking(w http.ResponseWriter, r *http.Request) {
+	var token string
+	switch r.Method {
+	case http.MethodGet:
+		token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		token = r.PostFormValue("token")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if token == "" {
+		http.Redirect(w, r, "/?booking=invalid", http.StatusSeeOther)
+		return
+	}
+
+	b, err := h.bookingByToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying pending booking", "error", err)
+		return
+	}
+	if b == nil {
+		http.Redirect(w, r, "/?booking=invalid", http.StatusSeeOther)
+		return
+	}
+	if status := b.closedStatus(time.Now()); status != "" {
+		http.Redirect(w, r, "/?booking="+status, http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderConfirmBookingPage(w, b.name, b.slotTime.In(h.Policy.location()), token)
+		return
+	}
+
+	zoomLink := h.createZoomMeeting(r.Context(), b.name, b.email, b.slotTime)
+
+	audit := auditorFromRequest(r)
+	audit.actor = Actor{Type: ActorClient, ID: "booking:" + strconv.Itoa(b.id)}
+	confirmed, err := h.confirmPendingBooking(r.Context(), audit, b.id, zoomLink)
+	if err != nil {
+		h.deleteZoomMeeting(r.Context(), zoomLink)
+		http.Error(w, "Failed to confirm booking", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error confirming booking", "booking_id", b.id, "error", err)
+		return
+	}
+	if !confirmed {
+		// The hold expired or was confirmed concurrently
+		h.deleteZoomMeeting(r.Context(), zoomLink)
+		http.Redirect(w, r, "/?booking=expired", http.StatusSeeOther)
+		return
+	}
+
+	h.sendConfirmationEmail(r.Context(), b.id, b.name, b.email, b.slotTime, time.Duration(b.durationMinutes)*time.Minute, b.paid, zoomLink)
+
+	slog.InfoContext(r.Context(), "Booking confirmed via email link", "booking_id", b.id)
+	http.Redirect(w, r, "/?booking=confirmed", http.StatusSeeOther)
+}
+
+// confirmPendingBooking marks a still-valid pending hold as confirmed, attributing the change
+// to audit, the client holding the confirmation token. It reports false if the hold is gone.
+func (h *APIHandlers) confirmPendingBooking(ctx context.Context, audit auditor, id int, zoomLink string) (bool, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadBooking(ctx, tx, id, true)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE bookings SET status = 'confirmed', zoom_link = $1, hold_expires_at = NULL,
+			confirmed_at = CURRENT_TIMESTAMP, status_updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status = 'pending' AND hold_expires_at > CURRENT_TIMESTAMP`,
+		nullString(zoomLink), id,
+	)
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return false, nil
+	}
+
+	after, err := loadBooking(ctx, tx, id, false)
+	if err != nil {
+		return false, err
+	}
+	if err := audit.record(ctx, tx, AuditBookingConfirmed, "booking", strconv.Itoa(id), before, after); err != nil {
+		return false, err
+	}
+
+	// A claimed waitlist offer takes the client off the waitlist
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist_entries SET status = 'booked' WHERE booking_id = $1 AND status = 'offered'", id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// ExpirePendingHolds marks pending bookings whose verification or payment window has passed
+// as expired, freeing their slots. It returns the number of holds expired.
+func (h *APIHandlers) ExpirePendingHolds(ctx context.Context) (int64, error) {
+	ctx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `UPDATE bookings SET status = 'expired', status_updated_at = CURRENT_TIMESTAMP
+		WHERE status = 'pending' AND hold_expires_at <= CURRENT_TIMESTAMP
+		RETURNING id, slot_time, name, email, created_at, status, session_type,
+			COALESCE(package_id, 0), COALESCE(discount_code_id, 0)`)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []Booking
+	for rows.Next() {
+		var b Booking
+		if err := rows.Scan(&b.ID, &b.SlotTime, &b.Name, &b.Email, &b.CreatedAt, &b.Status, &b.SessionType,
+			&b.PackageID, &b.DiscountCodeID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	audit := systemAuditor("hold-expiry")
+	for _, b := range expired {
+		before := b
+		before.Status = StatusPending
+		if err := audit.record(ctx, tx, AuditBookingHoldExpired, "booking", strconv.Itoa(b.ID), before, b); err != nil {
+			return 0, err
+		}
+		if err := releaseRedemptions(ctx, tx, audit, &b); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(expired)), nil
+}
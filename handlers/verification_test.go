@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeldBookingClosedStatus(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	held := func(status string, expiresAt time.Time) *heldBooking {
+		return &heldBooking{status: status, holdExpiresAt: sql.NullTime{Time: expiresAt, Valid: !expiresAt.IsZero()}}
+	}
+
+	tests := []struct {
+		name    string
+		booking *heldBooking
+		want    string
+	}{
+		{"pending hold", held(StatusPending, now.Add(10*time.Minute)), ""},
+		{"pending without expiry", held(StatusPending, time.Time{}), ""},
+		{"hold ran out", held(StatusPending, now.Add(-time.Minute)), "expired"},
+		{"expired by the job", held(StatusExpired, now.Add(-time.Minute)), "expired"},
+		{"already confirmed", held(StatusConfirmed, time.Time{}), "confirmed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.booking.closedStatus(now); got != tt.want {
+				t.Errorf("closedStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmBookingWithoutToken(t *testing.T) {
+	h := &APIHandlers{}
+
+	tests := []struct {
+		method       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{http.MethodGet, http.StatusSeeOther, "/?booking=invalid"},
+		{http.MethodPost, http.StatusSeeOther, "/?booking=invalid"},
+		{http.MethodPut, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/bookings/confirm", strings.NewReader(""))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.ConfirmBooking(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestRenderConfirmBookingPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	renderConfirmBookingPage(w, `<script>alert(1)</script>`, time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), "abc123")
+
+	body := w.Body.String()
+	for _, want := range []string{`method="POST"`, `action="/api/bookings/confirm"`, `value="abc123"`, "02.03.2026 10:00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %s", want)
+		}
+	}
+	if strings.Contains(body, "<script>") {
+		t.Error("page does not escape the client name")
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+}
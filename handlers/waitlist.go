@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Waitlist entry statuses
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+	WaitlistBooked  = "booked"
+	WaitlistExpired = "expired"
+	WaitlistRemoved = "removed"
+)
+
+// Audit actions for the waitlist
+const (
+	AuditWaitlistJoined  = "waitlist.joined"
+	AuditWaitlistOffered = "waitlist.offered"
+	AuditWaitlistExpired = "waitlist.expired"
+	AuditWaitlistRemoved = "waitlist.removed"
+)
+
+// DefaultWaitlistClaimWindow is how long a waitlisted client has to claim an offered slot
+const DefaultWaitlistClaimWindow = 2 * time.Hour
+
+// maxWaitlistWindows bounds the preferred windows a client can leave
+const maxWaitlistWindows = 10
+
+var ErrNoWaitlistMatch = errors.New("no waitlisted client wants this slot")
+
+// WaitlistEntry is a client waiting for a slot in one of their preferred windows
+type WaitlistEntry struct {
+	ID              int          `json:"id"`
+	Name            string       `json:"name"`
+	Email           string       `json:"email"`
+	Windows         []SlotFilter `json:"windows"`
+	Status          string       `json:"status"`
+	BookingID       int          `json:"booking_id,omitempty"`
+	OfferedSlotTime *time.Time   `json:"offered_slot_time,omitempty"`
+	OfferedAt       *time.Time   `json:"offered_at,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// WaitlistRequest is the body of POST /api/waitlist
+type WaitlistRequest struct {
+	Name    string       `json:"name"`
+	Email   string       `json:"email"`
+	Windows []SlotFilter `json:"windows"`
+
+	// Bot protection, as for bookings
+	Website      string `json:"website,omitempty"`
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+func (h *APIHandlers) waitlistClaimWindow() time.Duration {
+	if h.WaitlistClaimWindow > 0 {
+		return h.WaitlistClaimWindow
+	}
+	return DefaultWaitlistClaimWindow
+}
+
+// JoinWaitlist adds a client to the waitlist. A client who is already waiting keeps their
+// place in line and has their name and preferred windows replaced.
+func (h *APIHandlers) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	ip := clientIP(r)
+	if allowed, retryAfter := h.RateLimiter.AllowIP(ctx, ip); !allowed {
+		slog.WarnContext(r.Context(), "Rate limit exceeded for IP", "ip", ip)
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
+	var req WaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Bots fill in every field; pretend success so they don't adapt
+	if req.Website != "" {
+		slog.WarnContext(r.Context(), "Honeypot triggered, ignoring waitlist request", "ip", ip)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Added to the waitlist",
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Name == "" || req.Email == "" || len(req.Windows) == 0 {
+		http.Error(w, "Name, email and at least one window are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Windows) > maxWaitlistWindows {
+		http.Error(w, fmt.Sprintf("At most %d windows are allowed", maxWaitlistWindows), http.StatusBadRequest)
+		return
+	}
+	for _, window := range req.Windows {
+		if _, err := window.parse(h.Policy.location(), false); err != nil {
+			http.Error(w, "Invalid window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if h.Captcha != nil {
+		if err := h.Captcha.Verify(r.Context(), req.CaptchaToken, ip); err != nil {
+			http.Error(w, "CAPTCHA verification failed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if allowed, retryAfter := h.RateLimiter.AllowEmail(ctx, req.Email); !allowed {
+		slog.WarnContext(r.Context(), "Rate limit exceeded for email", "ip", ip)
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
+	windows, err := json.Marshal(req.Windows)
+	if err != nil {
+		http.Error(w, "Invalid windows", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO waitlist_entries (name, email, windows)
+		VALUES ($1, LOWER($2), $3)
+		ON CONFLICT (email) WHERE status IN ('waiting', 'offered')
+		DO UPDATE SET name = EXCLUDED.name, windows = EXCLUDED.windows
+		RETURNING id`,
+		req.Name, req.Email, string(windows),
+	).Scan(&id)
+	if err == nil {
+		after := map[string]interface{}{"id": id, "name": req.Name, "email": req.Email, "windows": req.Windows}
+		err = auditorFromRequest(r).record(ctx, tx, AuditWaitlistJoined, "waitlist_entry", strconv.Itoa(id), nil, after)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to join the waitlist", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error joining waitlist", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Added to the waitlist",
+	})
+}
+
+// offerSlotToWaitlist offers a slot that just became free to the first waiting client whose
+// windows match it. The slot is held as a pending booking that the client claims through the
+// usual confirmation link.
+func (h *APIHandlers) offerSlotToWaitlist(ctx context.Context, slotTime time.Time) error {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	if !slotTime.After(now) || h.Policy.CheckWindow(slotTime, now) != nil {
+		return nil
+	}
+	if h.PublicBaseURL == "" {
+		slog.WarnContext(ctx, "Cannot offer a slot to the waitlist without PUBLIC_BASE_URL", "slot_time", slotTime.UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	// Only offer slots that are actually bookable
+	if err := h.checkBookingCaps(dbCtx, slotTime); err != nil {
+		if errors.Is(err, ErrDailyLimitReached) || errors.Is(err, ErrWeeklyLimitReached) {
+			return nil
+		}
+		return err
+	}
+	sessionTypes, err := h.loadSessionTypes(dbCtx)
+	if err != nil {
+		return err
+	}
+	slotType, ok := sessionTypes[DefaultSessionType]
+	if !ok || slotType.Paid() {
+		// Claim links confirm without payment, so paid sessions are not offered
+		return nil
+	}
+	var blocked int
+	if err := h.DB.QueryRowContext(dbCtx, "SELECT COUNT(*) FROM blocked_slots WHERE slot_time = $1", slotTime.UTC()).Scan(&blocked); err != nil {
+		return err
+	}
+	if blocked > 0 {
+		return nil
+	}
+	sessions, err := h.loadSessions(dbCtx, sessionTypes, slotTime.AddDate(0, 0, -1), slotTime.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if slotOccupancy(slotTime, slotType, sessions) != slotFree {
+		return nil
+	}
+
+	// Free the slot if it is only held by an expired, unconfirmed booking
+	if _, err := h.ExpirePendingHolds(dbCtx); err != nil {
+		slog.WarnContext(ctx, "Failed to clear expired holds", "error", err)
+	}
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	guard := &slotGuard{slotTime: slotTime, slotType: slotType, types: sessionTypes, caps: true}
+	if err := guard.check(dbCtx, tx, h.Policy); err != nil {
+		if errors.Is(err, ErrSlotBooked) || errors.Is(err, ErrSlotInBuffer) ||
+			errors.Is(err, ErrDailyLimitReached) || errors.Is(err, ErrWeeklyLimitReached) {
+			// Someone booked the slot or filled the day in the meantime
+			return nil
+		}
+		return err
+	}
+
+	entry, err := nextWaitlistEntry(dbCtx, tx, slotTime, h.Policy.location())
+	if err == ErrNoWaitlistMatch {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := now.Add(h.waitlistClaimWindow())
+
+	var bookingID int
+	err = tx.QueryRowContext(dbCtx,
+		`INSERT INTO bookings (slot_time, name, email, duration, session_type, status, confirmation_token, hold_expires_at, source)
+		VALUES ($1, $2, $3, $4, $5, 'pending', $6, $7, $8) RETURNING id`,
+		slotTime.UTC(), entry.Name, entry.Email, slotType.DurationMinutes, slotType.Key, token, expiresAt.UTC(), BookingSourceWaitlist,
+	).Scan(&bookingID)
+	if isUniqueViolation(err) {
+		// Someone booked the slot in the meantime
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := linkBookingClient(dbCtx, tx, bookingID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(dbCtx,
+		`UPDATE waitlist_entries SET status = 'offered', booking_id = $1, offered_slot_time = $2, offered_at = CURRENT_TIMESTAMP
+		WHERE id = $3`,
+		bookingID, slotTime.UTC(), entry.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	audit := systemAuditor("waitlist")
+	booking, err := loadBooking(dbCtx, tx, bookingID, false)
+	if err != nil {
+		return err
+	}
+	if err := audit.record(dbCtx, tx, AuditBookingHeld, "booking", strconv.Itoa(bookingID), nil, booking); err != nil {
+		return err
+	}
+	after := map[string]interface{}{"booking_id": bookingID, "slot_time": slotTime.UTC(), "hold_expires_at": expiresAt.UTC()}
+	if err := audit.record(dbCtx, tx, AuditWaitlistOffered, "waitlist_entry", strconv.Itoa(entry.ID), nil, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	metrics.bookingsCreated.inc(booking.Source, booking.Status)
+
+	slog.InfoContext(ctx, "Offered slot to waitlist entry", "slot_time", slotTime.UTC().Format(time.RFC3339), "entry_id", entry.ID, "booking_id", bookingID)
+
+	if h.EmailService != nil {
+		claimURL := bookingConfirmationURL(h.PublicBaseURL, token)
+		if err := h.EmailService.SendWaitlistOffer(ctx, entry.Name, entry.Email, slotTime.In(h.Policy.location()), claimURL, expiresAt); err != nil {
+			slog.WarnContext(ctx, "Slot offered to waitlist entry but failed to send email", "entry_id", entry.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// nextWaitlistEntry locks and returns the longest-waiting entry with a window matching slotTime
+func nextWaitlistEntry(ctx context.Context, tx *sql.Tx, slotTime time.Time, loc *time.Location) (*WaitlistEntry, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, email, windows FROM waitlist_entries
+		WHERE status = 'waiting' ORDER BY created_at, id FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry WaitlistEntry
+		var windows string
+		if err := rows.Scan(&entry.ID, &entry.Name, &entry.Email, &windows); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(windows), &entry.Windows); err != nil {
+			slog.Warn("Skipping waitlist entry with invalid windows", "entry_id", entry.ID, "error", err)
+			continue
+		}
+		for _, window := range entry.Windows {
+			criteria, err := window.parse(loc, false)
+			if err == nil && criteria.matches(slotTime) {
+				return &entry, nil
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNoWaitlistMatch
+}
+
+// notifyWaitlist offers a freed slot to the waitlist after a request-driven change, logging
+// failures. The offer email is sent in the background so the response does not wait on it.
+func (h *APIHandlers) notifyWaitlist(r *http.Request, slotTime time.Time) {
+	// The slot is already free; make the offer even if the client has disconnected
+	ctx := context.WithoutCancel(r.Context())
+	h.runInBackground(func() {
+		if err := h.offerSlotToWaitlist(ctx, slotTime); err != nil {
+			slog.WarnContext(ctx, "Failed to offer freed slot to the waitlist", "error", err)
+		}
+	})
+}
+
+// ExpireWaitlistOffers closes offers whose hold was released without being claimed, or
+// cancelled by the coach, and passes each slot on to the next client in line. It returns the
+// number of offers expired. Run it after ExpirePendingHolds, which expires unclaimed holds.
+func (h *APIHandlers) ExpireWaitlistOffers(ctx context.Context) (int, error) {
+	dbCtx, cancel := h.dbContext(ctx)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(dbCtx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(dbCtx, `UPDATE waitlist_entries SET status = 'expired'
+		WHERE status = 'offered' AND (booking_id IS NULL OR booking_id IN
+			(SELECT id FROM bookings WHERE status IN ('cancelled_by_client', 'cancelled_by_coach', 'expired')))
+		RETURNING id, offered_slot_time`)
+	if err != nil {
+		return 0, err
+	}
+
+	type expiredOffer struct {
+		id       int
+		slotTime time.Time
+	}
+	var expired []expiredOffer
+	for rows.Next() {
+		var offer expiredOffer
+		if err := rows.Scan(&offer.id, &offer.slotTime); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, offer)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	audit := systemAuditor("waitlist")
+	for _, offer := range expired {
+		before := map[string]interface{}{"status": WaitlistOffered, "offered_slot_time": offer.slotTime}
+		if err := audit.record(dbCtx, tx, AuditWaitlistExpired, "waitlist_entry", strconv.Itoa(offer.id), before, nil); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, offer := range expired {
+		if err := h.offerSlotToWaitlist(ctx, offer.slotTime); err != nil {
+			slog.WarnContext(ctx, "Failed to offer slot to the next waitlisted client", "slot_time", offer.slotTime.UTC().Format(time.RFC3339), "error", err)
+		}
+	}
+	return len(expired), nil
+}
+
+const waitlistEntryColumns = "id, name, email, windows, status, booking_id, offered_slot_time, offered_at, created_at"
+
+func scanWaitlistEntry(row rowScanner) (*WaitlistEntry, error) {
+	var e WaitlistEntry
+	var windows string
+	var bookingID sql.NullInt64
+	var offeredSlotTime, offeredAt sql.NullTime
+	if err := row.Scan(&e.ID, &e.Name, &e.Email, &windows, &e.Status, &bookingID, &offeredSlotTime, &offeredAt, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(windows), &e.Windows); err != nil {
+		return nil, fmt.Errorf("invalid windows of waitlist entry %d: %w", e.ID, err)
+	}
+	e.BookingID = int(bookingID.Int64)
+	e.OfferedSlotTime = nullTimePtr(offeredSlotTime)
+	e.OfferedAt = nullTimePtr(offeredAt)
+	return &e, nil
+}
+
+// Waitlist lists waitlist entries for the admin, oldest first; status filters by status
+func (h *APIHandlers) Waitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	query := "SELECT " + waitlistEntryColumns + " FROM waitlist_entries"
+	var args []interface{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " WHERE status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying waitlist", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	entries := []WaitlistEntry{}
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Error scanning waitlist entry", "error", err)
+			return
+		}
+		entries = append(entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error querying waitlist", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// RemoveWaitlistEntry takes a client off the waitlist. A slot already offered to them stays
+// held until the claim link expires.
+func (h *APIHandlers) RemoveWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := h.dbContext(r.Context())
+	defer cancel()
+
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM waitlist_entries WHERE id = $1 FOR UPDATE", req.ID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE waitlist_entries SET status = 'removed' WHERE id = $1", req.ID)
+	}
+	if err == nil {
+		err = auditorFromRequest(r).record(ctx, tx, AuditWaitlistRemoved, "waitlist_entry", strconv.Itoa(req.ID),
+			map[string]string{"status": previous}, map[string]string{"status": WaitlistRemoved})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		http.Error(w, "Failed to remove waitlist entry", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error removing waitlist entry", "entry_id", req.ID, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Removed from the waitlist",
+	})
+}
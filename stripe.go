@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"coach-calendar-app/handlers"
+)
+
+const stripeAPI = "https://api.stripe.com/v1"
+
+// Stripe only accepts checkout expiry between 30 minutes and 24 hours from creation
+const (
+	stripeMinCheckoutExpiry = 31 * time.Minute
+	stripeMaxCheckoutExpiry = 24 * time.Hour
+)
+
+// stripeWebhookTolerance bounds the age of a signed webhook to prevent replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+// StripeService takes payments through Stripe Checkout
+type StripeService struct {
+	SecretKey     string
+	WebhookSecret string
+	client        *http.Client
+}
+
+type stripeCheckoutSession struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	PaymentIntent string `json:"payment_intent"`
+	PaymentStatus string `json:"payment_status"`
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object stripeCheckoutSession `json:"object"`
+	} `json:"data"`
+}
+
+// StripeConfig holds the STRIPE_* settings
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string
+}
+
+// NewStripeService returns a Stripe provider. LoadConfig has checked that both keys are
+// set when PAYMENT_PROVIDER=stripe.
+func NewStripeService(cfg StripeConfig) *StripeService {
+	slog.Info("Stripe payments enabled")
+	return &StripeService{
+		SecretKey:     cfg.SecretKey,
+		WebhookSecret: cfg.WebhookSecret,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *StripeService) Name() string {
+	return "stripe"
+}
+
+// post sends a form-encoded request to the Stripe API and decodes the JSON response into out
+func (s *StripeService) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stripeAPI+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(s.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.Error("Stripe API error", "status", resp.StatusCode, "body", string(bodyBytes))
+		return fmt.Errorf("stripe API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// CreateCheckout creates a hosted Checkout Session for a single paid session
+func (s *StripeService) CreateCheckout(ctx context.Context, req handlers.CheckoutRequest) (*handlers.Checkout, error) {
+	expiresAt := req.ExpiresAt
+	now := time.Now()
+	if expiresAt.Before(now.Add(stripeMinCheckoutExpiry)) {
+		// A late payment for a released hold is refunded by the webhook
+		expiresAt = now.Add(stripeMinCheckoutExpiry)
+	}
+	if expiresAt.After(now.Add(stripeMaxCheckoutExpiry)) {
+		expiresAt = now.Add(stripeMaxCheckoutExpiry)
+	}
+
+	bookingID := strconv.Itoa(req.BookingID)
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("customer_email", req.CustomerEmail)
+	form.Set("client_reference_id", bookingID)
+	form.Set("metadata[booking_id]", bookingID)
+	form.Set("expires_at", strconv.FormatInt(expiresAt.Unix(), 10))
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(req.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.Itoa(req.AmountCents))
+	form.Set("line_items[0][price_data][product_data][name]", req.Description)
+
+	var session stripeCheckoutSession
+	if err := s.post(ctx, "/checkout/sessions", form, "checkout-booking-"+bookingID, &session); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Stripe checkout created", "booking_id", bookingID, "checkout_id", session.ID)
+	return &handlers.Checkout{ID: session.ID, URL: session.URL}, nil
+}
+
+// ExpireCheckout closes an open Checkout Session
+func (s *StripeService) ExpireCheckout(ctx context.Context, checkoutID string) error {
+	return s.post(ctx, "/checkout/sessions/"+url.PathEscape(checkoutID)+"/expire", url.Values{}, "", nil)
+}
+
+// Refund refunds a PaymentIntent in full
+func (s *StripeService) Refund(ctx context.Context, paymentID string) (string, error) {
+	if paymentID == "" {
+		return "", fmt.Errorf("payment has no payment intent to refund")
+	}
+	form := url.Values{}
+	form.Set("payment_intent", paymentID)
+
+	var refund struct {
+		ID string `json:"id"`
+	}
+	if err := s.post(ctx, "/refunds", form, "refund-"+paymentID, &refund); err != nil {
+		return "", err
+	}
+	return refund.ID, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and maps Checkout Session events
+func (s *StripeService) ParseWebhook(r *http.Request) (*handlers.PaymentEvent, error) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySignature(payload, r.Header.Get("Stripe-Signature"), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("%w: %v", handlers.ErrInvalidWebhook, err)
+	}
+
+	session := event.Data.Object
+	result := &handlers.PaymentEvent{CheckoutID: session.ID, PaymentID: session.PaymentIntent}
+	switch event.Type {
+	case "checkout.session.completed":
+		// Delayed payment methods complete unpaid and report later
+		if session.PaymentStatus == "paid" {
+			result.Type = handlers.PaymentEventPaid
+		}
+	case "checkout.session.async_payment_succeeded":
+		result.Type = handlers.PaymentEventPaid
+	case "checkout.session.expired", "checkout.session.async_payment_failed":
+		result.Type = handlers.PaymentEventExpired
+	}
+	return result, nil
+}
+
+// verifySignature checks a "t=<unix>,v1=<hex hmac>" header against the payload
+func (s *StripeService) verifySignature(payload []byte, header string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("%w: missing signature", handlers.ErrInvalidWebhook)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp", handlers.ErrInvalidWebhook)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", handlers.ErrInvalidWebhook)
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		got, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature mismatch", handlers.ErrInvalidWebhook)
+}
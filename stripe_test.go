@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"coach-calendar-app/handlers"
+)
+
+func signStripePayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestStripeVerifySignature(t *testing.T) {
+	s := &StripeService{WebhookSecret: "whsec_test"}
+	payload := []byte(`{"type":"checkout.session.completed"}`)
+	now := time.Unix(1767225600, 0)
+	ts := now.Unix()
+	valid := signStripePayload(s.WebhookSecret, ts, payload)
+	otherSecret := signStripePayload("whsec_other", ts, payload)
+
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		wantErr bool
+	}{
+		{"valid", fmt.Sprintf("t=%d,v1=%s", ts, valid), payload, false},
+		{"valid with v0", fmt.Sprintf("t=%d,v1=%s,v0=deadbeef", ts, valid), payload, false},
+		{"one of several v1 valid", fmt.Sprintf("t=%d,v1=%s,v1=%s", ts, otherSecret, valid), payload, false},
+		{"within tolerance", fmt.Sprintf("t=%d,v1=%s", ts-240, signStripePayload(s.WebhookSecret, ts-240, payload)), payload, false},
+		{"too old", fmt.Sprintf("t=%d,v1=%s", ts-600, signStripePayload(s.WebhookSecret, ts-600, payload)), payload, true},
+		{"too far in the future", fmt.Sprintf("t=%d,v1=%s", ts+600, signStripePayload(s.WebhookSecret, ts+600, payload)), payload, true},
+		{"wrong secret", fmt.Sprintf("t=%d,v1=%s", ts, otherSecret), payload, true},
+		{"tampered payload", fmt.Sprintf("t=%d,v1=%s", ts, valid), []byte(`{"type":"checkout.session.expired"}`), true},
+		{"signature for another timestamp", fmt.Sprintf("t=%d,v1=%s", ts+1, valid), payload, true},
+		{"not hex", fmt.Sprintf("t=%d,v1=zz", ts), payload, true},
+		{"missing signature", fmt.Sprintf("t=%d", ts), payload, true},
+		{"missing timestamp", "v1=" + valid, payload, true},
+		{"invalid timestamp", "t=soon,v1=" + valid, payload, true},
+		{"empty header", "", payload, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.verifySignature(tt.payload, tt.header, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, handlers.ErrInvalidWebhook) {
+				t.Errorf("verifySignature() error = %v, want ErrInvalidWebhook", err)
+			}
+		})
+	}
+}
@@ -2,15 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"sync"
 	"time"
+
+	"coach-calendar-app/handlers"
 )
 
 type ZoomService struct {
@@ -20,9 +23,13 @@ type ZoomService struct {
 	AccessToken  string
 	TokenExpiry  time.Time
 	Enabled      bool
+	Timeout      time.Duration // bounds each operation, including the token request
 	mu           sync.Mutex
 }
 
+// defaultZoomTimeout bounds a Zoom operation when ZOOM_TIMEOUT is unset
+const defaultZoomTimeout = 10 * time.Second
+
 type zoomTokenResponse struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
@@ -54,29 +61,52 @@ type zoomMeetingResponse struct {
 	StartURL string `json:"start_url"`
 }
 
-func NewZoomService() *ZoomService {
-	accountID := os.Getenv("ZOOM_ACCOUNT_ID")
-	clientID := os.Getenv("ZOOM_CLIENT_ID")
-	clientSecret := os.Getenv("ZOOM_CLIENT_SECRET")
+// ZoomConfig holds the ZOOM_* settings
+type ZoomConfig struct {
+	AccountID    string
+	ClientID     string
+	ClientSecret string
+	Timeout      time.Duration
+}
 
-	enabled := accountID != "" && clientID != "" && clientSecret != ""
+func NewZoomService(cfg ZoomConfig) *ZoomService {
+	enabled := cfg.AccountID != "" && cfg.ClientID != "" && cfg.ClientSecret != ""
 
 	if !enabled {
-		log.Println("Zoom integration disabled - configuration not found")
-		log.Println("To enable Zoom integration, set: ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID, ZOOM_CLIENT_SECRET")
+		slog.Info("Zoom integration disabled - configuration not found")
+		slog.Info("To enable Zoom integration, set: ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID, ZOOM_CLIENT_SECRET")
 		return &ZoomService{Enabled: false}
 	}
 
-	log.Println("Zoom integration enabled")
+	slog.Info("Zoom integration enabled")
 	return &ZoomService{
-		AccountID:    accountID,
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
+		AccountID:    cfg.AccountID,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
 		Enabled:      enabled,
+		Timeout:      cfg.Timeout,
+	}
+}
+
+// ConfigStatus reports whether Zoom is configured, for the readiness probe
+func (z *ZoomService) ConfigStatus() handlers.ComponentStatus {
+	if !z.Enabled {
+		return handlers.ComponentStatus{Status: handlers.ComponentDisabled, Detail: "Zoom configuration not found"}
 	}
+	return handlers.ComponentStatus{Status: handlers.ComponentOK}
 }
 
-func (z *ZoomService) getAccessToken() (string, error) {
+// withTimeout bounds one Zoom operation. The request context still cancels it earlier,
+// e.g. when the client disconnects.
+func (z *ZoomService) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := z.Timeout
+	if timeout <= 0 {
+		timeout = defaultZoomTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (z *ZoomService) getAccessToken(ctx context.Context) (string, error) {
 	z.mu.Lock()
 	defer z.mu.Unlock()
 
@@ -91,7 +121,7 @@ func (z *ZoomService) getAccessToken() (string, error) {
 	data.Set("grant_type", "account_credentials")
 	data.Set("account_id", z.AccountID)
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create token request: %w", err)
 	}
@@ -99,10 +129,9 @@ func (z *ZoomService) getAccessToken() (string, error) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetBasicAuth(z.ClientID, z.ClientSecret)
 
-	log.Printf("Requesting Zoom OAuth token for account: %s", z.AccountID)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	handlers.ObserveZoomCall("oauth_token", zoomOutcome(resp, err, http.StatusOK), time.Since(start))
 	if err != nil {
 		return "", fmt.Errorf("failed to request token: %w", err)
 	}
@@ -111,9 +140,7 @@ func (z *ZoomService) getAccessToken() (string, error) {
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Zoom OAuth error - Status: %d, Response: %s", resp.StatusCode, string(body))
-		log.Printf("Debug - Account ID length: %d, Client ID length: %d, Client Secret length: %d",
-			len(z.AccountID), len(z.ClientID), len(z.ClientSecret))
+		slog.ErrorContext(ctx, "Zoom OAuth error", "status", resp.StatusCode, "body", string(body))
 		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -126,20 +153,23 @@ func (z *ZoomService) getAccessToken() (string, error) {
 	z.AccessToken = tokenResp.AccessToken
 	z.TokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-300) * time.Second) // Refresh 5 min early
 
-	log.Println("Successfully obtained Zoom access token")
+	slog.DebugContext(ctx, "Obtained Zoom access token")
 	return z.AccessToken, nil
 }
 
-func (z *ZoomService) CreateMeeting(name, email string, slotTime time.Time) (string, error) {
+func (z *ZoomService) CreateMeeting(ctx context.Context, name, email string, slotTime time.Time) (string, error) {
 	if !z.Enabled {
-		log.Println("Zoom is disabled - skipping meeting creation")
+		slog.DebugContext(ctx, "Zoom is disabled - skipping meeting creation")
 		return "", nil
 	}
 
+	ctx, cancel := z.withTimeout(ctx)
+	defer cancel()
+
 	// Get access token
-	token, err := z.getAccessToken()
+	token, err := z.getAccessToken(ctx)
 	if err != nil {
-		log.Printf("Failed to get Zoom access token: %v", err)
+		slog.ErrorContext(ctx, "Failed to get Zoom access token", "error", err)
 		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
 
@@ -172,7 +202,7 @@ func (z *ZoomService) CreateMeeting(name, email string, slotTime time.Time) (str
 
 	// Create HTTP request
 	apiURL := "https://api.zoom.us/v2/users/me/meetings"
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
 	if err != nil {
 		return "", fmt.Errorf("failed to create meeting request: %w", err)
 	}
@@ -181,8 +211,9 @@ func (z *ZoomService) CreateMeeting(name, email string, slotTime time.Time) (str
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	handlers.ObserveZoomCall("create_meeting", zoomOutcome(resp, err, http.StatusCreated), time.Since(start))
 	if err != nil {
 		return "", fmt.Errorf("failed to send meeting request: %w", err)
 	}
@@ -191,7 +222,7 @@ func (z *ZoomService) CreateMeeting(name, email string, slotTime time.Time) (str
 	// Check response
 	if resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Zoom API error: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		slog.ErrorContext(ctx, "Zoom API error", "status", resp.StatusCode, "body", string(bodyBytes))
 		return "", fmt.Errorf("zoom API returned status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
@@ -201,19 +232,116 @@ func (z *ZoomService) CreateMeeting(name, email string, slotTime time.Time) (str
 		return "", fmt.Errorf("failed to decode meeting response: %w", err)
 	}
 
-	log.Printf("Zoom meeting created successfully: %s (ID: %d)", meetingResp.JoinURL, meetingResp.ID)
+	slog.InfoContext(ctx, "Zoom meeting created", "meeting_id", meetingResp.ID)
 	return meetingResp.JoinURL, nil
 }
 
+// UpdateMeeting moves a Zoom meeting to a new start time, keeping its join URL
+func (z *ZoomService) UpdateMeeting(ctx context.Context, joinURL string, slotTime time.Time) error {
+	if !z.Enabled {
+		slog.DebugContext(ctx, "Zoom is disabled - skipping meeting update")
+		return nil
+	}
+
+	if joinURL == "" {
+		return nil
+	}
+
+	meetingID, err := z.patchMeeting(ctx, "update_meeting", joinURL, map[string]string{
+		"start_time": slotTime.UTC().Format("2006-01-02T15:04:05Z"),
+		"timezone":   "UTC",
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Zoom meeting moved", "meeting_id", meetingID, "start_time", slotTime.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// ScrubMeeting replaces the client's name and email in a meeting's topic and agenda with a
+// neutral title. Meetings that no longer exist have nothing left to scrub.
+func (z *ZoomService) ScrubMeeting(ctx context.Context, joinURL string) error {
+	if !z.Enabled || joinURL == "" {
+		return nil
+	}
+
+	meetingID, err := z.patchMeeting(ctx, "scrub_meeting", joinURL, map[string]string{
+		"topic":  "Онлайн-консультація",
+		"agenda": "",
+	})
+	if errors.Is(err, errZoomMeetingNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Zoom meeting scrubbed of client details", "meeting_id", meetingID)
+	return nil
+}
+
+var errZoomMeetingNotFound = errors.New("zoom meeting not found")
+
+// patchMeeting sends a PATCH with fields for the meeting behind joinURL and returns its ID.
+// operation names the call in metrics.
+func (z *ZoomService) patchMeeting(ctx context.Context, operation, joinURL string, fields map[string]string) (string, error) {
+	meetingID, err := extractMeetingIDFromURL(joinURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract meeting ID: %w", err)
+	}
+
+	ctx, cancel := z.withTimeout(ctx)
+	defer cancel()
+
+	token, err := z.getAccessToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal update request: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("https://api.zoom.us/v2/meetings/%s", meetingID)
+	req, err := http.NewRequestWithContext(ctx, "PATCH", apiURL, bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create update request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	handlers.ObserveZoomCall(operation, zoomOutcome(resp, err, http.StatusNoContent), time.Since(start))
+	if err != nil {
+		return "", fmt.Errorf("failed to send update request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errZoomMeetingNotFound
+	}
+	// 204 No Content on success
+	if resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.ErrorContext(ctx, "Zoom update API error", "status", resp.StatusCode, "body", string(bodyBytes))
+		return "", fmt.Errorf("zoom API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return meetingID, nil
+}
+
 // DeleteMeeting deletes a Zoom meeting by extracting the meeting ID from the join URL
-func (z *ZoomService) DeleteMeeting(joinURL string) error {
+func (z *ZoomService) DeleteMeeting(ctx context.Context, joinURL string) error {
 	if !z.Enabled {
-		log.Println("Zoom is disabled - skipping meeting deletion")
+		slog.DebugContext(ctx, "Zoom is disabled - skipping meeting deletion")
 		return nil
 	}
 
 	if joinURL == "" {
-		log.Println("No Zoom meeting URL provided - skipping deletion")
+		slog.DebugContext(ctx, "No Zoom meeting URL provided - skipping deletion")
 		return nil
 	}
 
@@ -221,20 +349,23 @@ func (z *ZoomService) DeleteMeeting(joinURL string) error {
 	// Format: https://zoom.us/j/1234567890?pwd=...
 	meetingID, err := extractMeetingIDFromURL(joinURL)
 	if err != nil {
-		log.Printf("Failed to extract meeting ID from URL: %v", err)
+		slog.ErrorContext(ctx, "Failed to extract meeting ID from URL", "error", err)
 		return fmt.Errorf("failed to extract meeting ID: %w", err)
 	}
 
+	ctx, cancel := z.withTimeout(ctx)
+	defer cancel()
+
 	// Get access token
-	token, err := z.getAccessToken()
+	token, err := z.getAccessToken(ctx)
 	if err != nil {
-		log.Printf("Failed to get Zoom access token: %v", err)
+		slog.ErrorContext(ctx, "Failed to get Zoom access token", "error", err)
 		return fmt.Errorf("failed to get access token: %w", err)
 	}
 
 	// Delete meeting via Zoom API
 	apiURL := fmt.Sprintf("https://api.zoom.us/v2/meetings/%s", meetingID)
-	req, err := http.NewRequest("DELETE", apiURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", apiURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create delete request: %w", err)
 	}
@@ -242,8 +373,9 @@ func (z *ZoomService) DeleteMeeting(joinURL string) error {
 	req.Header.Set("Authorization", "Bearer "+token)
 
 	// Send request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	handlers.ObserveZoomCall("delete_meeting", zoomOutcome(resp, err, http.StatusNoContent), time.Since(start))
 	if err != nil {
 		return fmt.Errorf("failed to send delete request: %w", err)
 	}
@@ -252,19 +384,33 @@ func (z *ZoomService) DeleteMeeting(joinURL string) error {
 	// Check response (204 No Content on success, 404 if meeting doesn't exist)
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Zoom delete API error: status %d, body: %s", resp.StatusCode, string(bodyBytes))
+		slog.ErrorContext(ctx, "Zoom delete API error", "status", resp.StatusCode, "body", string(bodyBytes))
 		return fmt.Errorf("zoom API returned status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	if resp.StatusCode == http.StatusNotFound {
-		log.Printf("Zoom meeting %s not found (may have been already deleted)", meetingID)
+		slog.InfoContext(ctx, "Zoom meeting not found (may have been already deleted)", "meeting_id", meetingID)
 	} else {
-		log.Printf("Zoom meeting deleted successfully: %s", meetingID)
+		slog.InfoContext(ctx, "Zoom meeting deleted", "meeting_id", meetingID)
 	}
 
 	return nil
 }
 
+// zoomOutcome classifies a Zoom API response for metrics: the expected status is a success
+func zoomOutcome(resp *http.Response, err error, expected int) string {
+	switch {
+	case err != nil:
+		return handlers.OutcomeFailure
+	case resp.StatusCode == expected:
+		return handlers.OutcomeSuccess
+	case resp.StatusCode == http.StatusNotFound:
+		return handlers.OutcomeNotFound
+	default:
+		return handlers.OutcomeFailure
+	}
+}
+
 // extractMeetingIDFromURL extracts the meeting ID from a Zoom join URL
 func extractMeetingIDFromURL(joinURL string) (string, error) {
 	// Parse the URL